@@ -0,0 +1,31 @@
+// Command config-schema writes the JSON Schema of the routing config, generated from config.Config
+//
+// Usage: config-schema [-o config/routing.schema.json]
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+func main() {
+	output := flag.String("o", "", "File to write the schema to (default stdout)")
+	flag.Parse()
+
+	schema, err := config.Schema()
+	if err != nil {
+		log.Fatalf("Failed to generate schema: %v", err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(*output, schema, 0644); err != nil {
+		log.Fatalf("Failed to write schema: %v", err)
+	}
+	log.Printf("Wrote %s", *output)
+}
@@ -2,30 +2,68 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/admin"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/controlplane"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
 )
 
+// Data planes are not browsers and send no Origin header, which the default origin check accepts
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for demo
-	},
 }
 
 func main() {
 	// Load configuration
-	configPath := getEnv("CONFIG_PATH", "config/routing.json")
-	configLoader := config.NewLoader(configPath, 5*time.Second)
+	// CONFIG_PATH is a file, a directory of fragments, or a URI (file://, dir://, http(s)://)
+	source, err := config.OpenSource(getEnv("CONFIG_PATH", "config/routing.json"))
+	if err != nil {
+		log.Fatalf("Invalid CONFIG_PATH: %v", err)
+	}
+	configLoader := config.NewSourceLoader(source, 5*time.Second)
+
+	// Replicas sharing a lease file elect one leader; each keeps its own history of the shared config
+	leaseFile := os.Getenv("LEADER_LEASE_FILE")
+	hostname, _ := os.Hostname()
+	replicaID := getEnv("CONTROL_PLANE_ID", hostname)
+	// State shared by replicas defaults to the directory holding the config
+	stateDir := "."
+	switch source := source.(type) {
+	case *config.FileSource:
+		stateDir = filepath.Dir(source.Path())
+	case *config.DirectorySource:
+		stateDir = filepath.Dir(filepath.Clean(source.Path()))
+	}
+	defaultHistoryDir := filepath.Join(stateDir, "history")
+	if leaseFile != "" {
+		defaultHistoryDir = filepath.Join(defaultHistoryDir, replicaID)
+	}
+
+	// Keep the last accepted configs on disk for diff and rollback
+	historyDir := getEnv("CONFIG_HISTORY_DIR", defaultHistoryDir)
+	historyLimit, err := strconv.Atoi(getEnv("CONFIG_HISTORY_LIMIT", strconv.Itoa(config.DefaultHistoryLimit)))
+	if err != nil {
+		log.Fatalf("Invalid CONFIG_HISTORY_LIMIT: %v", err)
+	}
+	history, err := config.NewHistory(historyDir, historyLimit)
+	if err != nil {
+		log.Fatalf("Failed to open config history: %v", err)
+	}
+	configLoader.SetHistory(history)
 
 	if err := configLoader.LoadInitial(); err != nil {
 		log.Fatalf("Failed to load config: %v", err)
@@ -37,19 +75,94 @@ func main() {
 
 	// Create control plane server
 	cpServer := controlplane.NewServer(configLoader)
+	pingInterval, pongTimeout, err := protocol.HeartbeatFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid heartbeat settings: %v", err)
+	}
+	cpServer.SetHeartbeat(pingInterval, pongTimeout)
+
+	// Sign configs sent to data planes
+	if keyFile := os.Getenv("CONFIG_SIGNING_KEY_FILE"); keyFile != "" {
+		signingKey, err := config.LoadSigningKey(keyFile)
+		if err != nil {
+			log.Fatalf("Invalid CONFIG_SIGNING_KEY_FILE: %v", err)
+		}
+		cpServer.SetSigningKey(signingKey)
+		log.Printf("Signing configs sent to data planes with the key in %s", keyFile)
+	}
+
+	// Stage new config versions across the fleet if configured
+	if waves := os.Getenv("ROLLOUT_WAVES"); waves != "" {
+		strategy := rolloutStrategy(waves)
+		cpServer.SetRolloutStrategy(strategy)
+		log.Printf("Rolling out config in waves %v (bake %v, ack timeout %v, max nack ratio %v)",
+			strategy.Waves, strategy.BakeTime, strategy.AckTimeout, strategy.MaxNackRatio)
+	}
+
+	// Only the leader accepts config writes and runs rollouts; followers serve snapshots
+	if leaseFile != "" {
+		ttl, err := time.ParseDuration(getEnv("LEADER_LEASE_TTL", controlplane.DefaultLeaseTTL.String()))
+		if err != nil {
+			log.Fatalf("Invalid LEADER_LEASE_TTL: %v", err)
+		}
+		lease := controlplane.NewFileLease(leaseFile)
+		elector := controlplane.NewElector(lease, replicaID, os.Getenv("CONTROL_PLANE_ADVERTISE_URL"), ttl)
+		cpServer.SetElector(elector, controlplane.NewFileRelease(lease))
+		elector.Start()
+		defer elector.Stop()
+		http.Handle("/debug/leader", elector)
+		log.Printf("Control plane %s campaigning for leadership via %s (lease %v)", replicaID, leaseFile, ttl)
+	}
 
 	// Watch for config changes and broadcast
 	go cpServer.WatchConfigChanges()
 
-	// WebSocket endpoint for data planes to connect
-	http.HandleFunc("/connect", func(w http.ResponseWriter, r *http.Request) {
-		conn, err := upgrader.Upgrade(w, r, nil)
-		if err != nil {
-			log.Printf("Failed to upgrade connection: %v", err)
-			return
+	// Data plane authentication: client certificates (mTLS) and/or tokens signed with per data plane credentials
+	tlsConfig := serverTLSConfig()
+	mtls := tlsConfig != nil && tlsConfig.ClientCAs != nil
+	var credentials *controlplane.CredentialStore
+	if path := os.Getenv("DP_CREDENTIALS_FILE"); path != "" {
+		if credentials, err = controlplane.NewCredentialStore(path); err != nil {
+			log.Fatalf("Failed to load data plane credentials: %v", err)
 		}
-		cpServer.HandleConnection(conn)
-	})
+	}
+	if mtls || credentials != nil {
+		cpServer.SetAuthenticator(controlplane.NewAuthenticator(credentials, mtls))
+		log.Printf("Data plane authentication enabled (mtls: %v, token: %v)", mtls, credentials != nil)
+	} else {
+		log.Println("DP_CREDENTIALS_FILE and TLS_CLIENT_CA_FILE not set, accepting unauthenticated data planes")
+	}
+	revocations, err := controlplane.NewRevocationStore(getEnv("DP_REVOKED_FILE", filepath.Join(stateDir, "revoked-data-planes.json")))
+	if err != nil {
+		log.Fatalf("Failed to load revoked data planes: %v", err)
+	}
+	cpServer.SetRevocationStore(revocations)
+	for _, id := range strings.Split(os.Getenv("DP_REVOKED"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			if err := cpServer.RevokeDataPlane(id); err != nil {
+				log.Fatalf("Failed to revoke data plane: %v", err)
+			}
+		}
+	}
+
+	// WebSocket endpoint for data planes to connect
+	http.Handle("/connect", cpServer.ConnectHandler(&upgrader))
+
+	// Config polling for data planes whose network blocks long-lived WebSockets
+	pollHandler := cpServer.PollHandler()
+	http.Handle("/config", pollHandler)
+	http.Handle("/config"+protocol.PollAckPath, pollHandler)
+
+	// Admin endpoints: operator overrides fanned out to data planes, and routing config changes
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		http.Handle("/admin/", admin.NewHandler(token, cpServer))
+		http.Handle("/api/v1/", admin.RequireToken(token, controlplane.NewAPIHandler(cpServer)))
+	} else {
+		log.Println("ADMIN_TOKEN not set, admin API disabled")
+	}
+
+	// Fleet convergence on the current config version
+	http.Handle("/debug/fleet", controlplane.NewFleetHandler(cpServer))
 
 	// Health endpoint
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -63,12 +176,17 @@ func main() {
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		TLSConfig:    tlsConfig,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Control plane starting on port %s", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("Control plane starting on port %s (tls: %v)", port, tlsConfig != nil)
+		listen := server.ListenAndServe
+		if tlsConfig != nil {
+			listen = func() error { return server.ListenAndServeTLS("", "") }
+		}
+		if err := listen(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed: %v", err)
 		}
 	}()
@@ -90,6 +208,69 @@ func main() {
 	log.Println("Control plane stopped")
 }
 
+// rolloutStrategy builds a rollout strategy from comma-separated cumulative wave fractions and ROLLOUT_* settings
+func rolloutStrategy(waves string) *controlplane.RolloutStrategy {
+	strategy := &controlplane.RolloutStrategy{}
+	for _, wave := range strings.Split(waves, ",") {
+		fraction, err := strconv.ParseFloat(strings.TrimSpace(wave), 64)
+		if err != nil {
+			log.Fatalf("Invalid ROLLOUT_WAVES: %v", err)
+		}
+		strategy.Waves = append(strategy.Waves, fraction)
+	}
+
+	var err error
+	if strategy.BakeTime, err = time.ParseDuration(getEnv("ROLLOUT_BAKE_TIME", "30s")); err != nil {
+		log.Fatalf("Invalid ROLLOUT_BAKE_TIME: %v", err)
+	}
+	if strategy.AckTimeout, err = time.ParseDuration(getEnv("ROLLOUT_ACK_TIMEOUT", "10s")); err != nil {
+		log.Fatalf("Invalid ROLLOUT_ACK_TIMEOUT: %v", err)
+	}
+	if strategy.MaxNackRatio, err = strconv.ParseFloat(getEnv("ROLLOUT_MAX_NACK_RATIO", "0"), 64); err != nil {
+		log.Fatalf("Invalid ROLLOUT_MAX_NACK_RATIO: %v", err)
+	}
+
+	if err := strategy.Validate(); err != nil {
+		log.Fatalf("Invalid rollout strategy: %v", err)
+	}
+	return strategy
+}
+
+// serverTLSConfig builds TLS settings from TLS_CERT_FILE/TLS_KEY_FILE, verifying data plane client
+// certificates against TLS_CLIENT_CA_FILE when set; nil when TLS is not configured
+func serverTLSConfig() *tls.Config {
+	certFile := os.Getenv("TLS_CERT_FILE")
+	if certFile == "" {
+		if os.Getenv("TLS_CLIENT_CA_FILE") != "" {
+			log.Fatal("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, os.Getenv("TLS_KEY_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to load TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if caFile := os.Getenv("TLS_CLIENT_CA_FILE"); caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			log.Fatalf("Failed to read TLS_CLIENT_CA_FILE: %v", err)
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(caPEM) {
+			log.Fatalf("No certificates found in TLS_CLIENT_CA_FILE %s", caFile)
+		}
+		// Certificates are optional so token-authenticated data planes and operators can still connect
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
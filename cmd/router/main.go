@@ -2,19 +2,28 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/admin"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/dataplane"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/debug"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/limits"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/proxy"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/routing"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/tracing"
 )
 
 func main() {
@@ -32,20 +41,100 @@ func main() {
 		configPath = getEnv("CONFIG_PATH", "config/routing.json")
 	}
 
-	configLoader := config.NewLoader(configPath, 5*time.Second)
+	// CONFIG_PATH is a file, a directory of fragments, or a URI (file://, dir://, http(s)://)
+	source, err := config.OpenSource(configPath)
+	if err != nil {
+		log.Fatalf("Invalid CONFIG_PATH: %v", err)
+	}
+	configLoader := config.NewSourceLoader(source, 5*time.Second)
+
+	// Refuse config files and control plane snapshots not signed by the matching private key
+	var verifyKey ed25519.PublicKey
+	if keyFile := os.Getenv("CONFIG_VERIFY_KEY_FILE"); keyFile != "" {
+		var err error
+		if verifyKey, err = config.LoadVerifyKey(keyFile); err != nil {
+			log.Fatalf("Invalid CONFIG_VERIFY_KEY_FILE: %v", err)
+		}
+		configLoader.SetVerifyKey(verifyKey)
+		log.Printf("Requiring configs signed by the key in %s", keyFile)
+	}
 
-	// Load initial config (fail fast if invalid)
-	if err := configLoader.LoadInitial(); err != nil {
+	// Load initial config (fail fast if invalid); in CP mode prefer the last config acked from the CP
+	cacheFile := getEnv("CONFIG_CACHE_PATH", "config/dataplane-cache.json")
+	loadInitial := configLoader.LoadInitial
+	if cpURL != "" {
+		loadInitial = func() error { return configLoader.LoadInitialWithCache(cacheFile) }
+	}
+	if err := loadInitial(); err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Create router with config loader
+	router := routing.NewRouter(configLoader)
+
+	// Create proxy handler (pass config for resilience mechanisms, reapplied on every config activation)
+	handler := proxy.NewHandler(router, configLoader.GetConfig(), logger)
+	handler.SetConfigSource(configLoader.GetConfig)
+	configLoader.OnActivate(handler.ApplyConfig)
+	defer handler.Stop()
+
 	// Connect to control plane if configured
+	var dpClient *dataplane.Client
+	var stalenessGuard *dataplane.StalenessGuard
 	if cpURL != "" {
 		// CP mode: only accept updates from control plane
-		dpClient := dataplane.NewClient(cpURL, configLoader)
+		if timeout := os.Getenv("RESOLVE_ENDPOINTS_TIMEOUT"); timeout != "" {
+			resolveTimeout, err := time.ParseDuration(timeout)
+			if err != nil {
+				log.Fatalf("Invalid RESOLVE_ENDPOINTS_TIMEOUT: %v", err)
+			}
+			configLoader.AddPreApplyCheck(config.ResolveNewEndpoints(resolveTimeout))
+		}
+
+		dpClient, err = dataplane.NewClient(cpURL, configLoader)
+		if err != nil {
+			log.Fatalf("Invalid CONTROL_PLANE_URL: %v", err)
+		}
+		identity := dataplane.DefaultIdentity()
+		identity.ID = getEnv("ROUTER_ID", identity.Hostname)
+		labels, err := protocol.ParseLabels(os.Getenv("ROUTER_LABELS"))
+		if err != nil {
+			log.Fatalf("Invalid ROUTER_LABELS: %v", err)
+		}
+		identity.Labels = labels
+		dpClient.SetIdentity(identity)
+		dpClient.SetOverrideApplier(handler)
+		dpClient.SetCacheFile(cacheFile)
+		if verifyKey != nil {
+			dpClient.SetVerifyKey(verifyKey)
+		}
+		pingInterval, pongTimeout, err := protocol.HeartbeatFromEnv()
+		if err != nil {
+			log.Fatalf("Invalid heartbeat settings: %v", err)
+		}
+		dpClient.SetHeartbeat(pingInterval, pongTimeout)
+		if secret := os.Getenv("CONTROL_PLANE_AUTH_SECRET"); secret != "" {
+			dpClient.SetTokenSecret([]byte(secret))
+		}
+		if tlsConfig := controlPlaneTLSConfig(); tlsConfig != nil {
+			dpClient.SetTLSConfig(tlsConfig)
+		}
 		dpClient.Start()
 		defer dpClient.Stop()
 		log.Printf("Connected to control plane at %s - config updates via CP only", cpURL)
+
+		// Act on config staleness if the control plane stays silent
+		threshold, err := time.ParseDuration(getEnv("STALE_CONFIG_THRESHOLD", "5m"))
+		if err != nil {
+			log.Fatalf("Invalid STALE_CONFIG_THRESHOLD: %v", err)
+		}
+		policy, err := dataplane.ParseStalenessPolicy(getEnv("STALE_CONFIG_POLICY", string(dataplane.StalenessWarn)))
+		if err != nil {
+			log.Fatalf("Invalid STALE_CONFIG_POLICY: %v", err)
+		}
+		stalenessGuard = dataplane.NewStalenessGuard(dpClient, threshold, policy)
+		stalenessGuard.Start()
+		defer stalenessGuard.Stop()
 	} else {
 		// File-only mode: watch for file changes
 		configLoader.StartReloadLoop()
@@ -53,19 +142,75 @@ func main() {
 		log.Println("No control plane configured, using file-based config with hot-reload")
 	}
 
-	// Create router with config loader
-	router := routing.NewRouter(configLoader)
+	// Export request spans to an OpenTelemetry collector if configured
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		serviceName := getEnv("OTEL_SERVICE_NAME", "cell-router")
+		exporter := tracing.NewOTLPExporter(tracing.OTLPConfig{
+			Endpoint:    endpoint,
+			ServiceName: serviceName,
+		}, logger)
+		defer exporter.Shutdown()
+		handler.SetTracer(tracing.NewTracer(serviceName, exporter))
+		log.Printf("Exporting traces to %s", endpoint)
+	}
 
-	// Create proxy handler (pass config for resilience mechanisms)
-	handler := proxy.NewHandler(router, configLoader.GetConfig(), logger)
-	defer handler.Stop()
+	// Expose the active config version as an info metric
+	configInfo := handler.Metrics().NewGaugeVec("router_config_info",
+		"Active routing config version and source (always 1).", "version", "source")
+	configReload := handler.Metrics().NewGaugeVec("router_config_last_reload_timestamp_seconds",
+		"Unix time of the last successful config load.")
+	configStaleness := handler.Metrics().NewGaugeVec("router_config_staleness_seconds",
+		"Seconds since the control plane was last heard from (0 in file-only mode).")
+	handler.Metrics().OnScrape(func() {
+		configInfo.Reset()
+		configInfo.Set(1, configLoader.GetConfigVersion(), fmt.Sprint(configLoader.GetConfigSource()))
+		configReload.Set(float64(configLoader.LastReloadTime().Unix()))
+		if dpClient != nil {
+			configStaleness.Set(dpClient.Staleness().Seconds())
+		}
+	})
+
+	// Share rate limit consumption with other routers if configured
+	var rateLimitPeers *limits.PeerBackend
+	if peers := os.Getenv("RATE_LIMIT_PEERS"); peers != "" {
+		rateLimitPeers = newRateLimitPeers(peers, configLoader.GetConfig().RateLimitMaxKeys, logger)
+		handler.LimitsManager().SetRateLimitBackend(rateLimitPeers)
+		rateLimitPeers.Start()
+		defer rateLimitPeers.Stop()
+	}
 
 	// Create debug handler
 	debugHandler := debug.NewHandler(configLoader)
+	if dpClient != nil {
+		debugHandler.SetStalenessProvider(dpClient)
+	}
 
 	// Set up routing
 	mux := http.NewServeMux()
 	mux.Handle("/debug/config", debugHandler)
+	mux.Handle("/debug/limits", debug.NewLimitsHandler(handler.LimitsManager()))
+	mux.Handle("/debug/placements", debug.NewPlacementsHandler(handler))
+	mux.Handle("/debug/routes", debug.NewRoutesHandler(configLoader))
+	mux.Handle("/debug/route", debug.NewRouteHandler(handler))
+	mux.Handle("/metrics", handler.Metrics())
+	if stalenessGuard != nil {
+		mux.Handle("/ready", stalenessGuard)
+	} else {
+		mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"ready"}`))
+		})
+	}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		adminHandler := admin.NewHandler(token, handler)
+		adminHandler.EnableConfigRollback(configLoader)
+		mux.Handle("/admin/", adminHandler)
+	} else {
+		log.Println("ADMIN_TOKEN not set, admin API disabled")
+	}
+	if rateLimitPeers != nil {
+		mux.Handle(limits.PeerSyncPath, rateLimitPeers)
+	}
 	mux.Handle("/", handler)
 
 	// Configure HTTP server
@@ -104,6 +249,73 @@ func main() {
 	log.Println("Server stopped")
 }
 
+// newRateLimitPeers creates a rate limit backend syncing with a comma-separated list of peer URLs
+func newRateLimitPeers(peers string, maxKeys int, logger *logging.Logger) *limits.PeerBackend {
+	syncInterval, err := time.ParseDuration(getEnv("RATE_LIMIT_SYNC_INTERVAL", "200ms"))
+	if err != nil {
+		log.Fatalf("Invalid RATE_LIMIT_SYNC_INTERVAL: %v", err)
+	}
+
+	policy := limits.FailurePolicy(getEnv("RATE_LIMIT_FAILURE_POLICY", string(limits.FailOpen)))
+	if policy != limits.FailOpen && policy != limits.FailClosed {
+		log.Fatalf("Invalid RATE_LIMIT_FAILURE_POLICY: %s", policy)
+	}
+
+	secret := os.Getenv("RATE_LIMIT_PEER_SECRET")
+	if secret == "" {
+		log.Fatal("RATE_LIMIT_PEER_SECRET is required when RATE_LIMIT_PEERS is set")
+	}
+
+	hostname, _ := os.Hostname()
+	var peerURLs []string
+	for _, peer := range strings.Split(peers, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			peerURLs = append(peerURLs, peer)
+		}
+	}
+
+	log.Printf("Sharing rate limits with %d peers (failure policy: %s)", len(peerURLs), policy)
+	return limits.NewPeerBackend(limits.PeerConfig{
+		ID:            getEnv("ROUTER_ID", hostname),
+		Peers:         peerURLs,
+		SyncInterval:  syncInterval,
+		FailurePolicy: policy,
+		MaxKeys:       maxKeys,
+		Secret:        []byte(secret),
+	}, logger)
+}
+
+// controlPlaneTLSConfig builds the TLS settings for a wss:// or https:// control plane from CONTROL_PLANE_CA_FILE
+// and, for mTLS, CONTROL_PLANE_CERT_FILE/CONTROL_PLANE_KEY_FILE; nil when none are set
+func controlPlaneTLSConfig() *tls.Config {
+	caFile := os.Getenv("CONTROL_PLANE_CA_FILE")
+	certFile := os.Getenv("CONTROL_PLANE_CERT_FILE")
+	keyFile := os.Getenv("CONTROL_PLANE_KEY_FILE")
+	if caFile == "" && certFile == "" {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		caPEM, err := os.ReadFile(caFile)
+		if err != nil {
+			log.Fatalf("Failed to read CONTROL_PLANE_CA_FILE: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			log.Fatalf("No certificates found in CONTROL_PLANE_CA_FILE %s", caFile)
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			log.Fatalf("Failed to load control plane client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,51 @@
+// Command sign-config writes detached Ed25519 signatures (<file>.sig) for config files
+//
+// Usage: sign-config -key signing-key.pem config/routing.json [more configs...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+func main() {
+	keyPath := flag.String("key", os.Getenv("CONFIG_SIGNING_KEY_FILE"), "PEM Ed25519 private key (default $CONFIG_SIGNING_KEY_FILE)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s -key signing-key.pem config.json...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *keyPath == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	key, err := config.LoadSigningKey(*keyPath)
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+
+	for _, path := range flag.Args() {
+		cfg, err := config.LoadFromFile(path)
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			log.Fatalf("%s: invalid config: %v", path, err)
+		}
+
+		signature, err := config.SignConfig(key, cfg)
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		if err := config.WriteSignatureFile(path, signature); err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		log.Printf("Signed %s (version %s) -> %s", path, cfg.Version, config.SignatureFile(path))
+	}
+}
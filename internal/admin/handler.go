@@ -0,0 +1,209 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// DefaultOverrideTTL applies when an override request does not set a ttl
+const DefaultOverrideTTL = 15 * time.Minute
+
+// ErrUnknownPlacement is returned by an OverrideApplier for placements missing from the config
+var ErrUnknownPlacement = errors.New("unknown placement")
+
+// OverrideApplier applies operator overrides to circuit breakers and health state
+type OverrideApplier interface {
+	ApplyOverride(override protocol.OverrideMessage) error
+}
+
+// ConfigRollbacker reactivates the previous (last-known-good) config
+type ConfigRollbacker interface {
+	Rollback() (*config.Config, error)
+}
+
+// circuitRequest is the body of a force circuit request
+type circuitRequest struct {
+	State string `json:"state"` // "open" or "closed"
+	TTL   string `json:"ttl"`   // Go duration, defaults to DefaultOverrideTTL
+}
+
+// drainRequest is the body of a drain request
+type drainRequest struct {
+	TTL string `json:"ttl"` // Go duration, defaults to DefaultOverrideTTL
+}
+
+// Handler serves the admin override API behind bearer token authentication
+type Handler struct {
+	applier OverrideApplier
+	mux     *http.ServeMux
+	auth    http.Handler
+}
+
+// NewHandler creates an admin handler that requires the given bearer token
+func NewHandler(token string, applier OverrideApplier) *Handler {
+	h := &Handler{
+		applier: applier,
+		mux:     http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("POST /admin/placements/{placement}/circuit", h.handleForceCircuit)
+	h.mux.HandleFunc("DELETE /admin/placements/{placement}/circuit", h.handleClear(protocol.OverrideClearCircuit))
+	h.mux.HandleFunc("POST /admin/placements/{placement}/drain", h.handleDrain)
+	h.mux.HandleFunc("DELETE /admin/placements/{placement}/drain", h.handleClear(protocol.OverrideUndrain))
+	h.auth = RequireToken(token, h.mux)
+
+	return h
+}
+
+// EnableConfigRollback serves POST /admin/config/rollback, reactivating the previous config locally
+func (h *Handler) EnableConfigRollback(rollbacker ConfigRollbacker) {
+	h.mux.HandleFunc("POST /admin/config/rollback", func(w http.ResponseWriter, r *http.Request) {
+		cfg, err := rollbacker.Rollback()
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, config.ErrNoPreviousConfig) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"version": cfg.Version})
+	})
+}
+
+// ServeHTTP handles /admin/ requests
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.auth.ServeHTTP(w, r)
+}
+
+// RequireToken wraps a handler so it only serves requests bearing the given token
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleForceCircuit forces a placement's circuit breaker open or closed
+func (h *Handler) handleForceCircuit(w http.ResponseWriter, r *http.Request) {
+	var req circuitRequest
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var action protocol.OverrideAction
+	switch req.State {
+	case "open":
+		action = protocol.OverrideForceOpen
+	case "closed":
+		action = protocol.OverrideForceClosed
+	default:
+		http.Error(w, "Bad Request: state must be 'open' or 'closed'", http.StatusBadRequest)
+		return
+	}
+
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.apply(w, protocol.OverrideMessage{
+		Type:      protocol.MessageTypeOverride,
+		Placement: r.PathValue("placement"),
+		Action:    action,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	})
+}
+
+// handleDrain drains a placement so new traffic fails over
+func (h *Handler) handleDrain(w http.ResponseWriter, r *http.Request) {
+	var req drainRequest
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.apply(w, protocol.OverrideMessage{
+		Type:      protocol.MessageTypeOverride,
+		Placement: r.PathValue("placement"),
+		Action:    protocol.OverrideDrain,
+		ExpiresAt: time.Now().Add(ttl).UTC(),
+	})
+}
+
+// handleClear returns a handler removing an override
+func (h *Handler) handleClear(action protocol.OverrideAction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.apply(w, protocol.OverrideMessage{
+			Type:      protocol.MessageTypeOverride,
+			Placement: r.PathValue("placement"),
+			Action:    action,
+		})
+	}
+}
+
+// apply hands an override to the applier and writes the result
+func (h *Handler) apply(w http.ResponseWriter, override protocol.OverrideMessage) {
+	if err := h.applier.ApplyOverride(override); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrUnknownPlacement) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(override)
+}
+
+// decodeBody decodes an optional JSON request body
+func decodeBody(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+	return nil
+}
+
+// parseTTL parses an override ttl, applying DefaultOverrideTTL when empty
+func parseTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return DefaultOverrideTTL, nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl: %w", err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("ttl must be positive")
+	}
+	return d, nil
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// recordingApplier records applied overrides and rejects placements it does not know
+type recordingApplier struct {
+	placements map[string]bool
+	applied    []protocol.OverrideMessage
+}
+
+func (a *recordingApplier) ApplyOverride(override protocol.OverrideMessage) error {
+	if !a.placements[override.Placement] {
+		return fmt.Errorf("%w: %s", ErrUnknownPlacement, override.Placement)
+	}
+	a.applied = append(a.applied, override)
+	return nil
+}
+
+func TestHandler_Overrides(t *testing.T) {
+	applier := &recordingApplier{placements: map[string]bool{"tier1": true}}
+	handler := NewHandler("secret", applier)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		wantStatus int
+		wantAction protocol.OverrideAction
+		wantTTL    time.Duration
+	}{
+		{"missing token", http.MethodPost, "/admin/placements/tier1/circuit", `{"state":"open"}`, "", http.StatusUnauthorized, "", 0},
+		{"wrong token", http.MethodPost, "/admin/placements/tier1/circuit", `{"state":"open"}`, "nope", http.StatusUnauthorized, "", 0},
+		{"force open", http.MethodPost, "/admin/placements/tier1/circuit", `{"state":"open","ttl":"5m"}`, "secret", http.StatusOK, protocol.OverrideForceOpen, 5 * time.Minute},
+		{"force closed default ttl", http.MethodPost, "/admin/placements/tier1/circuit", `{"state":"closed"}`, "secret", http.StatusOK, protocol.OverrideForceClosed, DefaultOverrideTTL},
+		{"invalid state", http.MethodPost, "/admin/placements/tier1/circuit", `{"state":"ajar"}`, "secret", http.StatusBadRequest, "", 0},
+		{"invalid ttl", http.MethodPost, "/admin/placements/tier1/drain", `{"ttl":"-1m"}`, "secret", http.StatusBadRequest, "", 0},
+		{"drain without body", http.MethodPost, "/admin/placements/tier1/drain", "", "secret", http.StatusOK, protocol.OverrideDrain, DefaultOverrideTTL},
+		{"undrain", http.MethodDelete, "/admin/placements/tier1/drain", "", "secret", http.StatusOK, protocol.OverrideUndrain, 0},
+		{"unknown placement", http.MethodDelete, "/admin/placements/nope/circuit", "", "secret", http.StatusNotFound, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := len(applier.applied)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantAction == "" {
+				if len(applier.applied) != applied {
+					t.Errorf("override applied for rejected request")
+				}
+				return
+			}
+
+			override := applier.applied[len(applier.applied)-1]
+			if override.Action != tt.wantAction || override.Placement != "tier1" {
+				t.Errorf("applied %s for %s, want %s for tier1", override.Action, override.Placement, tt.wantAction)
+			}
+			if tt.wantTTL == 0 {
+				if !override.ExpiresAt.IsZero() {
+					t.Errorf("ExpiresAt = %v, want zero", override.ExpiresAt)
+				}
+			} else if ttl := time.Until(override.ExpiresAt); ttl > tt.wantTTL || ttl < tt.wantTTL-time.Minute {
+				t.Errorf("ExpiresAt in %v, want about %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
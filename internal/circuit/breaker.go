@@ -15,6 +15,10 @@ const (
 	StateClosed   State = "closed"
 	StateOpen     State = "open"
 	StateHalfOpen State = "half_open"
+
+	// Forced states are set by operators and take precedence until they expire
+	StateForcedOpen   State = "forced_open"
+	StateForcedClosed State = "forced_closed"
 )
 
 // Config configures circuit breaker behavior
@@ -31,6 +35,8 @@ type Breaker struct {
 	failures        uint32
 	lastStateChange time.Time
 	nextRetryTime   time.Time
+	forcedState     State     // Operator override, empty when not forced
+	forcedUntil     time.Time // When the override expires
 	mu              sync.RWMutex
 	logger          *logging.Logger
 }
@@ -54,6 +60,10 @@ func (b *Breaker) Allow() bool {
 
 	now := time.Now()
 
+	if forced, active := b.activeOverride(now); active {
+		return forced == StateForcedClosed
+	}
+
 	switch b.state {
 	case StateClosed:
 		return true
@@ -74,6 +84,20 @@ func (b *Breaker) Allow() bool {
 	}
 }
 
+// WouldAllow reports whether Allow would let a request through, without changing state
+func (b *Breaker) WouldAllow() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if forced, active := b.activeOverride(time.Now()); active {
+		return forced == StateForcedClosed
+	}
+	if b.state == StateOpen {
+		return time.Now().After(b.nextRetryTime)
+	}
+	return true
+}
+
 // RecordSuccess records a successful request
 func (b *Breaker) RecordSuccess() {
 	b.mu.Lock()
@@ -113,13 +137,61 @@ func (b *Breaker) RecordFailure() {
 	}
 }
 
-// GetState returns the current circuit breaker state
+// GetState returns the current circuit breaker state, reporting operator overrides while active
 func (b *Breaker) GetState() State {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
+	if forced, active := b.activeOverride(time.Now()); active {
+		return forced
+	}
 	return b.state
 }
 
+// ForceOpen rejects all requests until the given time, regardless of failures
+func (b *Breaker) ForceOpen(until time.Time) {
+	b.setOverride(StateForcedOpen, until)
+}
+
+// ForceClosed allows all requests until the given time, regardless of failures
+func (b *Breaker) ForceClosed(until time.Time) {
+	b.setOverride(StateForcedClosed, until)
+}
+
+// ClearOverride returns the breaker to its failure-driven state
+func (b *Breaker) ClearOverride() {
+	b.setOverride("", time.Time{})
+}
+
+// setOverride records an operator override and logs the change
+func (b *Breaker) setOverride(state State, until time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.forcedState = state
+	b.forcedUntil = until
+
+	fields := map[string]interface{}{
+		"placement":        b.placementKey,
+		"underlying_state": b.state,
+		"timestamp":        time.Now().Unix(),
+	}
+	if state == "" {
+		b.logger.LogInfo("circuit breaker override cleared", fields)
+		return
+	}
+	fields["forced_state"] = state
+	fields["forced_until"] = until.Format(time.RFC3339)
+	b.logger.LogInfo(fmt.Sprintf("circuit breaker override set: %s", state), fields)
+}
+
+// activeOverride returns the forced state if an unexpired override is set (must be called with lock held)
+func (b *Breaker) activeOverride(now time.Time) (State, bool) {
+	if b.forcedState == "" || !now.Before(b.forcedUntil) {
+		return "", false
+	}
+	return b.forcedState, true
+}
+
 // GetFailureCount returns the current failure count
 func (b *Breaker) GetFailureCount() uint32 {
 	b.mu.RLock()
@@ -127,6 +199,32 @@ func (b *Breaker) GetFailureCount() uint32 {
 	return b.failures
 }
 
+// BreakerStatus is a point-in-time view of a circuit breaker
+type BreakerStatus struct {
+	State           State      `json:"state"`
+	Failures        uint32     `json:"failures"`
+	LastStateChange time.Time  `json:"last_state_change"`
+	ForcedUntil     *time.Time `json:"forced_until,omitempty"` // Set while an operator override is active
+}
+
+// Status returns the breaker's current state and failure count
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	status := BreakerStatus{
+		State:           b.state,
+		Failures:        b.failures,
+		LastStateChange: b.lastStateChange,
+	}
+	if forced, active := b.activeOverride(time.Now()); active {
+		forcedUntil := b.forcedUntil
+		status.State = forced
+		status.ForcedUntil = &forcedUntil
+	}
+	return status
+}
+
 // transitionTo changes the circuit breaker state (must be called with lock held)
 func (b *Breaker) transitionTo(newState State, reason string) {
 	if b.state == newState {
@@ -189,6 +287,18 @@ func (m *Manager) GetBreaker(placementKey string) *Breaker {
 	return breaker
 }
 
+// Snapshot returns the status of every breaker keyed by placement
+func (m *Manager) Snapshot() map[string]BreakerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := make(map[string]BreakerStatus, len(m.breakers))
+	for placementKey, breaker := range m.breakers {
+		snapshot[placementKey] = breaker.Status()
+	}
+	return snapshot
+}
+
 // RemoveBreaker removes a circuit breaker for a placement key
 func (m *Manager) RemoveBreaker(placementKey string) {
 	m.mu.Lock()
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// ResolveNewEndpoints returns a pre-apply check that every endpoint host not in the active config resolves
+func ResolveNewEndpoints(timeout time.Duration) PreApplyCheck {
+	return resolveNewEndpoints(net.DefaultResolver.LookupHost, timeout)
+}
+
+// resolveNewEndpoints builds the resolve check around a lookup function
+func resolveNewEndpoints(lookupHost func(ctx context.Context, host string) ([]string, error), timeout time.Duration) PreApplyCheck {
+	return func(current, next *Config) error {
+		known := make(map[string]bool)
+		if current != nil {
+			for _, endpoint := range current.GetCellEndpoints() {
+				known[endpoint] = true
+			}
+		}
+
+		for placement, endpoint := range next.GetCellEndpoints() {
+			if known[endpoint] {
+				continue
+			}
+			parsed, err := url.Parse(endpoint)
+			if err != nil {
+				return fmt.Errorf("placement '%s': invalid URL: %w", placement, err)
+			}
+			host := parsed.Hostname()
+			if net.ParseIP(host) != nil {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			_, err = lookupHost(ctx, host)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("placement '%s': endpoint host %s does not resolve: %w", placement, host, err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveNewEndpoints(t *testing.T) {
+	var looked []string
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		looked = append(looked, host)
+		if host == "cell-tier3" {
+			return []string{"10.0.0.3"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	check := resolveNewEndpoints(lookup, time.Second)
+
+	current := testConfig("v1", "tier1")
+	next := testConfig("v2", "tier1")
+	next.CellEndpoints["tier3"] = "http://cell-tier3:9003"
+	next.CellEndpoints["tier4"] = "http://10.0.0.4:9004"
+	if err := check(current, next); err != nil {
+		t.Errorf("check() error = %v, want nil", err)
+	}
+	if len(looked) != 1 || looked[0] != "cell-tier3" {
+		t.Errorf("looked up %v, want only the new hostname cell-tier3", looked)
+	}
+
+	next.CellEndpoints["tier5"] = "http://cell-tier5:9005"
+	if err := check(current, next); err == nil || !strings.Contains(err.Error(), "cell-tier5") {
+		t.Errorf("check() error = %v, want cell-tier5 not resolving", err)
+	}
+}
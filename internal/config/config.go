@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/url"
 	"os"
 	"time"
@@ -66,14 +67,163 @@ func (c *CircuitBreakerConfig) Parse() (*ParsedCircuitBreakerConfig, error) {
 	}, nil
 }
 
+// QueueConfig configures a bounded wait queue in front of a placement's concurrency limit
+type QueueConfig struct {
+	MaxLength int    `json:"max_length"`
+	MaxWait   string `json:"max_wait"`
+	Order     string `json:"order,omitempty"` // fifo (default) or lifo
+}
+
+// ParsedQueueConfig contains parsed duration values
+type ParsedQueueConfig struct {
+	MaxLength int
+	MaxWait   time.Duration
+	Order     string
+}
+
+// Parse converts string duration to time.Duration
+func (q *QueueConfig) Parse() (*ParsedQueueConfig, error) {
+	if q.MaxLength <= 0 {
+		return nil, fmt.Errorf("queue max_length must be positive")
+	}
+
+	maxWait, err := time.ParseDuration(q.MaxWait)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue max_wait: %w", err)
+	}
+
+	order := q.Order
+	if order == "" {
+		order = "fifo"
+	}
+	if order != "fifo" && order != "lifo" {
+		return nil, fmt.Errorf("invalid queue order '%s'", q.Order)
+	}
+
+	return &ParsedQueueConfig{
+		MaxLength: q.MaxLength,
+		MaxWait:   maxWait,
+		Order:     order,
+	}, nil
+}
+
+// AdaptiveConcurrencyConfig configures an adaptive (AIMD) concurrency limit
+type AdaptiveConcurrencyConfig struct {
+	MinLimit         int     `json:"min_limit"`
+	MaxLimit         int     `json:"max_limit"`
+	LatencyTolerance float64 `json:"latency_tolerance,omitempty"` // Multiple of baseline latency treated as congestion
+	BackoffRatio     float64 `json:"backoff_ratio,omitempty"`     // Multiplicative decrease on congestion
+}
+
+// Validate checks the adaptive limit bounds
+func (a *AdaptiveConcurrencyConfig) Validate() error {
+	if a.MinLimit <= 0 {
+		return fmt.Errorf("adaptive_concurrency min_limit must be positive")
+	}
+	if a.MaxLimit < a.MinLimit {
+		return fmt.Errorf("adaptive_concurrency max_limit must be at least min_limit")
+	}
+	if a.LatencyTolerance != 0 && a.LatencyTolerance <= 1 {
+		return fmt.Errorf("adaptive_concurrency latency_tolerance must be greater than 1")
+	}
+	if a.BackoffRatio != 0 && (a.BackoffRatio <= 0 || a.BackoffRatio >= 1) {
+		return fmt.Errorf("adaptive_concurrency backoff_ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// RateLimitRuleConfig configures a token bucket
+type RateLimitRuleConfig struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst,omitempty"`
+}
+
+// EffectiveBurst returns the bucket size, defaulting to one second of traffic
+func (r *RateLimitRuleConfig) EffectiveBurst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return int(math.Ceil(r.RequestsPerSecond))
+}
+
+// validate checks the rule has a positive rate
+func (r *RateLimitRuleConfig) validate() error {
+	if r.RequestsPerSecond <= 0 {
+		return fmt.Errorf("requests_per_second must be positive")
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("burst must be non-negative")
+	}
+	return nil
+}
+
+// Rate limit keying modes
+const (
+	RateLimitKeyRoutingKey          = "routing_key"
+	RateLimitKeyPlacement           = "placement"
+	RateLimitKeyRoutingKeyPlacement = "routing_key_placement"
+)
+
+// RateLimitConfig configures token-bucket rate limiting for a placement
+type RateLimitConfig struct {
+	Key               string                          `json:"key,omitempty"` // routing_key (default), placement, routing_key_placement
+	RequestsPerSecond float64                         `json:"requests_per_second"`
+	Burst             int                             `json:"burst,omitempty"`
+	Overrides         map[string]*RateLimitRuleConfig `json:"overrides,omitempty"` // Per routing key
+}
+
+// DefaultRule returns the rule applied to routing keys without an override
+func (r *RateLimitConfig) DefaultRule() *RateLimitRuleConfig {
+	return &RateLimitRuleConfig{
+		RequestsPerSecond: r.RequestsPerSecond,
+		Burst:             r.Burst,
+	}
+}
+
+// Validate checks the rate limit keying mode and rules
+func (r *RateLimitConfig) Validate() error {
+	switch r.Key {
+	case "", RateLimitKeyRoutingKey, RateLimitKeyPlacement, RateLimitKeyRoutingKeyPlacement:
+	default:
+		return fmt.Errorf("invalid rate limit key '%s'", r.Key)
+	}
+
+	if err := r.DefaultRule().validate(); err != nil {
+		return fmt.Errorf("invalid rate limit: %w", err)
+	}
+
+	// A placement-keyed bucket is shared by every tenant, so a per-tenant rule has nothing to apply to
+	if r.Key == RateLimitKeyPlacement && len(r.Overrides) > 0 {
+		return fmt.Errorf("rate limit overrides cannot be combined with key '%s'", RateLimitKeyPlacement)
+	}
+
+	for routingKey, override := range r.Overrides {
+		if override == nil {
+			return fmt.Errorf("invalid rate limit override for '%s': empty rule", routingKey)
+		}
+		if err := override.validate(); err != nil {
+			return fmt.Errorf("invalid rate limit override for '%s': %w", routingKey, err)
+		}
+	}
+	return nil
+}
+
 // PlacementConfig contains resilience configuration for a placement
 type PlacementConfig struct {
-	URL                 string                `json:"url"`
-	Fallback            string                `json:"fallback,omitempty"`
-	HealthCheck         *HealthCheckConfig    `json:"health_check,omitempty"`
-	CircuitBreaker      *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
-	ConcurrencyLimit    int                   `json:"concurrency_limit,omitempty"`
-	MaxRequestBodyBytes int64                 `json:"max_request_body_bytes,omitempty"`
+	URL                 string                     `json:"url"`
+	Fallback            string                     `json:"fallback,omitempty"`
+	HealthCheck         *HealthCheckConfig         `json:"health_check,omitempty"`
+	CircuitBreaker      *CircuitBreakerConfig      `json:"circuit_breaker,omitempty"`
+	ConcurrencyLimit    int                        `json:"concurrency_limit,omitempty"`
+	Queue               *QueueConfig               `json:"queue,omitempty"`
+	AdaptiveConcurrency *AdaptiveConcurrencyConfig `json:"adaptive_concurrency,omitempty"`
+	MaxRequestBodyBytes int64                      `json:"max_request_body_bytes,omitempty"`
+	RateLimit           *RateLimitConfig           `json:"rate_limit,omitempty"`
+
+	TenantConcurrencyLimit     int            `json:"tenant_concurrency_limit,omitempty"`     // Default per routing key
+	TenantConcurrencyOverrides map[string]int `json:"tenant_concurrency_overrides,omitempty"` // Per routing key
+
+	Labels map[string]string `json:"labels,omitempty"` // Matched against view selectors, e.g. {"region": "eu"}
 }
 
 // Config represents the routing configuration
@@ -83,6 +233,8 @@ type Config struct {
 	CellEndpoints    map[string]string           `json:"cellEndpoints,omitempty"` // Legacy format
 	Placements       map[string]*PlacementConfig `json:"placements,omitempty"`    // New format
 	DefaultPlacement string                      `json:"defaultPlacement"`
+	RateLimitMaxKeys int                         `json:"rateLimitMaxKeys,omitempty"` // Bound on tracked token buckets
+	Views            map[string]*ViewConfig      `json:"views,omitempty"`            // Per-label subsets sent to data planes
 }
 
 // GetVersion returns the config version
@@ -125,26 +277,55 @@ func (c *Config) GetPlacementConfig(placementKey string) (*PlacementConfig, bool
 	return placement, exists
 }
 
-// LoadFromFile reads and parses a config file
+// Clone returns a deep copy of the config
+func (c *Config) Clone() (*Config, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+
+	var clone Config
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	return &clone, nil
+}
+
+// LoadFromFile reads and parses a config file in the format its extension names (see ParseConfig)
 func LoadFromFile(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	cfg, err := ParseConfig(data, FormatForPath(path))
+	if err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
-	return &cfg, nil
+	return cfg, nil
+}
+
+// SaveToFile atomically writes a config file, so readers never see a partial config
+func SaveToFile(path string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
 }
 
 // Validate checks if the config is valid
+// Errors are FieldErrors naming the JSON path of the offending field
 func (c *Config) Validate() error {
 	// Version must be present
 	if c.Version == "" {
-		return fmt.Errorf("version must be non-empty")
+		return fieldErrorf("$.version", "version must be non-empty")
 	}
 
 	// Get endpoints (supports both formats)
@@ -152,47 +333,98 @@ func (c *Config) Validate() error {
 
 	// DefaultPlacement must exist in endpoints
 	if _, exists := endpoints[c.DefaultPlacement]; !exists {
-		return fmt.Errorf("defaultPlacement '%s' not found in endpoints", c.DefaultPlacement)
+		return fieldErrorf("$.defaultPlacement", "defaultPlacement '%s' not found in endpoints", c.DefaultPlacement)
 	}
 
 	// All placements in routingTable must exist in endpoints
-	for routingKey, placementKey := range c.RoutingTable {
-		if _, exists := endpoints[placementKey]; !exists {
-			return fmt.Errorf("routingTable[%s] references unknown placement '%s'", routingKey, placementKey)
+	for _, routingKey := range sortedKeys(c.RoutingTable) {
+		if placementKey := c.RoutingTable[routingKey]; !hasKey(endpoints, placementKey) {
+			return fieldErrorf(jsonPath("$.routingTable", routingKey), "routingTable[%s] references unknown placement '%s'", routingKey, placementKey)
 		}
 	}
 
 	// All endpoint URLs must be valid
-	for placement, endpointURL := range endpoints {
-		if _, err := url.Parse(endpointURL); err != nil {
-			return fmt.Errorf("invalid URL for placement '%s': %w", placement, err)
+	for _, placement := range sortedKeys(endpoints) {
+		if _, err := url.Parse(endpoints[placement]); err != nil {
+			path := jsonPath("$.placements", placement, "url")
+			if len(c.CellEndpoints) > 0 {
+				path = jsonPath("$.cellEndpoints", placement)
+			}
+			return &FieldError{Path: path, Err: fmt.Errorf("invalid URL for placement '%s': %w", placement, err)}
 		}
 	}
 
-	// Validate fallback references
-	if c.Placements != nil {
-		for placementKey, placement := range c.Placements {
-			if placement.Fallback != "" {
-				if _, exists := endpoints[placement.Fallback]; !exists {
-					return fmt.Errorf("placement '%s' references unknown fallback '%s'", placementKey, placement.Fallback)
-				}
-			}
+	// Validate placement settings
+	for _, placementKey := range sortedKeys(c.Placements) {
+		if err := c.Placements[placementKey].validate(jsonPath("$.placements", placementKey), endpoints); err != nil {
+			return err
+		}
+	}
 
-			// Validate health check config
-			if placement.HealthCheck != nil {
-				if _, err := placement.HealthCheck.Parse(); err != nil {
-					return fmt.Errorf("placement '%s': %w", placementKey, err)
-				}
-			}
+	// Every view must route unknown tenants somewhere inside it
+	return c.validateViews(endpoints)
+}
 
-			// Validate circuit breaker config
-			if placement.CircuitBreaker != nil {
-				if _, err := placement.CircuitBreaker.Parse(); err != nil {
-					return fmt.Errorf("placement '%s': %w", placementKey, err)
-				}
-			}
+// validate checks a placement's settings, reporting errors under the placement's JSON path
+func (p *PlacementConfig) validate(path string, endpoints map[string]string) error {
+	if p == nil {
+		return fieldErrorf(path, "placement must not be null")
+	}
+
+	// Validate fallback reference
+	if p.Fallback != "" && !hasKey(endpoints, p.Fallback) {
+		return fieldErrorf(jsonPath(path, "fallback"), "unknown fallback '%s'", p.Fallback)
+	}
+
+	// Validate health check config
+	if p.HealthCheck != nil {
+		if _, err := p.HealthCheck.Parse(); err != nil {
+			return &FieldError{Path: jsonPath(path, "health_check"), Err: err}
+		}
+	}
+
+	// Validate circuit breaker config
+	if p.CircuitBreaker != nil {
+		if _, err := p.CircuitBreaker.Parse(); err != nil {
+			return &FieldError{Path: jsonPath(path, "circuit_breaker"), Err: err}
+		}
+	}
+
+	// Validate queue config
+	if p.Queue != nil {
+		if _, err := p.Queue.Parse(); err != nil {
+			return &FieldError{Path: jsonPath(path, "queue"), Err: err}
+		}
+	}
+
+	// Validate adaptive concurrency config
+	if p.AdaptiveConcurrency != nil {
+		if err := p.AdaptiveConcurrency.Validate(); err != nil {
+			return &FieldError{Path: jsonPath(path, "adaptive_concurrency"), Err: err}
+		}
+	}
+
+	// Validate tenant concurrency quotas
+	if p.TenantConcurrencyLimit < 0 {
+		return fieldErrorf(jsonPath(path, "tenant_concurrency_limit"), "tenant_concurrency_limit must be non-negative")
+	}
+	for _, routingKey := range sortedKeys(p.TenantConcurrencyOverrides) {
+		if p.TenantConcurrencyOverrides[routingKey] <= 0 {
+			return fieldErrorf(jsonPath(path, "tenant_concurrency_overrides", routingKey), "tenant_concurrency_overrides[%s] must be positive", routingKey)
 		}
 	}
 
+	// Validate rate limit config
+	if p.RateLimit != nil {
+		if err := p.RateLimit.Validate(); err != nil {
+			return &FieldError{Path: jsonPath(path, "rate_limit"), Err: err}
+		}
+	}
 	return nil
 }
+
+// hasKey reports whether a map has a key
+func hasKey[V any](m map[string]V, key string) bool {
+	_, exists := m[key]
+	return exists
+}
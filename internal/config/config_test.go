@@ -157,3 +157,40 @@ func TestValidate_InvalidURL(t *testing.T) {
 		t.Errorf("Error should mention 'invalid URL', got: %v", err)
 	}
 }
+
+func TestValidate_RateLimit(t *testing.T) {
+	cfg := &Config{
+		Version:      "v1",
+		RoutingTable: map[string]string{"acme": "tier1"},
+		Placements: map[string]*PlacementConfig{
+			"tier1": {
+				URL: "http://cell-tier1:9001",
+				RateLimit: &RateLimitConfig{
+					Key:               RateLimitKeyRoutingKey,
+					RequestsPerSecond: 10,
+					Overrides: map[string]*RateLimitRuleConfig{
+						"acme": {RequestsPerSecond: 0},
+					},
+				},
+			},
+		},
+		DefaultPlacement: "tier1",
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected error for zero-rate override, got nil")
+	}
+	if !strings.Contains(err.Error(), "acme") {
+		t.Errorf("Error should mention 'acme', got: %v", err)
+	}
+
+	cfg.Placements["tier1"].RateLimit.Overrides["acme"].RequestsPerSecond = 50
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() failed: %v", err)
+	}
+	cfg.Placements["tier1"].RateLimit.Key = RateLimitKeyPlacement
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "overrides cannot be combined") {
+		t.Errorf("Validate() = %v, want error rejecting overrides with placement keying", err)
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Change is a single difference between two configs
+// Path is the dotted JSON path of the changed field (e.g. "routingTable.acme")
+type Change struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from,omitempty"` // Absent when the field was added
+	To   interface{} `json:"to,omitempty"`   // Absent when the field was removed
+}
+
+// Diff returns the changes from one config to another, sorted by path
+// The version field is excluded since it differs between any two versions
+func Diff(from, to *Config) ([]Change, error) {
+	fromTree, err := jsonTree(from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := jsonTree(to)
+	if err != nil {
+		return nil, err
+	}
+	delete(fromTree, "version")
+	delete(toTree, "version")
+
+	changes := []Change{}
+	diffValues("", fromTree, toTree, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// diffValues appends the differences between two decoded JSON values
+// Objects are compared key by key; any other differing values are reported whole
+func diffValues(path string, from, to interface{}, changes *[]Change) {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if !fromIsMap || !toIsMap {
+		if !reflect.DeepEqual(from, to) {
+			*changes = append(*changes, Change{Path: path, From: from, To: to})
+		}
+		return
+	}
+
+	for key, fromValue := range fromMap {
+		diffValues(joinPath(path, key), fromValue, toMap[key], changes)
+	}
+	for key, toValue := range toMap {
+		if _, exists := fromMap[key]; !exists {
+			diffValues(joinPath(path, key), nil, toValue, changes)
+		}
+	}
+}
+
+// jsonTree decodes a config's JSON encoding into generic maps
+func jsonTree(cfg *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var tree map[string]interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	return tree, nil
+}
+
+// joinPath appends a key to a dotted path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
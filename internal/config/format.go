@@ -0,0 +1,308 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Format is the syntax a config file is written in
+type Format string
+
+// Supported config formats
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// FormatForPath returns the format a file or URL path's extension names, defaulting to JSON
+func FormatForPath(name string) Format {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// formatForContentType returns the format a Content-Type names, or an empty string if it names none
+func formatForContentType(contentType string) Format {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "application/json":
+		return FormatJSON
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return FormatYAML
+	case "application/toml", "text/toml":
+		return FormatTOML
+	}
+	return ""
+}
+
+// FieldError is a config error at a JSON path, e.g. $.placements.tier1.health_check
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+// Error returns the path followed by the error
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldErrorf creates a FieldError with a formatted message
+func fieldErrorf(path, format string, args ...interface{}) error {
+	return &FieldError{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// jsonPath appends keys to a JSON path, quoting keys that are not identifiers ($.placements["eu-tier1"])
+func jsonPath(base string, keys ...string) string {
+	for _, key := range keys {
+		if identifierPattern.MatchString(key) {
+			base += "." + key
+		} else {
+			quoted, _ := json.Marshal(key)
+			base += "[" + string(quoted) + "]"
+		}
+	}
+	return base
+}
+
+// ParseConfig decodes a config written in the given format
+// Decoding is strict: unknown fields and values of the wrong type are rejected with their JSON path,
+// so a typo like "helth_check" fails the load instead of silently dropping the health check
+func ParseConfig(data []byte, format Format) (*Config, error) {
+	switch format {
+	case FormatYAML, FormatTOML:
+		var tree interface{}
+		var err error
+		if format == FormatYAML {
+			tree, err = parseYAML(data)
+		} else {
+			tree, err = parseTOML(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(tree); err != nil {
+			return nil, fmt.Errorf("failed to convert %s config: %w", format, err)
+		}
+	case FormatJSON, "":
+	default:
+		return nil, fmt.Errorf("unsupported config format '%s'", format)
+	}
+
+	var cfg Config
+	if err := decodeStrict(data, &cfg, "config object"); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ParsePlacement decodes a JSON placement config as strictly as ParseConfig
+// Errors are FieldErrors naming the JSON path from the placement, e.g. $.helth_check
+func ParsePlacement(data []byte) (*PlacementConfig, error) {
+	var placement PlacementConfig
+	if err := decodeStrict(data, &placement, "placement object"); err != nil {
+		return nil, err
+	}
+	return &placement, nil
+}
+
+// DecodeStrict decodes a single JSON value into v, which must be a pointer
+// Unknown fields and values of the wrong type are rejected with a FieldError naming their JSON path
+func DecodeStrict(data []byte, v interface{}) error {
+	return decodeStrict(data, v, "JSON value")
+}
+
+// decodeStrict is DecodeStrict naming what was decoded when data follows it
+func decodeStrict(data []byte, v interface{}, what string) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after the %s", what)
+	}
+	if err := checkFields("$", tree, reflect.TypeOf(v).Elem()); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// checkFields checks a decoded JSON value against the Go type it will be decoded into
+// Null is accepted anywhere, as encoding/json leaves the field unset
+func checkFields(path string, value interface{}, t reflect.Type) error {
+	if value == nil {
+		return nil
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(path, "object", value)
+		}
+		fields := make(map[string]reflect.Type)
+		for _, field := range jsonFields(t) {
+			fields[field.name] = field.typ
+		}
+		for _, key := range sortedKeys(object) {
+			fieldType, exists := fields[key]
+			if !exists {
+				return &FieldError{Path: jsonPath(path, key), Err: unknownFieldError(key, fields)}
+			}
+			if err := checkFields(jsonPath(path, key), object[key], fieldType); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(path, "object", value)
+		}
+		for _, key := range sortedKeys(object) {
+			if err := checkFields(jsonPath(path, key), object[key], t.Elem()); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			return typeError(path, "string", value)
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			return typeError(path, "boolean", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, ok := value.(json.Number)
+		if !ok {
+			return typeError(path, "integer", value)
+		}
+		if _, err := number.Int64(); err != nil {
+			return fieldErrorf(path, "expected integer, got %s", number)
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			return typeError(path, "number", value)
+		}
+	}
+	return nil
+}
+
+// typeError reports a value of the wrong JSON type
+func typeError(path, want string, value interface{}) error {
+	got := "string"
+	switch value.(type) {
+	case map[string]interface{}:
+		got = "object"
+	case []interface{}:
+		got = "array"
+	case json.Number:
+		got = "number"
+	case bool:
+		got = "boolean"
+	}
+	return fieldErrorf(path, "expected %s, got %s", want, got)
+}
+
+// unknownFieldError reports an unknown field, suggesting a known field with a similar name
+func unknownFieldError(key string, fields map[string]reflect.Type) error {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if distance := editDistance(key, name); distance < bestDistance || (distance == bestDistance && name < best) {
+			best, bestDistance = name, distance
+		}
+	}
+	if best != "" {
+		return fmt.Errorf("unknown field (did you mean %q?)", best)
+	}
+	return errors.New("unknown field")
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// jsonField is a struct field as encoding/json sees it
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitempty bool
+}
+
+// jsonFields returns the JSON-encoded fields of a struct type in declaration order
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{
+			name:      name,
+			typ:       field.Type,
+			omitempty: strings.Contains(","+options+",", ",omitempty,"),
+		})
+	}
+	return fields
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
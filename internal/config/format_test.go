@@ -0,0 +1,254 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const formatJSON = `{
+  "version": "1.2.0",
+  "routingTable": {"acme": "eu-tier1", "globex": "shared"},
+  "placements": {
+    "eu-tier1": {
+      "url": "http://eu-tier1:9001",
+      "fallback": "shared",
+      "health_check": {"path": "/health", "interval": "5s", "timeout": "1s"},
+      "concurrency_limit": 100,
+      "rate_limit": {"requests_per_second": 2.5, "overrides": {"acme": {"requests_per_second": 10}}},
+      "labels": {"region": "eu"}
+    },
+    "shared": {"url": "http://shared:9002"}
+  },
+  "defaultPlacement": "shared"
+}`
+
+const formatYAML = `# Routing config
+version: "1.2.0"
+routingTable:
+  acme: eu-tier1
+  globex: shared   # Shared cell
+placements:
+  eu-tier1:
+    url: http://eu-tier1:9001
+    fallback: shared
+    health_check:
+      path: /health
+      interval: 5s
+      timeout: '1s'
+    concurrency_limit: 100
+    rate_limit:
+      requests_per_second: 2.5
+      overrides:
+        acme: {requests_per_second: 10}
+    labels: {region: eu}
+  shared:
+    url: "http://shared:9002"
+defaultPlacement: shared
+`
+
+const formatTOML = `# Routing config
+version = "1.2.0"
+defaultPlacement = "shared"
+
+[routingTable]
+acme = "eu-tier1"
+globex = "shared" # Shared cell
+
+[placements.eu-tier1]
+url = "http://eu-tier1:9001"
+fallback = "shared"
+health_check = { path = "/health", interval = "5s", timeout = '1s' }
+concurrency_limit = 100
+labels.region = "eu"
+
+[placements.eu-tier1.rate_limit]
+requests_per_second = 2.5
+overrides = { acme = { requests_per_second = 10 } }
+
+[placements.shared]
+url = "http://shared:9002"
+`
+
+func TestParseConfig_Formats(t *testing.T) {
+	want, err := ParseConfig([]byte(formatJSON), FormatJSON)
+	if err != nil {
+		t.Fatalf("ParseConfig(json) failed: %v", err)
+	}
+	if err := want.Validate(); err != nil {
+		t.Fatalf("config is invalid: %v", err)
+	}
+
+	for format, data := range map[Format]string{FormatYAML: formatYAML, FormatTOML: formatTOML} {
+		t.Run(string(format), func(t *testing.T) {
+			cfg, err := ParseConfig([]byte(data), format)
+			if err != nil {
+				t.Fatalf("ParseConfig failed: %v", err)
+			}
+			if !reflect.DeepEqual(cfg, want) {
+				got, _ := Diff(want, cfg)
+				t.Errorf("%s config differs from JSON: %+v", format, got)
+			}
+		})
+	}
+
+	// JSON is valid YAML, so a JSON document parses as YAML too
+	cfg, err := ParseConfig([]byte(formatJSON), FormatYAML)
+	if err != nil || !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ParseConfig(json as yaml) = %+v, %v; want the JSON config", cfg, err)
+	}
+}
+
+func TestParseConfig_Strict(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "misspelled field",
+			format:  FormatJSON,
+			data:    `{"version": "v1", "placements": {"tier1": {"url": "http://tier1", "helth_check": {}}}}`,
+			wantErr: `$.placements.tier1.helth_check: unknown field (did you mean "health_check"?)`,
+		},
+		{
+			name:    "unknown top-level field",
+			format:  FormatJSON,
+			data:    `{"version": "v1", "tenants": {}}`,
+			wantErr: `$.tenants: unknown field`,
+		},
+		{
+			name:    "wrong type",
+			format:  FormatJSON,
+			data:    `{"version": "v1", "placements": {"eu-tier1": {"url": "http://eu", "concurrency_limit": "10"}}}`,
+			wantErr: `$.placements["eu-tier1"].concurrency_limit: expected integer, got string`,
+		},
+		{
+			name:    "fractional integer",
+			format:  FormatJSON,
+			data:    `{"version": "v1", "rateLimitMaxKeys": 1.5}`,
+			wantErr: `$.rateLimitMaxKeys: expected integer, got 1.5`,
+		},
+		{
+			name:    "unquoted YAML version",
+			format:  FormatYAML,
+			data:    "version: 1.0\n",
+			wantErr: `$.version: expected string, got number`,
+		},
+		{
+			name:    "YAML typo",
+			format:  FormatYAML,
+			data:    "version: v1\nplacements:\n  tier1:\n    url: http://tier1\n    circuit_braker:\n      timeout: 5s\n",
+			wantErr: `$.placements.tier1.circuit_braker: unknown field (did you mean "circuit_breaker"?)`,
+		},
+		{
+			name:    "TOML typo",
+			format:  FormatTOML,
+			data:    "version = \"v1\"\n[placements.tier1]\nurl = \"http://tier1\"\n[placements.tier1.queue]\nmax_lenght = 10\n",
+			wantErr: `$.placements.tier1.queue.max_lenght: unknown field (did you mean "max_length"?)`,
+		},
+		{
+			name:    "trailing data",
+			format:  FormatJSON,
+			data:    `{"version": "v1"} {}`,
+			wantErr: "unexpected data after the config object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.data), tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseConfig() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseConfig_SyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		data    string
+		wantErr string
+	}{
+		{name: "YAML bad indentation", format: FormatYAML, data: "version: v1\n  routingTable: {}\n", wantErr: "yaml: line 2: unexpected indentation"},
+		{name: "YAML duplicate key", format: FormatYAML, data: "version: v1\nversion: v2\n", wantErr: `yaml: line 2: duplicate key "version"`},
+		{name: "YAML missing colon", format: FormatYAML, data: "version v1\n", wantErr: "yaml: line 1: expected \"key: value\""},
+		{name: "YAML anchor", format: FormatYAML, data: "placements:\n  tier1: &base\n    url: http://tier1\n", wantErr: "yaml: line 2: anchors and aliases are not supported"},
+		{name: "YAML tab indentation", format: FormatYAML, data: "routingTable:\n\tacme: tier1\n", wantErr: "yaml: line 2: tabs are not allowed"},
+		{name: "YAML unterminated flow", format: FormatYAML, data: "labels: {region: eu\n", wantErr: "yaml: line 1: unterminated flow collection"},
+		{name: "TOML duplicate key", format: FormatTOML, data: "version = \"v1\"\nversion = \"v2\"\n", wantErr: `toml: line 2: key "version" is defined twice`},
+		{name: "TOML duplicate table", format: FormatTOML, data: "[routingTable]\n[routingTable]\n", wantErr: "toml: line 2: table [routingTable] is defined twice"},
+		{name: "TOML unquoted string", format: FormatTOML, data: "version = v1\n", wantErr: `toml: line 1: invalid value "v1" (strings must be quoted)`},
+		{name: "TOML array of tables", format: FormatTOML, data: "[[placements]]\n", wantErr: "toml: line 1: arrays of tables are not supported"},
+		{name: "TOML date", format: FormatTOML, data: "version = 2024-01-02\n", wantErr: "toml: line 1: dates and times are not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.data), tt.format)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseConfig() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_FieldPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(cfg *Config)
+		wantPath string
+	}{
+		{name: "unknown placement", change: func(cfg *Config) { cfg.RoutingTable["acme"] = "tier9" }, wantPath: "$.routingTable.acme"},
+		{name: "unknown fallback", change: func(cfg *Config) { cfg.Placements["eu-tier1"].Fallback = "tier9" }, wantPath: `$.placements["eu-tier1"].fallback`},
+		{name: "bad health check", change: func(cfg *Config) { cfg.Placements["eu-tier1"].HealthCheck.Interval = "soon" }, wantPath: `$.placements["eu-tier1"].health_check`},
+		{name: "bad rate limit", change: func(cfg *Config) { cfg.Placements["eu-tier1"].RateLimit.RequestsPerSecond = 0 }, wantPath: `$.placements["eu-tier1"].rate_limit`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseConfig([]byte(formatJSON), FormatJSON)
+			if err != nil {
+				t.Fatalf("ParseConfig failed: %v", err)
+			}
+			tt.change(cfg)
+
+			var fieldErr *FieldError
+			if err := cfg.Validate(); !errors.As(err, &fieldErr) || fieldErr.Path != tt.wantPath {
+				t.Errorf("Validate() = %v, want a field error at %s", err, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema()
+	if err != nil {
+		t.Fatalf("Schema failed: %v", err)
+	}
+	published, err := os.ReadFile("../../config/routing.schema.json")
+	if err != nil {
+		t.Fatalf("Failed to read published schema: %v", err)
+	}
+	if !bytes.Equal(schema, published) {
+		t.Error("config/routing.schema.json is out of date; run go generate ./internal/config")
+	}
+
+	// The shipped configs must pass strict decoding
+	paths, _ := filepath.Glob("../../config/*.json")
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".schema.json") {
+			continue
+		}
+		if _, err := LoadFromFile(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHistoryLimit is the number of configs a history keeps when no limit is given
+const DefaultHistoryLimit = 20
+
+// HistoryEntry describes one accepted config version
+type HistoryEntry struct {
+	Version    string    `json:"version"`
+	Checksum   string    `json:"checksum"` // SHA256 of the config's JSON encoding
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// historyRecord is the on-disk form of a history entry
+type historyRecord struct {
+	HistoryEntry
+	Config *Config `json:"config"`
+}
+
+// History keeps the last accepted configs in a directory, one file per version
+type History struct {
+	dir     string
+	limit   int
+	mu      sync.Mutex
+	records []historyRecord // Oldest first
+	files   []string        // File name per record
+}
+
+// NewHistory opens (creating if needed) a history directory keeping at most limit configs
+func NewHistory(dir string, limit int) (*History, error) {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	h := &History{
+		dir:   dir,
+		limit: limit,
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names) // Names start with the record time, so this is oldest first
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history entry: %w", err)
+		}
+		var record historyRecord
+		if err := json.Unmarshal(data, &record); err != nil || record.Config == nil {
+			continue // Skip partial or foreign files
+		}
+		h.records = append(h.records, record)
+		h.files = append(h.files, filepath.Base(name))
+	}
+
+	return h, nil
+}
+
+// Record stores cfg unless it matches the latest entry, pruning the oldest beyond the limit
+func (h *History) Record(cfg *Config) (HistoryEntry, error) {
+	checksum, err := Checksum(cfg)
+	if err != nil {
+		return HistoryEntry{}, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n := len(h.records); n > 0 && h.records[n-1].Version == cfg.Version && h.records[n-1].Checksum == checksum {
+		return h.records[n-1].HistoryEntry, nil
+	}
+
+	record := historyRecord{
+		HistoryEntry: HistoryEntry{
+			Version:    cfg.Version,
+			Checksum:   checksum,
+			RecordedAt: time.Now().UTC(),
+		},
+		Config: cfg,
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return HistoryEntry{}, fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	name := fmt.Sprintf("%020d-%s.json", record.RecordedAt.UnixNano(), fileSafe(cfg.Version))
+	if err := writeFileAtomic(filepath.Join(h.dir, name), data); err != nil {
+		return HistoryEntry{}, fmt.Errorf("failed to write history entry: %w", err)
+	}
+	h.records = append(h.records, record)
+	h.files = append(h.files, name)
+
+	for len(h.records) > h.limit {
+		os.Remove(filepath.Join(h.dir, h.files[0]))
+		h.records = h.records[1:]
+		h.files = h.files[1:]
+	}
+	return record.HistoryEntry, nil
+}
+
+// Entries returns the recorded versions, newest first
+func (h *History) Entries() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := make([]HistoryEntry, 0, len(h.records))
+	for i := len(h.records) - 1; i >= 0; i-- {
+		entries = append(entries, h.records[i].HistoryEntry)
+	}
+	return entries
+}
+
+// Get returns the most recent config recorded with the given version
+func (h *History) Get(version string) (*Config, HistoryEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].Version == version {
+			return h.records[i].Config, h.records[i].HistoryEntry, true
+		}
+	}
+	return nil, HistoryEntry{}, false
+}
+
+// Checksum returns the SHA256 of a config's JSON encoding
+func Checksum(cfg *Config) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal config: %w", err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// fileSafe replaces characters that are not safe in file names
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func testConfig(version, acmePlacement string) *Config {
+	return &Config{
+		Version:          version,
+		RoutingTable:     map[string]string{"acme": acmePlacement},
+		CellEndpoints:    map[string]string{"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		DefaultPlacement: "tier1",
+	}
+}
+
+func TestHistory_RecordAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	history, err := NewHistory(dir, 3)
+	if err != nil {
+		t.Fatalf("NewHistory failed: %v", err)
+	}
+
+	for i := 1; i <= 4; i++ {
+		if _, err := history.Record(testConfig(fmt.Sprintf("v%d", i), "tier1")); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	// Re-recording the latest version is a no-op
+	if _, err := history.Record(testConfig("v4", "tier1")); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	reopened, err := NewHistory(dir, 3)
+	if err != nil {
+		t.Fatalf("NewHistory failed: %v", err)
+	}
+	entries := reopened.Entries()
+	if len(entries) != 3 || entries[0].Version != "v4" || entries[2].Version != "v2" {
+		t.Fatalf("Entries() = %+v, want v4, v3, v2", entries)
+	}
+	if _, _, exists := reopened.Get("v1"); exists {
+		t.Error("Get(v1) found a pruned version")
+	}
+
+	cfg, entry, exists := reopened.Get("v3")
+	if !exists || cfg.Version != "v3" {
+		t.Fatalf("Get(v3) = %+v, %v", cfg, exists)
+	}
+	if checksum, _ := Checksum(cfg); checksum != entry.Checksum {
+		t.Errorf("checksum = %s, want %s", entry.Checksum, checksum)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := testConfig("v1", "tier1")
+	to := testConfig("v2", "tier2")
+	to.RoutingTable["globex"] = "tier1"
+	delete(to.CellEndpoints, "tier1")
+
+	changes, err := Diff(from, to)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	want := []Change{
+		{Path: "cellEndpoints.tier1", From: "http://cell-tier1:9001"},
+		{Path: "routingTable.acme", From: "tier1", To: "tier2"},
+		{Path: "routingTable.globex", To: "tier1"},
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("Diff() = %+v, want %+v", changes, want)
+	}
+}
+
+func TestLoader_Rollback(t *testing.T) {
+	loader := NewLoader(t.TempDir()+"/config.json", 0)
+
+	if err := loader.ApplyConfig(testConfig("v1", "tier1")); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if _, err := loader.Rollback(); !errors.Is(err, ErrNoPreviousConfig) {
+		t.Fatalf("Rollback() with no previous config error = %v, want ErrNoPreviousConfig", err)
+	}
+
+	if err := loader.ApplyConfig(testConfig("v2", "tier2")); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if loader.PreviousVersion() != "v1" {
+		t.Errorf("PreviousVersion() = %s, want v1", loader.PreviousVersion())
+	}
+
+	cfg, err := loader.Rollback()
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if cfg.Version != "v1" || loader.GetConfigVersion() != "v1" || loader.PreviousVersion() != "v2" {
+		t.Errorf("after rollback active = %s, previous = %s, want v1 and v2", loader.GetConfigVersion(), loader.PreviousVersion())
+	}
+}
@@ -1,75 +1,285 @@
 package config
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
+var (
+	// ErrVersionConflict is returned by Update when the active version differs from the expected one
+	ErrVersionConflict = errors.New("config version conflict")
+	// ErrInvalidConfig is returned by Update when the changed config fails validation
+	ErrInvalidConfig = errors.New("invalid config")
+	// ErrPreApplyCheckFailed is returned by ApplyConfig when a pre-apply check rejects the config
+	ErrPreApplyCheckFailed = errors.New("pre-apply check failed")
+	// ErrNoPreviousConfig is returned by Rollback when no config was replaced yet
+	ErrNoPreviousConfig = errors.New("no previous config")
+)
+
 // ConfigSource indicates where the config came from
 type ConfigSource string
 
 const (
-	SourceFile         ConfigSource = "file"
+	SourceFile         ConfigSource = "file" // The loader's Source: a file, fragment directory or URL
 	SourceControlPlane ConfigSource = "control_plane"
+	SourceCache        ConfigSource = "cache" // Last config acked from the control plane, persisted locally
 )
 
 // Loader manages hot-reloading of routing configuration
 type Loader struct {
-	configPath   string
+	source       Source
 	activeConfig atomic.Value // stores *Config
+	prevConfig   atomic.Value // stores *Config replaced by the active one (last-known-good for rollback)
 	configSource atomic.Value // stores ConfigSource
-	lastChecksum atomic.Value // stores string
+	lastRevision atomic.Value // stores string; revision of the config last read from the source
 	lastReload   atomic.Value // stores time.Time
 	pollInterval time.Duration
 	stopChan     chan struct{}
+	updateMu     sync.Mutex // Serializes Update, Rollback and file reloads
+	history      *History   // Records accepted configs if set
+	checks       []PreApplyCheck
+	onActivate   []func(cfg *Config) // Called with every config activated
+	verifyKey    ed25519.PublicKey   // Files must carry a valid detached signature if set
 }
 
-// NewLoader creates a new config loader
+// PreApplyCheck vets a config from the control plane after validation and before it is applied
+// current is the active config, or nil if none is loaded yet
+type PreApplyCheck func(current, next *Config) error
+
+// NewLoader creates a new config loader reading a single config file
 func NewLoader(configPath string, pollInterval time.Duration) *Loader {
+	return NewSourceLoader(NewFileSource(configPath), pollInterval)
+}
+
+// NewSourceLoader creates a new config loader reading from source (see OpenSource)
+func NewSourceLoader(source Source, pollInterval time.Duration) *Loader {
 	return &Loader{
-		configPath:   configPath,
+		source:       source,
 		pollInterval: pollInterval,
 		stopChan:     make(chan struct{}),
 	}
 }
 
-// LoadInitial loads the config file at startup
+// Source returns where the loader reads its config from
+func (l *Loader) Source() Source {
+	return l.source
+}
+
+// SetHistory records every config the loader accepts from now on
+func (l *Loader) SetHistory(history *History) {
+	l.history = history
+}
+
+// SetVerifyKey requires every config the loader reads (initial, reloaded or cached) to carry a
+// detached signature (see SignatureFile) by the matching private key. Must be called before LoadInitial
+func (l *Loader) SetVerifyKey(key ed25519.PublicKey) {
+	l.verifyKey = key
+}
+
+// History returns the loader's config history, or nil if none is set
+func (l *Loader) History() *History {
+	return l.history
+}
+
+// LoadInitial loads the config from the source at startup
 // Returns error if config is invalid or missing
 func (l *Loader) LoadInitial() error {
-	cfg, err := LoadFromFile(l.configPath)
+	_, err := l.loadInitial()
+	return err
+}
+
+// loadInitial loads and activates the config from the source, returning what was fetched
+func (l *Loader) loadInitial() (*Fetched, error) {
+	fetched, err := l.source.Fetch("")
 	if err != nil {
-		return fmt.Errorf("failed to load initial config: %w", err)
+		return nil, fmt.Errorf("failed to load initial config: %w", err)
 	}
+	cfg := fetched.Config
 
 	if err := cfg.Validate(); err != nil {
-		return fmt.Errorf("invalid initial config: %w", err)
+		return nil, fmt.Errorf("invalid initial config: %w", err)
 	}
 
-	checksum, err := fileChecksum(l.configPath)
-	if err != nil {
-		return fmt.Errorf("failed to compute checksum: %w", err)
+	if err := l.verifySource(cfg); err != nil {
+		return nil, fmt.Errorf("initial config rejected: %w", err)
 	}
 
-	l.activeConfig.Store(cfg)
-	l.configSource.Store(SourceFile)
-	l.lastChecksum.Store(checksum)
-	l.lastReload.Store(time.Now())
+	l.lastRevision.Store(fetched.Revision)
+	l.activate(cfg, SourceFile)
 
 	log.Printf("Loaded initial config version: %s", cfg.Version)
+	return fetched, nil
+}
+
+// OnActivate registers a function called with every config the loader activates from now on:
+// reloads, control plane snapshots and deltas, API updates and rollbacks. Must be called before reloads start
+func (l *Loader) OnActivate(fn func(cfg *Config)) {
+	l.onActivate = append(l.onActivate, fn)
+}
+
+// AddPreApplyCheck registers a check ApplyConfig runs before applying a config
+func (l *Loader) AddPreApplyCheck(check PreApplyCheck) {
+	l.updateMu.Lock()
+	defer l.updateMu.Unlock()
+	l.checks = append(l.checks, check)
+}
+
+// LoadInitialWithCache loads the config from the source at startup, preferring the cached control plane config
+// The cache is used if it is valid and was written after the source's config last changed (or the source
+// cannot tell when that was), or if the source cannot be loaded. Returns error if neither yields a valid config
+func (l *Loader) LoadInitialWithCache(cachePath string) error {
+	fetched, initialErr := l.loadInitial()
+
+	cached, err := l.loadValidFile(cachePath)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Ignoring config cache %s: %v", cachePath, err)
+		}
+		return initialErr
+	}
+
+	if initialErr == nil {
+		cacheInfo, err := os.Stat(cachePath)
+		if err != nil {
+			return nil
+		}
+		if !fetched.ModTime.IsZero() && !cacheInfo.ModTime().After(fetched.ModTime) {
+			log.Printf("Config cache %s (version %s) is older than %s, not using it", cachePath, cached.Version, l.source)
+			return nil
+		}
+	} else {
+		log.Printf("Falling back to config cache: %v", initialErr)
+	}
+
+	l.updateMu.Lock()
+	l.activate(cached, SourceCache)
+	l.updateMu.Unlock()
+
+	log.Printf("Loaded cached control plane config version: %s", cached.Version)
 	return nil
 }
 
-// ApplyConfig atomically applies a config from the control plane
+// loadValidFile reads, parses, validates and (with a verify key) verifies a config file
+func (l *Loader) loadValidFile(path string) (*Config, error) {
+	cfg, err := LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if err := l.verifyFile(path, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// verifySource checks the detached signature of the config last fetched from the source if the loader has a verify key
+func (l *Loader) verifySource(cfg *Config) error {
+	if l.verifyKey == nil {
+		return nil
+	}
+	signature, err := l.source.ReadSignature()
+	if err != nil {
+		return err
+	}
+	return VerifyConfig(l.verifyKey, cfg, signature)
+}
+
+// verifyFile checks a config file's detached signature if the loader has a verify key
+func (l *Loader) verifyFile(path string, cfg *Config) error {
+	if l.verifyKey == nil {
+		return nil
+	}
+	signature, err := ReadSignatureFile(path)
+	if err != nil {
+		return err
+	}
+	return VerifyConfig(l.verifyKey, cfg, signature)
+}
+
+// ApplyConfig validates a config from the control plane, runs pre-apply checks and atomically applies it
+// Returns ErrInvalidConfig or ErrPreApplyCheckFailed, keeping the active config, if the config is rejected
 func (l *Loader) ApplyConfig(cfg *Config) error {
+	l.updateMu.Lock()
+	defer l.updateMu.Unlock()
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	current, _ := l.activeConfig.Load().(*Config)
+	for _, check := range l.checks {
+		if err := check(current, cfg); err != nil {
+			return fmt.Errorf("%w: %v", ErrPreApplyCheckFailed, err)
+		}
+	}
+
+	l.activate(cfg, SourceControlPlane)
+	return nil
+}
+
+// Rollback reactivates the config replaced by the active one, keeping the active one as previous
+// A later reload or control plane snapshot replaces it as usual
+func (l *Loader) Rollback() (*Config, error) {
+	l.updateMu.Lock()
+	defer l.updateMu.Unlock()
+
+	previous := l.PreviousConfig()
+	if previous == nil {
+		return nil, ErrNoPreviousConfig
+	}
+
+	current := l.GetConfig()
+	l.activate(previous, l.GetConfigSource().(ConfigSource))
+	log.Printf("Config rolled back: version %s -> %s", current.Version, previous.Version)
+	return previous, nil
+}
+
+// PreviousConfig returns the config replaced by the active one, or nil if there is none
+func (l *Loader) PreviousConfig() *Config {
+	v := l.prevConfig.Load()
+	if v == nil {
+		return nil
+	}
+	return v.(*Config)
+}
+
+// PreviousVersion returns the version of the config replaced by the active one, or "" if there is none
+func (l *Loader) PreviousVersion() string {
+	if previous := l.PreviousConfig(); previous != nil {
+		return previous.Version
+	}
+	return ""
+}
+
+// activate makes cfg the active config, keeping the replaced one for rollback and recording cfg in history
+func (l *Loader) activate(cfg *Config, source ConfigSource) {
+	if current, ok := l.activeConfig.Load().(*Config); ok && current != cfg {
+		l.prevConfig.Store(current)
+	}
 	l.activeConfig.Store(cfg)
-	l.configSource.Store(SourceControlPlane)
+	l.configSource.Store(source)
 	l.lastReload.Store(time.Now())
-	return nil
+
+	if l.history != nil {
+		if _, err := l.history.Record(cfg); err != nil {
+			log.Printf("Failed to record config version %s in history: %v", cfg.Version, err)
+		}
+	}
+	for _, fn := range l.onActivate {
+		fn(cfg)
+	}
 }
 
 // GetConfigSource returns the source of the current config
@@ -97,8 +307,9 @@ func (l *Loader) GetRoutingTable() map[string]string {
 }
 
 // GetCellEndpoints implements routing.ConfigProvider
+// Supports both legacy and new formats
 func (l *Loader) GetCellEndpoints() map[string]string {
-	return l.GetConfig().CellEndpoints
+	return l.GetConfig().GetCellEndpoints()
 }
 
 // GetDefaultPlacement implements routing.ConfigProvider
@@ -125,7 +336,7 @@ func (l *Loader) Stop() {
 	close(l.stopChan)
 }
 
-// reloadLoop polls the config file for changes and reloads if needed
+// reloadLoop polls the config source for changes and reloads if needed
 func (l *Loader) reloadLoop() {
 	ticker := time.NewTicker(l.pollInterval)
 	defer ticker.Stop()
@@ -142,47 +353,129 @@ func (l *Loader) reloadLoop() {
 
 // tryReload attempts to reload the config if it has changed
 func (l *Loader) tryReload() {
-	// Check if file has changed
-	currentChecksum, err := fileChecksum(l.configPath)
-	if err != nil {
-		log.Printf("Config reload: failed to compute checksum: %v", err)
-		return
-	}
+	l.updateMu.Lock()
+	defer l.updateMu.Unlock()
 
-	lastChecksum := l.lastChecksum.Load().(string)
-	if currentChecksum == lastChecksum {
-		// No changes
+	// Fetch only if the source changed since the last config read from it
+	lastRevision, _ := l.lastRevision.Load().(string)
+	fetched, err := l.source.Fetch(lastRevision)
+	if errors.Is(err, ErrNotModified) {
 		return
 	}
-
-	// File changed, attempt to load and validate
-	cfg, err := LoadFromFile(l.configPath)
 	if err != nil {
 		log.Printf("Config reload failed: %v (keeping last-known-good config)", err)
 		return
 	}
+	cfg := fetched.Config
 
 	if err := cfg.Validate(); err != nil {
 		log.Printf("Config reload failed: validation error: %v (keeping last-known-good config)", err)
 		return
 	}
 
+	if err := l.verifySource(cfg); err != nil {
+		log.Printf("Config reload failed: %v (keeping last-known-good config)", err)
+		return
+	}
+
 	// Atomically swap to new config
-	l.activeConfig.Store(cfg)
-	l.configSource.Store(SourceFile)
-	l.lastChecksum.Store(currentChecksum)
-	l.lastReload.Store(time.Now())
+	l.lastRevision.Store(fetched.Revision)
+	l.activate(cfg, SourceFile)
 
 	log.Printf("Config reloaded successfully: version %s", cfg.Version)
 }
 
-// fileChecksum computes SHA256 checksum of a file
-func fileChecksum(path string) (string, error) {
-	data, err := os.ReadFile(path)
+// Update applies a change to a copy of the active config, bumps its version, validates it
+// and atomically replaces the config file before activating it
+// Returns ErrVersionConflict if the active version is not expectedVersion, and ErrReadOnlySource
+// if the source is not a WritableSource
+func (l *Loader) Update(expectedVersion string, change func(cfg *Config) error) (*Config, error) {
+	l.updateMu.Lock()
+	defer l.updateMu.Unlock()
+
+	writer, ok := l.source.(WritableSource)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReadOnlySource, l.source)
+	}
+
+	current := l.GetConfig()
+	if current.Version != expectedVersion {
+		return nil, fmt.Errorf("%w: active version is %s, not %s", ErrVersionConflict, current.Version, expectedVersion)
+	}
+
+	cfg, err := current.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if err := change(cfg); err != nil {
+		return nil, err
+	}
+	cfg.Version = NextVersion(current.Version)
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	data = append(data, '\n')
+
+	revision, err := writer.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write config: %w", err)
+	}
+
+	l.lastRevision.Store(revision)
+	l.activate(cfg, SourceFile)
+
+	log.Printf("Config updated: version %s -> %s", current.Version, cfg.Version)
+	return cfg, nil
+}
+
+// NextVersion derives the version following v by incrementing its trailing number
+// ("1.0.0" -> "1.0.1", "v1" -> "v2", "2024-01" -> "2024-02"), or appending ".1" if it has none
+func NextVersion(v string) string {
+	i := len(v)
+	for i > 0 && v[i-1] >= '0' && v[i-1] <= '9' {
+		i--
+	}
+	if i == len(v) {
+		return v + ".1"
+	}
+	n, err := strconv.ParseUint(v[i:], 10, 64)
 	if err != nil {
-		return "", err
+		return v + ".1"
 	}
+	return v[:i] + fmt.Sprintf("%0*d", len(v)-i, n+1) // Keep zero padding
+}
 
-	hash := sha256.Sum256(data)
-	return hex.EncodeToString(hash[:]), nil
+// writeFileAtomic writes data to a temp file in the same directory and renames it over path
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// Keep the original file's permissions
+	if info, err := os.Stat(path); err == nil {
+		if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
 }
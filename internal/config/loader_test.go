@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -170,3 +171,192 @@ func TestLoader_HotReload_KeepsLastKnownGood(t *testing.T) {
 		t.Errorf("After invalid reload, version = %v, want v1 (last-known-good)", cfg.Version)
 	}
 }
+
+func TestLoader_Update(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	initialConfig := `{
+		"version": "1.0.0",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+
+	if err := os.WriteFile(tmpFile, []byte(initialConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	loader := NewLoader(tmpFile, 1*time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+
+	// Stale versions are rejected
+	_, err := loader.Update("0.9.0", func(cfg *Config) error { return nil })
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Update with stale version error = %v, want ErrVersionConflict", err)
+	}
+
+	// Invalid changes are rejected and leave the active config untouched
+	_, err = loader.Update("1.0.0", func(cfg *Config) error {
+		cfg.RoutingTable["acme"] = "missing"
+		return nil
+	})
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Update with unknown placement error = %v, want ErrInvalidConfig", err)
+	}
+	if got := loader.GetConfig().RoutingTable["acme"]; got != "tier1" {
+		t.Errorf("active acme placement = %s after rejected update, want tier1", got)
+	}
+
+	cfg, err := loader.Update("1.0.0", func(cfg *Config) error {
+		cfg.RoutingTable["acme"] = "tier2"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if cfg.Version != "1.0.1" || loader.GetConfigVersion() != "1.0.1" {
+		t.Errorf("Version = %s (active %s), want 1.0.1", cfg.Version, loader.GetConfigVersion())
+	}
+
+	// The change is persisted and not picked up again as a file change
+	onDisk, err := LoadFromFile(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if onDisk.Version != "1.0.1" || onDisk.RoutingTable["acme"] != "tier2" {
+		t.Errorf("persisted config = %+v, want version 1.0.1 with acme on tier2", onDisk)
+	}
+	reloadTime := loader.LastReloadTime()
+	loader.tryReload()
+	if !loader.LastReloadTime().Equal(reloadTime) {
+		t.Error("tryReload reloaded a config written by Update")
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	tests := map[string]string{
+		"1.0.0":   "1.0.1",
+		"v9":      "v10",
+		"2024-01": "2024-02",
+		"stable":  "stable.1",
+	}
+	for version, want := range tests {
+		if got := NextVersion(version); got != want {
+			t.Errorf("NextVersion(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
+
+func TestLoader_ApplyConfig_RejectsKeepingLastKnownGood(t *testing.T) {
+	loader := NewLoader(t.TempDir()+"/config.json", time.Second)
+	if err := loader.ApplyConfig(testConfig("v1", "tier1")); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+
+	invalid := testConfig("v2", "tier9")
+	if err := loader.ApplyConfig(invalid); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("ApplyConfig(unknown placement) error = %v, want ErrInvalidConfig", err)
+	}
+
+	var checked *Config
+	loader.AddPreApplyCheck(func(current, next *Config) error {
+		checked = current
+		return errors.New("endpoint unreachable")
+	})
+	if err := loader.ApplyConfig(testConfig("v3", "tier2")); !errors.Is(err, ErrPreApplyCheckFailed) {
+		t.Errorf("ApplyConfig(failing check) error = %v, want ErrPreApplyCheckFailed", err)
+	}
+	if checked == nil || checked.Version != "v1" {
+		t.Errorf("pre-apply check got current = %+v, want v1", checked)
+	}
+
+	if version := loader.GetConfigVersion(); version != "v1" {
+		t.Errorf("active version = %s, want v1 kept", version)
+	}
+}
+
+func TestLoader_LoadInitialWithCache(t *testing.T) {
+	dir := t.TempDir()
+	initialPath := dir + "/initial.json"
+	cachePath := dir + "/cache.json"
+	old := time.Now().Add(-time.Hour)
+
+	writeInitial := func() {
+		t.Helper()
+		if err := SaveToFile(initialPath, testConfig("v1", "tier1")); err != nil {
+			t.Fatalf("SaveToFile failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		setup       func()
+		wantVersion string
+		wantSource  ConfigSource
+	}{
+		{
+			name:        "no cache",
+			setup:       writeInitial,
+			wantVersion: "v1",
+			wantSource:  SourceFile,
+		},
+		{
+			name: "cache newer than initial file",
+			setup: func() {
+				writeInitial()
+				os.Chtimes(initialPath, old, old)
+				SaveToFile(cachePath, testConfig("v7", "tier2"))
+			},
+			wantVersion: "v7",
+			wantSource:  SourceCache,
+		},
+		{
+			name: "initial file newer than cache",
+			setup: func() {
+				SaveToFile(cachePath, testConfig("v7", "tier2"))
+				os.Chtimes(cachePath, old, old)
+				writeInitial()
+			},
+			wantVersion: "v1",
+			wantSource:  SourceFile,
+		},
+		{
+			name: "invalid cache",
+			setup: func() {
+				writeInitial()
+				os.Chtimes(initialPath, old, old)
+				SaveToFile(cachePath, testConfig("v7", "tier9"))
+			},
+			wantVersion: "v1",
+			wantSource:  SourceFile,
+		},
+		{
+			name: "missing initial file",
+			setup: func() {
+				SaveToFile(cachePath, testConfig("v7", "tier2"))
+			},
+			wantVersion: "v7",
+			wantSource:  SourceCache,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(initialPath)
+			os.Remove(cachePath)
+			tt.setup()
+
+			loader := NewLoader(initialPath, time.Second)
+			if err := loader.LoadInitialWithCache(cachePath); err != nil {
+				t.Fatalf("LoadInitialWithCache failed: %v", err)
+			}
+			if version := loader.GetConfigVersion(); version != tt.wantVersion {
+				t.Errorf("version = %s, want %s", version, tt.wantVersion)
+			}
+			if source := loader.GetConfigSource(); source != tt.wantSource {
+				t.Errorf("source = %v, want %s", source, tt.wantSource)
+			}
+		})
+	}
+}
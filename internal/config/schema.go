@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+//go:generate go run ../../cmd/config-schema -o ../../config/routing.schema.json
+
+// SchemaID is the $schema dialect of the generated schema
+const SchemaID = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema returns the JSON Schema of the routing config, generated from Config
+// Structs become closed objects under $defs; fields without omitempty are required
+func Schema() ([]byte, error) {
+	defs := make(map[string]interface{})
+	root := structSchema(reflect.TypeOf(Config{}), defs)
+	root["$schema"] = SchemaID
+	root["title"] = "Cell routing config"
+	root["$defs"] = defs
+
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// structSchema returns the schema of a struct's fields, adding the structs it uses to defs
+func structSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for _, field := range jsonFields(t) {
+		properties[field.name] = typeSchema(field.typ, defs)
+		if !field.omitempty {
+			required = append(required, field.name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// typeSchema returns the schema of a field type, referencing structs by their name in defs
+func typeSchema(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, exists := defs[t.Name()]; !exists {
+			defs[t.Name()] = nil // Reserve the name in case the struct refers to itself
+			defs[t.Name()] = structSchema(t, defs)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), defs),
+		}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
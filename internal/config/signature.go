@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrUnsigned is returned when a config that must be signed carries no signature
+	ErrUnsigned = errors.New("config is not signed")
+	// ErrBadSignature is returned when a config signature does not verify against the trusted key
+	ErrBadSignature = errors.New("config signature does not verify")
+)
+
+// SignatureFile returns the path of the detached signature for a config file
+func SignatureFile(path string) string {
+	return path + ".sig"
+}
+
+// CanonicalJSON serializes a config deterministically for signing: object keys sorted, no
+// insignificant whitespace, and null values and empty objects or arrays omitted, so a config
+// signs the same however it was transported or formatted
+func CanonicalJSON(cfg *Config) ([]byte, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Keep numbers as written
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("failed to canonicalize config: %w", err)
+	}
+
+	// encoding/json writes map keys in sorted order
+	return json.Marshal(prune(tree))
+}
+
+// prune drops null values and empty objects or arrays from a decoded JSON tree
+func prune(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if value = prune(value); value == nil {
+				delete(v, key)
+			} else {
+				v[key] = value
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = prune(value)
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	return v
+}
+
+// SignConfig returns a base64 Ed25519 signature over the config's canonical JSON
+func SignConfig(key ed25519.PrivateKey, cfg *Config) (string, error) {
+	data, err := CanonicalJSON(cfg)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)), nil
+}
+
+// VerifyConfig checks a base64 Ed25519 signature over the config's canonical JSON
+// Returns ErrUnsigned if signature is empty and ErrBadSignature if it does not verify
+func VerifyConfig(key ed25519.PublicKey, cfg *Config, signature string) error {
+	if signature == "" {
+		return ErrUnsigned
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrBadSignature)
+	}
+
+	data, err := CanonicalJSON(cfg)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("%w for version %s", ErrBadSignature, cfg.Version)
+	}
+	return nil
+}
+
+// ReadSignatureFile reads the detached signature of a config file
+// Returns ErrUnsigned if the signature file does not exist
+func ReadSignatureFile(path string) (string, error) {
+	data, err := os.ReadFile(SignatureFile(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s missing", ErrUnsigned, SignatureFile(path))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read signature: %w", err)
+	}
+	return string(data), nil
+}
+
+// WriteSignatureFile atomically writes the detached signature of a config file
+func WriteSignatureFile(path, signature string) error {
+	if err := writeFileAtomic(SignatureFile(path), []byte(signature+"\n")); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+// LoadSigningKey reads a PEM-encoded PKCS#8 Ed25519 private key (as written by openssl genpkey -algorithm ed25519)
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not an Ed25519 key", path)
+	}
+	return edKey, nil
+}
+
+// LoadVerifyKey reads a PEM-encoded PKIX Ed25519 public key (as written by openssl pkey -pubout)
+func LoadVerifyKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse verify key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("verify key %s is not an Ed25519 key", path)
+	}
+	return edKey, nil
+}
+
+// readPEM returns the bytes of the first PEM block of the given type in a file
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no %s block in %s", blockType, path)
+		}
+		if block.Type == blockType {
+			return block.Bytes, nil
+		}
+	}
+}
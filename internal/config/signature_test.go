@@ -0,0 +1,132 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestVerifyConfig(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	signed := testConfig("v1", "tier1")
+	signature, err := SignConfig(priv, signed)
+	if err != nil {
+		t.Fatalf("SignConfig failed: %v", err)
+	}
+
+	// Same content, transported differently: empty rather than nil maps
+	equivalent := testConfig("v1", "tier1")
+	equivalent.Placements = map[string]*PlacementConfig{}
+
+	tests := []struct {
+		name      string
+		key       ed25519.PublicKey
+		cfg       *Config
+		signature string
+		wantErr   error
+	}{
+		{name: "signed config", key: pub, cfg: signed, signature: signature},
+		{name: "equivalent config", key: pub, cfg: equivalent, signature: signature},
+		{name: "rerouted tenant", key: pub, cfg: testConfig("v1", "tier2"), signature: signature, wantErr: ErrBadSignature},
+		{name: "other key", key: otherPub, cfg: signed, signature: signature, wantErr: ErrBadSignature},
+		{name: "malformed signature", key: pub, cfg: signed, signature: "not base64!", wantErr: ErrBadSignature},
+		{name: "unsigned", key: pub, cfg: signed, signature: "", wantErr: ErrUnsigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyConfig(tt.key, tt.cfg, tt.signature)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("VerifyConfig error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyConfig error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	cfg := testConfig("v1", "tier1")
+	cfg.Placements = map[string]*PlacementConfig{}
+
+	data, err := CanonicalJSON(cfg)
+	if err != nil {
+		t.Fatalf("CanonicalJSON failed: %v", err)
+	}
+	want := `{"cellEndpoints":{"tier1":"http://cell-tier1:9001","tier2":"http://cell-tier2:9002"},"defaultPlacement":"tier1","routingTable":{"acme":"tier1"},"version":"v1"}`
+	if string(data) != want {
+		t.Errorf("CanonicalJSON = %s, want %s", data, want)
+	}
+}
+
+func TestLoader_VerifyKey(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	// Keys round-trip through the PEM formats openssl writes
+	privDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	os.WriteFile(dir+"/signing.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600)
+	os.WriteFile(dir+"/verify.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644)
+	signingKey, err := LoadSigningKey(dir + "/signing.pem")
+	if err != nil {
+		t.Fatalf("LoadSigningKey failed: %v", err)
+	}
+	verifyKey, err := LoadVerifyKey(dir + "/verify.pem")
+	if err != nil {
+		t.Fatalf("LoadVerifyKey failed: %v", err)
+	}
+
+	path := dir + "/config.json"
+	write := func(cfg *Config, sign bool) {
+		t.Helper()
+		if err := SaveToFile(path, cfg); err != nil {
+			t.Fatalf("SaveToFile failed: %v", err)
+		}
+		if sign {
+			signature, err := SignConfig(signingKey, cfg)
+			if err != nil {
+				t.Fatalf("SignConfig failed: %v", err)
+			}
+			if err := WriteSignatureFile(path, signature); err != nil {
+				t.Fatalf("WriteSignatureFile failed: %v", err)
+			}
+		}
+	}
+
+	write(testConfig("v1", "tier1"), false)
+	loader := NewLoader(path, time.Second)
+	loader.SetVerifyKey(verifyKey)
+	if err := loader.LoadInitial(); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("LoadInitial of unsigned file error = %v, want ErrUnsigned", err)
+	}
+
+	write(testConfig("v1", "tier1"), true)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial of signed file failed: %v", err)
+	}
+
+	// A dropped-in file with a stale signature is not reloaded
+	write(testConfig("v2", "tier2"), false)
+	loader.tryReload()
+	if version := loader.GetConfigVersion(); version != "v1" {
+		t.Errorf("version after unsigned reload = %s, want v1", version)
+	}
+
+	write(testConfig("v2", "tier2"), true)
+	loader.tryReload()
+	if version := loader.GetConfigVersion(); version != "v2" {
+		t.Errorf("version after signed reload = %s, want v2", version)
+	}
+}
@@ -0,0 +1,396 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotModified is returned by Source.Fetch when the config is still at the given revision
+	ErrNotModified = errors.New("config not modified")
+	// ErrReadOnlySource is returned by Update when the loader's source cannot be written
+	ErrReadOnlySource = errors.New("config source is read-only")
+)
+
+// Source supplies the config a Loader validates and activates
+type Source interface {
+	// Fetch returns the current config unless its revision equals since, in which case it returns ErrNotModified
+	Fetch(since string) (*Fetched, error)
+	// ReadSignature returns the detached signature of the config last fetched (ErrUnsigned if it has none)
+	ReadSignature() (string, error)
+	// String describes the source in logs
+	String() string
+}
+
+// WritableSource is a source the loader can write configs changed through Update to
+type WritableSource interface {
+	Source
+	// Write atomically replaces the config and returns its new revision
+	Write(data []byte) (string, error)
+}
+
+// Fetched is a config read from a source
+type Fetched struct {
+	Config   *Config
+	Revision string    // Identifies the content, e.g. a checksum or ETag
+	ModTime  time.Time // When the content last changed; zero if unknown
+}
+
+// OpenSource returns the source a URI names:
+//   - a path or file:// URI: a single config file, or a directory of fragments if the path is a directory
+//   - dir:// URI: a directory of fragments
+//   - http:// or https:// URL: a document fetched with ETag revalidation
+func OpenSource(uri string) (Source, error) {
+	switch {
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		if _, err := url.Parse(uri); err != nil {
+			return nil, fmt.Errorf("invalid config source URL: %w", err)
+		}
+		return NewHTTPSource(uri), nil
+	case strings.HasPrefix(uri, "dir://"):
+		return NewDirectorySource(strings.TrimPrefix(uri, "dir://")), nil
+	}
+
+	path := strings.TrimPrefix(uri, "file://")
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return NewDirectorySource(path), nil
+	}
+	return NewFileSource(path), nil
+}
+
+// FileSource reads a single config file, revisioned by its SHA256 checksum
+type FileSource struct {
+	path string
+}
+
+// NewFileSource creates a source reading the config file at path
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Path returns the config file path
+func (f *FileSource) Path() string {
+	return f.path
+}
+
+// Fetch reads the config file if its checksum differs from since
+func (f *FileSource) Fetch(since string) (*Fetched, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	revision := checksum(data)
+	if revision == since {
+		return nil, ErrNotModified
+	}
+
+	cfg, err := ParseConfig(data, FormatForPath(f.path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	fetched := &Fetched{Config: cfg, Revision: revision}
+	if info, err := os.Stat(f.path); err == nil {
+		fetched.ModTime = info.ModTime()
+	}
+	return fetched, nil
+}
+
+// ReadSignature reads the file's detached signature
+func (f *FileSource) ReadSignature() (string, error) {
+	return ReadSignatureFile(f.path)
+}
+
+// Write atomically replaces the config file
+// Only JSON files are written, since rewriting YAML or TOML would lose their comments
+func (f *FileSource) Write(data []byte) (string, error) {
+	if format := FormatForPath(f.path); format != FormatJSON {
+		return "", fmt.Errorf("%w: %s config files are edited by hand", ErrReadOnlySource, format)
+	}
+	if err := writeFileAtomic(f.path, data); err != nil {
+		return "", err
+	}
+	return checksum(data), nil
+}
+
+// String returns the file path
+func (f *FileSource) String() string {
+	return f.path
+}
+
+// DirectorySource merges the fragment files of a directory (*.json, *.yaml, *.yml, *.toml), e.g. one file per tenant
+//
+// Fragments are read in file name order, whatever their format. Map fields (routingTable, cellEndpoints, placements, views)
+// are merged, and the other fields may be set by any number of fragments as long as they agree, so a
+// key defined twice is an error rather than a silent override. Without a version in any fragment the
+// merged config is versioned by its content checksum
+type DirectorySource struct {
+	dir string
+}
+
+// fragmentPatterns match the fragment files of a DirectorySource
+var fragmentPatterns = []string{"*.json", "*.yaml", "*.yml", "*.toml"}
+
+// NewDirectorySource creates a source merging the fragments in dir
+func NewDirectorySource(dir string) *DirectorySource {
+	return &DirectorySource{dir: dir}
+}
+
+// Path returns the fragment directory
+func (d *DirectorySource) Path() string {
+	return d.dir
+}
+
+// Fetch merges the fragments if their combined checksum differs from since
+func (d *DirectorySource) Fetch(since string) (*Fetched, error) {
+	var paths []string
+	for _, pattern := range fragmentPatterns {
+		matches, err := filepath.Glob(filepath.Join(d.dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list config fragments: %w", err)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no config fragments (%s) in %s", strings.Join(fragmentPatterns, ", "), d.dir)
+	}
+	sort.Strings(paths)
+
+	// Read everything first so the revision covers exactly the merged content
+	hash := sha256.New()
+	fragments := make([][]byte, len(paths))
+	var modTime time.Time
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config fragment: %w", err)
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.Base(path), len(data))
+		hash.Write(data)
+		fragments[i] = data
+
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	revision := hex.EncodeToString(hash.Sum(nil))
+	if revision == since {
+		return nil, ErrNotModified
+	}
+
+	merged := &Config{}
+	for i, data := range fragments {
+		fragment, err := ParseConfig(data, FormatForPath(paths[i]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config fragment %s: %w", filepath.Base(paths[i]), err)
+		}
+		if err := mergeFragment(merged, fragment); err != nil {
+			return nil, fmt.Errorf("config fragment %s: %w", filepath.Base(paths[i]), err)
+		}
+	}
+	if merged.Version == "" {
+		merged.Version = "sha256-" + revision[:12]
+	}
+	if merged.RoutingTable == nil {
+		merged.RoutingTable = map[string]string{}
+	}
+
+	return &Fetched{Config: merged, Revision: revision, ModTime: modTime}, nil
+}
+
+// mergeFragment merges a fragment into cfg, failing on keys or fields the two set differently
+func mergeFragment(cfg, fragment *Config) error {
+	if err := mergeField("version", &cfg.Version, fragment.Version); err != nil {
+		return err
+	}
+	if err := mergeField("defaultPlacement", &cfg.DefaultPlacement, fragment.DefaultPlacement); err != nil {
+		return err
+	}
+	if err := mergeField("rateLimitMaxKeys", &cfg.RateLimitMaxKeys, fragment.RateLimitMaxKeys); err != nil {
+		return err
+	}
+	if err := mergeMap("routingTable", &cfg.RoutingTable, fragment.RoutingTable); err != nil {
+		return err
+	}
+	if err := mergeMap("cellEndpoints", &cfg.CellEndpoints, fragment.CellEndpoints); err != nil {
+		return err
+	}
+	if err := mergeMap("placements", &cfg.Placements, fragment.Placements); err != nil {
+		return err
+	}
+	return mergeMap("views", &cfg.Views, fragment.Views)
+}
+
+// mergeField sets a scalar field unless another fragment set it to a different value
+func mergeField[T comparable](name string, field *T, value T) error {
+	var zero T
+	switch {
+	case value == zero:
+	case *field == zero:
+		*field = value
+	case *field != value:
+		return fmt.Errorf("%s %v conflicts with %v set by an earlier fragment", name, value, *field)
+	}
+	return nil
+}
+
+// mergeMap adds a fragment's entries to a map field, failing on keys an earlier fragment defined
+func mergeMap[V any](name string, field *map[string]V, entries map[string]V) error {
+	for key, value := range entries {
+		if _, exists := (*field)[key]; exists {
+			return fmt.Errorf("%s[%s] is already defined by an earlier fragment", name, key)
+		}
+		if *field == nil {
+			*field = make(map[string]V)
+		}
+		(*field)[key] = value
+	}
+	return nil
+}
+
+// ReadSignature reads the detached signature of the merged config, stored next to the directory
+func (d *DirectorySource) ReadSignature() (string, error) {
+	return ReadSignatureFile(filepath.Clean(d.dir))
+}
+
+// String returns the directory path
+func (d *DirectorySource) String() string {
+	return d.dir
+}
+
+// Caps on HTTP source responses, so a misbehaving server cannot exhaust memory
+const (
+	MaxHTTPConfigSize    = 10 << 20 // Largest config document fetched
+	MaxHTTPSignatureSize = 64 << 10 // Largest detached signature fetched
+)
+
+// HTTPSource fetches a config from a URL, revalidating with If-None-Match
+// The format comes from the Content-Type, or the URL's extension if that names none. The revision is the response ETag, or the body checksum if the server sends none
+type HTTPSource struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSource creates a source fetching the config at url
+func NewHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Fetch downloads the config unless the server reports it unchanged since the given revision
+func (h *HTTPSource) Fetch(since string) (*Fetched, error) {
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if since != "" {
+		req.Header.Set("If-None-Match", since)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch config: %s", resp.Status)
+	}
+	data, err := readLimited(resp.Body, MaxHTTPConfigSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+
+	revision := resp.Header.Get("ETag")
+	if revision == "" {
+		revision = checksum(data)
+	}
+	if revision == since {
+		return nil, ErrNotModified
+	}
+
+	format := formatForContentType(resp.Header.Get("Content-Type"))
+	if format == "" {
+		format = FormatForPath(resp.Request.URL.Path)
+	}
+	cfg, err := ParseConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	fetched := &Fetched{Config: cfg, Revision: revision}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		fetched.ModTime = modTime
+	}
+	return fetched, nil
+}
+
+// ReadSignature downloads the detached signature published next to the config (<url>.sig)
+// The suffix is added to the URL path, so a query string is kept after it
+func (h *HTTPSource) ReadSignature() (string, error) {
+	u, err := url.Parse(h.url)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch signature: %w", err)
+	}
+	u.Path = SignatureFile(u.Path)
+	if u.RawPath != "" {
+		u.RawPath = SignatureFile(u.RawPath)
+	}
+	signatureURL := u.String()
+
+	resp, err := h.client.Get(signatureURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch signature: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s missing", ErrUnsigned, signatureURL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch signature: %s", resp.Status)
+	}
+	data, err := readLimited(resp.Body, MaxHTTPSignatureSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch signature: %w", err)
+	}
+	return string(data), nil
+}
+
+// String returns the URL
+func (h *HTTPSource) String() string {
+	return h.url
+}
+
+// readLimited reads r to the end, failing if it holds more than limit bytes
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("response exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// checksum returns the hex SHA256 checksum of data
+func checksum(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
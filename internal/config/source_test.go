@@ -0,0 +1,274 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeFragments(t *testing.T, dir string, fragments map[string]string) {
+	t.Helper()
+	for name, content := range fragments {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write fragment: %v", err)
+		}
+	}
+}
+
+func TestOpenSource(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: dir + "/routing.json", want: "*config.FileSource"},
+		{uri: "file://" + dir + "/routing.json", want: "*config.FileSource"},
+		{uri: dir, want: "*config.DirectorySource"},
+		{uri: "dir://" + dir + "/tenants", want: "*config.DirectorySource"},
+		{uri: "https://config.example.com/routing.json", want: "*config.HTTPSource"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			source, err := OpenSource(tt.uri)
+			if err != nil {
+				t.Fatalf("OpenSource failed: %v", err)
+			}
+			if got := fmt.Sprintf("%T", source); got != tt.want {
+				t.Errorf("OpenSource(%q) = %s, want %s", tt.uri, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectorySource(t *testing.T) {
+	dir := t.TempDir()
+	writeFragments(t, dir, map[string]string{
+		"_base.json":  `{"version": "v1", "placements": {"tier1": {"url": "http://cell-tier1:9001"}}, "defaultPlacement": "tier1"}`,
+		"acme.json":   `{"routingTable": {"acme": "acme-cell"}, "placements": {"acme-cell": {"url": "http://cell-acme:9002"}}}`,
+		"globex.json": `{"routingTable": {"globex": "tier1"}}`,
+		"README.md":   `not a fragment`,
+	})
+	source := NewDirectorySource(dir)
+
+	fetched, err := source.Fetch("")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	cfg := fetched.Config
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("merged config is invalid: %v", err)
+	}
+	if cfg.Version != "v1" || cfg.RoutingTable["acme"] != "acme-cell" || cfg.RoutingTable["globex"] != "tier1" || len(cfg.Placements) != 2 {
+		t.Errorf("merged config = %+v, want both tenants and placements at v1", cfg)
+	}
+
+	if _, err := source.Fetch(fetched.Revision); !errors.Is(err, ErrNotModified) {
+		t.Errorf("Fetch(unchanged revision) error = %v, want ErrNotModified", err)
+	}
+
+	writeFragments(t, dir, map[string]string{"initech.json": `{"routingTable": {"initech": "tier1"}}`})
+	changed, err := source.Fetch(fetched.Revision)
+	if err != nil {
+		t.Fatalf("Fetch after adding a fragment failed: %v", err)
+	}
+	if changed.Revision == fetched.Revision || changed.Config.RoutingTable["initech"] != "tier1" {
+		t.Errorf("Fetch after adding a fragment = revision %s, routing table %v; want a new revision with initech", changed.Revision, changed.Config.RoutingTable)
+	}
+
+	t.Run("conflicts", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			fragments map[string]string
+			wantErr   string
+		}{
+			{
+				name:      "routing key defined twice",
+				fragments: map[string]string{"a.json": `{"routingTable": {"acme": "tier1"}}`, "b.json": `{"routingTable": {"acme": "tier2"}}`},
+				wantErr:   "routingTable[acme] is already defined",
+			},
+			{
+				name:      "different versions",
+				fragments: map[string]string{"a.json": `{"version": "v1"}`, "b.json": `{"version": "v2"}`},
+				wantErr:   "version v2 conflicts with v1",
+			},
+			{
+				name:      "malformed fragment",
+				fragments: map[string]string{"a.json": `{"routingTable": `},
+				wantErr:   "failed to parse config fragment a.json",
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				dir := t.TempDir()
+				writeFragments(t, dir, tt.fragments)
+				_, err := NewDirectorySource(dir).Fetch("")
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Fetch error = %v, want error containing %q", err, tt.wantErr)
+				}
+			})
+		}
+	})
+
+	t.Run("version from content", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFragments(t, dir, map[string]string{"a.json": `{"routingTable": {"acme": "tier1"}}`})
+		fetched, err := NewDirectorySource(dir).Fetch("")
+		if err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+		if want := "sha256-" + fetched.Revision[:12]; fetched.Config.Version != want {
+			t.Errorf("version = %s, want %s", fetched.Config.Version, want)
+		}
+	})
+}
+
+func TestHTTPSource(t *testing.T) {
+	var mu sync.Mutex
+	body, etag := `{"version": "v1", "routingTable": {}, "cellEndpoints": {"tier1": "http://cell-tier1:9001"}, "defaultPlacement": "tier1"}`, `"rev-1"`
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/routing.json.sig" {
+			http.NotFound(w, r)
+			return
+		}
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat))
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	loader := NewSourceLoader(NewHTTPSource(server.URL+"/routing.json"), time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	if version := loader.GetConfigVersion(); version != "v1" {
+		t.Errorf("version = %s, want v1", version)
+	}
+
+	// An unchanged ETag is revalidated without reloading
+	loader.tryReload()
+	mu.Lock()
+	body, etag = strings.Replace(body, "v1", "v2", 1), `"rev-2"`
+	mu.Unlock()
+	loader.tryReload()
+	if version := loader.GetConfigVersion(); version != "v2" {
+		t.Errorf("version after reload = %s, want v2", version)
+	}
+	mu.Lock()
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+	mu.Unlock()
+
+	if _, err := loader.Update("v2", func(cfg *Config) error { return nil }); !errors.Is(err, ErrReadOnlySource) {
+		t.Errorf("Update error = %v, want ErrReadOnlySource", err)
+	}
+
+	if _, err := NewHTTPSource(server.URL + "/routing.json").ReadSignature(); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("ReadSignature error = %v, want ErrUnsigned", err)
+	}
+}
+
+func TestHTTPSource_Limits(t *testing.T) {
+	body := `{"version": "v1", "routingTable": {}, "cellEndpoints": {"tier1": "http://cell-tier1:9001"}, "defaultPlacement": "tier1"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/routing.json.sig" && r.URL.Query().Get("token") == "abc":
+			w.Write([]byte("signature"))
+		case r.URL.Path == "/large.json":
+			w.Write([]byte(body + strings.Repeat(" ", MaxHTTPConfigSize)))
+		case r.URL.Path == "/large.json.sig":
+			w.Write([]byte(strings.Repeat("a", MaxHTTPSignatureSize+1)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// The signature suffix goes on the path, before the query string
+	signature, err := NewHTTPSource(server.URL + "/routing.json?token=abc").ReadSignature()
+	if err != nil || signature != "signature" {
+		t.Errorf("ReadSignature = %q, %v; want the signature next to the path", signature, err)
+	}
+
+	large := NewHTTPSource(server.URL + "/large.json")
+	if _, err := large.Fetch(""); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("Fetch of an oversized config error = %v, want size error", err)
+	}
+	if _, err := large.ReadSignature(); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("ReadSignature of an oversized signature error = %v, want size error", err)
+	}
+}
+
+func TestLoader_DirectorySourceReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFragments(t, dir, map[string]string{
+		"_base.json": `{"version": "v1", "cellEndpoints": {"tier1": "http://cell-tier1:9001"}, "defaultPlacement": "tier1"}`,
+		"acme.json":  `{"routingTable": {"acme": "tier1"}}`,
+	})
+	loader := NewSourceLoader(NewDirectorySource(dir), time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+
+	// A fragment referencing an unknown placement is rejected, keeping the last-known-good config
+	writeFragments(t, dir, map[string]string{
+		"_base.json":  `{"version": "v2", "cellEndpoints": {"tier1": "http://cell-tier1:9001"}, "defaultPlacement": "tier1"}`,
+		"globex.json": `{"routingTable": {"globex": "tier9"}}`,
+	})
+	loader.tryReload()
+	if version := loader.GetConfigVersion(); version != "v1" {
+		t.Errorf("version after invalid fragment = %s, want v1", version)
+	}
+
+	writeFragments(t, dir, map[string]string{"globex.json": `{"routingTable": {"globex": "tier1"}}`})
+	loader.tryReload()
+	if cfg := loader.GetConfig(); cfg.Version != "v2" || cfg.RoutingTable["globex"] != "tier1" {
+		t.Errorf("config after fixing fragment = %+v, want v2 with globex", cfg)
+	}
+}
+
+func TestSource_Formats(t *testing.T) {
+	dir := t.TempDir()
+	writeFragments(t, dir, map[string]string{
+		"_base.toml":  "version = \"v1\"\ndefaultPlacement = \"tier1\"\n[placements.tier1]\nurl = \"http://cell-tier1:9001\"\n",
+		"acme.yaml":   "routingTable:\n  acme: tier1\n",
+		"globex.json": `{"routingTable": {"globex": "tier1"}}`,
+	})
+	fetched, err := NewDirectorySource(dir).Fetch("")
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if cfg := fetched.Config; cfg.Validate() != nil || len(cfg.RoutingTable) != 2 {
+		t.Errorf("merged config = %+v, want acme and globex on tier1", cfg)
+	}
+
+	path := filepath.Join(dir, "acme.yaml")
+	if _, err := NewFileSource(path).Write([]byte(`{}`)); !errors.Is(err, ErrReadOnlySource) {
+		t.Errorf("Write(yaml) error = %v, want ErrReadOnlySource", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write([]byte("version: v1\nrouteingTable: {}\n"))
+	}))
+	defer server.Close()
+	if _, err := NewHTTPSource(server.URL).Fetch(""); err == nil || !strings.Contains(err.Error(), `$.routeingTable: unknown field (did you mean "routingTable"?)`) {
+		t.Errorf("Fetch(yaml) error = %v, want unknown field routeingTable", err)
+	}
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseTOML decodes the subset of TOML config files need into the values encoding/json produces
+//
+// Supported: [table] headers, dotted and quoted keys, basic and literal strings, integers,
+// floats, booleans, arrays and inline tables (which may span lines). Arrays of tables,
+// multi-line strings and dates are rejected, as is defining a key or table twice
+func parseTOML(data []byte) (interface{}, error) {
+	root := make(map[string]interface{})
+	current := root
+	headers := make(map[string]bool)
+
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		text := strings.TrimSpace(stripComment(strings.TrimRight(lines[i], "\r")))
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			if strings.HasPrefix(text, "[[") {
+				return nil, fmt.Errorf("toml: line %d: arrays of tables are not supported", number)
+			}
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("toml: line %d: unterminated table header", number)
+			}
+			keys, err := parseTOMLKey(text[1 : len(text)-1])
+			if err != nil {
+				return nil, fmt.Errorf("toml: line %d: %w", number, err)
+			}
+			header := strings.Join(keys, "\x00")
+			if headers[header] {
+				return nil, fmt.Errorf("toml: line %d: table [%s] is defined twice", number, strings.Join(keys, "."))
+			}
+			headers[header] = true
+			if current, err = tomlTable(root, keys); err != nil {
+				return nil, fmt.Errorf("toml: line %d: %w", number, err)
+			}
+			continue
+		}
+
+		// Arrays and inline tables may continue on the following lines
+		for bracketDepth(text) > 0 && i+1 < len(lines) {
+			i++
+			text += " " + strings.TrimSpace(stripComment(strings.TrimRight(lines[i], "\r")))
+		}
+		if err := parseTOMLKeyValue(current, text); err != nil {
+			return nil, fmt.Errorf("toml: line %d: %w", number, err)
+		}
+	}
+	return root, nil
+}
+
+// parseTOMLKeyValue parses "key = value" into table
+func parseTOMLKeyValue(table map[string]interface{}, text string) error {
+	keyText, valueText, ok := cutUnquoted(text, '=')
+	if !ok {
+		return fmt.Errorf("expected \"key = value\"")
+	}
+	keys, err := parseTOMLKey(keyText)
+	if err != nil {
+		return err
+	}
+
+	value := &tomlValue{text: valueText}
+	parsed, err := value.parse()
+	if err != nil {
+		return err
+	}
+	value.skipSpace()
+	if value.pos < len(value.text) {
+		return fmt.Errorf("unexpected %q after value", value.text[value.pos:])
+	}
+
+	parent, err := tomlTable(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	key := keys[len(keys)-1]
+	if _, exists := parent[key]; exists {
+		return fmt.Errorf("key %q is defined twice", strings.Join(keys, "."))
+	}
+	parent[key] = parsed
+	return nil
+}
+
+// tomlTable returns the table at a key path, creating missing tables
+func tomlTable(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	table := root
+	for i, key := range keys {
+		existing, exists := table[key]
+		if !exists {
+			child := make(map[string]interface{})
+			table[key] = child
+			table = child
+			continue
+		}
+		child, ok := existing.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("key %q is already defined as a value", strings.Join(keys[:i+1], "."))
+		}
+		table = child
+	}
+	return table, nil
+}
+
+var (
+	tomlBareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlIntPattern     = regexp.MustCompile(`^([-+]?(0|[1-9](_?[0-9])*)|0x[0-9A-Fa-f](_?[0-9A-Fa-f])*|0o[0-7](_?[0-7])*|0b[01](_?[01])*)$`)
+	tomlFloatPattern   = regexp.MustCompile(`^[-+]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][-+]?[0-9](_?[0-9])*)?$`)
+)
+
+// parseTOMLKey splits a dotted key, unquoting quoted parts
+func parseTOMLKey(text string) ([]string, error) {
+	var keys []string
+	for {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, fmt.Errorf("empty key")
+		}
+
+		var key string
+		if text[0] == '"' || text[0] == '\'' {
+			value, rest, err := parseTOMLString(text)
+			if err != nil {
+				return nil, err
+			}
+			key, text = value, strings.TrimSpace(rest)
+		} else {
+			end := strings.IndexByte(text, '.')
+			if end < 0 {
+				end = len(text)
+			}
+			key, text = strings.TrimSpace(text[:end]), text[end:]
+			if !tomlBareKeyPattern.MatchString(key) {
+				return nil, fmt.Errorf("invalid key %q", key)
+			}
+		}
+		keys = append(keys, key)
+
+		if text == "" {
+			return keys, nil
+		}
+		if text[0] != '.' {
+			return nil, fmt.Errorf("unexpected %q in key", text)
+		}
+		text = text[1:]
+	}
+}
+
+// parseTOMLString parses a basic or literal string at the start of text, returning the text after it
+func parseTOMLString(text string) (string, string, error) {
+	if strings.HasPrefix(text, `"""`) || strings.HasPrefix(text, "'''") {
+		return "", "", fmt.Errorf("multi-line strings are not supported")
+	}
+	if text[0] == '\'' {
+		end := strings.IndexByte(text[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return text[1 : end+1], text[end+2:], nil
+	}
+
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", text[:i+1])
+			}
+			return value, text[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// cutUnquoted splits text around the first separator outside quotes
+func cutUnquoted(text string, separator byte) (string, string, bool) {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == separator:
+			return text[:i], text[i+1:], true
+		}
+	}
+	return text, "", false
+}
+
+// tomlValue parses a value, including the arrays and inline tables nested in it
+type tomlValue struct {
+	text string
+	pos  int
+}
+
+func (v *tomlValue) skipSpace() {
+	for v.pos < len(v.text) && (v.text[v.pos] == ' ' || v.text[v.pos] == '\t') {
+		v.pos++
+	}
+}
+
+func (v *tomlValue) parse() (interface{}, error) {
+	v.skipSpace()
+	if v.pos >= len(v.text) {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	switch v.text[v.pos] {
+	case '"', '\'':
+		value, rest, err := parseTOMLString(v.text[v.pos:])
+		if err != nil {
+			return nil, err
+		}
+		v.pos = len(v.text) - len(rest)
+		return value, nil
+	case '[':
+		return v.array()
+	case '{':
+		return v.inlineTable()
+	}
+
+	start := v.pos
+	for v.pos < len(v.text) && !strings.ContainsRune(",]} \t", rune(v.text[v.pos])) {
+		v.pos++
+	}
+	return parseTOMLScalar(v.text[start:v.pos])
+}
+
+// parseTOMLScalar parses a boolean, integer or float
+func parseTOMLScalar(token string) (interface{}, error) {
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if tomlIntPattern.MatchString(token) {
+		if value, err := strconv.ParseInt(token, 0, 64); err == nil {
+			return value, nil
+		}
+	}
+	if tomlFloatPattern.MatchString(token) {
+		if value, err := strconv.ParseFloat(strings.ReplaceAll(token, "_", ""), 64); err == nil {
+			return value, nil
+		}
+	}
+	if strings.Count(token, "-") >= 2 || strings.Contains(token, ":") {
+		return nil, fmt.Errorf("dates and times are not supported: %s", token)
+	}
+	return nil, fmt.Errorf("invalid value %q (strings must be quoted)", token)
+}
+
+func (v *tomlValue) array() (interface{}, error) {
+	items := []interface{}{}
+	v.pos++ // [
+	for {
+		v.skipSpace()
+		if v.pos < len(v.text) && v.text[v.pos] == ']' {
+			v.pos++
+			return items, nil
+		}
+		item, err := v.parse()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		v.skipSpace()
+		switch {
+		case v.pos < len(v.text) && v.text[v.pos] == ',':
+			v.pos++
+		case v.pos < len(v.text) && v.text[v.pos] == ']':
+		default:
+			return nil, fmt.Errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (v *tomlValue) inlineTable() (interface{}, error) {
+	table := make(map[string]interface{})
+	v.pos++ // {
+	v.skipSpace()
+	if v.pos < len(v.text) && v.text[v.pos] == '}' {
+		v.pos++
+		return table, nil
+	}
+	for {
+		keyText, _, ok := cutUnquoted(v.text[v.pos:], '=')
+		if !ok {
+			return nil, fmt.Errorf("expected \"key = value\" in inline table")
+		}
+		keys, err := parseTOMLKey(keyText)
+		if err != nil {
+			return nil, err
+		}
+		v.pos += len(keyText) + 1
+
+		value, err := v.parse()
+		if err != nil {
+			return nil, err
+		}
+		parent, err := tomlTable(table, keys[:len(keys)-1])
+		if err != nil {
+			return nil, err
+		}
+		key := keys[len(keys)-1]
+		if _, exists := parent[key]; exists {
+			return nil, fmt.Errorf("key %q is defined twice", strings.Join(keys, "."))
+		}
+		parent[key] = value
+
+		v.skipSpace()
+		switch {
+		case v.pos < len(v.text) && v.text[v.pos] == ',':
+			v.pos++
+		case v.pos < len(v.text) && v.text[v.pos] == '}':
+			v.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("expected ',' or '}' in inline table")
+		}
+	}
+}
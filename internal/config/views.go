@@ -0,0 +1,140 @@
+package config
+
+import (
+	"sort"
+)
+
+// ViewConfig selects the part of the config sent to data planes whose labels match Selector
+type ViewConfig struct {
+	Selector         map[string]string `json:"selector"`                    // Labels a data plane must have, e.g. {"region": "eu"}
+	DefaultPlacement string            `json:"default_placement,omitempty"` // Replaces defaultPlacement in the view
+}
+
+// Matches reports whether a data plane's labels include every label of the selector
+func (v *ViewConfig) Matches(labels map[string]string) bool {
+	for key, value := range v.Selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// includesPlacement reports whether a placement belongs to the view
+// Placements without a selector label are shared by every view
+func (v *ViewConfig) includesPlacement(placement *PlacementConfig) bool {
+	if placement == nil {
+		return true
+	}
+	for key, value := range v.Selector {
+		if label, exists := placement.Labels[key]; exists && label != value {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchView returns the name of the view for a data plane's labels, or an empty string if none matches
+// The view with the most selector labels wins; ties go to the first name in sorted order
+func (c *Config) MatchView(labels map[string]string) string {
+	names := make([]string, 0, len(c.Views))
+	for name := range c.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best := ""
+	for _, name := range names {
+		view := c.Views[name]
+		if view.Matches(labels) && (best == "" || len(view.Selector) > len(c.Views[best].Selector)) {
+			best = name
+		}
+	}
+	return best
+}
+
+// ForDataPlane returns the config a data plane with the given labels receives and the name of its view
+// A matching view keeps its placements (and the fallbacks they use), the routing keys mapped to them,
+// and its default placement; without one the data plane gets every placement and routing key.
+// Views themselves are never sent to data planes
+func (c *Config) ForDataPlane(labels map[string]string) (*Config, string, error) {
+	cfg, err := c.Clone()
+	if err != nil {
+		return nil, "", err
+	}
+	cfg.Views = nil
+
+	name := c.MatchView(labels)
+	if name == "" {
+		return cfg, "", nil
+	}
+	view := c.Views[name]
+
+	matching := make(map[string]bool)
+	for placement := range c.GetCellEndpoints() {
+		if view.includesPlacement(c.Placements[placement]) {
+			matching[placement] = true
+		}
+	}
+
+	for routingKey, placement := range cfg.RoutingTable {
+		if !matching[placement] {
+			delete(cfg.RoutingTable, routingKey)
+		}
+	}
+
+	if view.DefaultPlacement != "" {
+		cfg.DefaultPlacement = view.DefaultPlacement
+	}
+
+	// Keep fallbacks reachable from the view so routing to them still works
+	kept := make(map[string]bool)
+	var keep func(placement string)
+	keep = func(placement string) {
+		if placement == "" || kept[placement] {
+			return
+		}
+		kept[placement] = true
+		if p := c.Placements[placement]; p != nil {
+			keep(p.Fallback)
+		}
+	}
+	for placement := range matching {
+		keep(placement)
+	}
+	keep(cfg.DefaultPlacement)
+
+	for placement := range cfg.CellEndpoints {
+		if !kept[placement] {
+			delete(cfg.CellEndpoints, placement)
+		}
+	}
+	for placement := range cfg.Placements {
+		if !kept[placement] {
+			delete(cfg.Placements, placement)
+		}
+	}
+	return cfg, name, nil
+}
+
+// validateViews checks each view has a selector and a default placement inside it
+func (c *Config) validateViews(endpoints map[string]string) error {
+	for _, name := range sortedKeys(c.Views) {
+		view, path := c.Views[name], jsonPath("$.views", name)
+		if view == nil || len(view.Selector) == 0 {
+			return fieldErrorf(jsonPath(path, "selector"), "view '%s' must have a selector", name)
+		}
+
+		defaultPlacement := c.DefaultPlacement
+		if view.DefaultPlacement != "" {
+			if _, exists := endpoints[view.DefaultPlacement]; !exists {
+				return fieldErrorf(jsonPath(path, "default_placement"), "view '%s' references unknown default_placement '%s'", name, view.DefaultPlacement)
+			}
+			defaultPlacement = view.DefaultPlacement
+		}
+		if !view.includesPlacement(c.Placements[defaultPlacement]) {
+			return fieldErrorf(path, "view '%s' excludes default placement '%s'; set its default_placement", name, defaultPlacement)
+		}
+	}
+	return nil
+}
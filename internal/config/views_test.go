@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// regionalConfig has EU and US placements, a shared one, and views per region
+func regionalConfig(t *testing.T) *Config {
+	t.Helper()
+	var cfg Config
+	err := json.Unmarshal([]byte(`{
+		"version": "v1",
+		"routingTable": {"acme": "eu-tier1", "globex": "us-tier1", "initech": "shared"},
+		"placements": {
+			"eu-tier1": {"url": "http://eu-tier1:9001", "fallback": "eu-tier2", "labels": {"region": "eu"}},
+			"eu-tier2": {"url": "http://eu-tier2:9002", "labels": {"region": "eu"}},
+			"us-tier1": {"url": "http://us-tier1:9003", "fallback": "shared", "labels": {"region": "us"}},
+			"shared": {"url": "http://shared:9004"}
+		},
+		"defaultPlacement": "us-tier1",
+		"views": {
+			"eu": {"selector": {"region": "eu"}, "default_placement": "eu-tier1"},
+			"eu-canary": {"selector": {"region": "eu", "environment": "canary"}, "default_placement": "eu-tier2"},
+			"us": {"selector": {"region": "us"}}
+		}
+	}`), &cfg)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	return &cfg
+}
+
+func TestConfig_ForDataPlane(t *testing.T) {
+	tests := []struct {
+		name           string
+		labels         map[string]string
+		wantView       string
+		wantKeys       []string
+		wantPlacements []string
+		wantDefault    string
+	}{
+		{
+			name:           "region view",
+			labels:         map[string]string{"region": "eu", "environment": "prod"},
+			wantView:       "eu",
+			wantKeys:       []string{"acme", "initech"},
+			wantPlacements: []string{"eu-tier1", "eu-tier2", "shared"},
+			wantDefault:    "eu-tier1",
+		},
+		{
+			name:           "most specific view wins",
+			labels:         map[string]string{"region": "eu", "environment": "canary"},
+			wantView:       "eu-canary",
+			wantKeys:       []string{"acme", "initech"},
+			wantPlacements: []string{"eu-tier1", "eu-tier2", "shared"},
+			wantDefault:    "eu-tier2",
+		},
+		{
+			name:           "view keeps the global default placement",
+			labels:         map[string]string{"region": "us"},
+			wantView:       "us",
+			wantKeys:       []string{"globex", "initech"},
+			wantPlacements: []string{"shared", "us-tier1"},
+			wantDefault:    "us-tier1",
+		},
+		{
+			name:           "no matching view",
+			labels:         map[string]string{"region": "apac"},
+			wantKeys:       []string{"acme", "globex", "initech"},
+			wantPlacements: []string{"eu-tier1", "eu-tier2", "shared", "us-tier1"},
+			wantDefault:    "us-tier1",
+		},
+		{
+			name:           "no labels",
+			wantKeys:       []string{"acme", "globex", "initech"},
+			wantPlacements: []string{"eu-tier1", "eu-tier2", "shared", "us-tier1"},
+			wantDefault:    "us-tier1",
+		},
+	}
+
+	cfg := regionalConfig(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			view, name, err := cfg.ForDataPlane(tt.labels)
+			if err != nil {
+				t.Fatalf("ForDataPlane failed: %v", err)
+			}
+			if name != tt.wantView {
+				t.Errorf("view = %q, want %q", name, tt.wantView)
+			}
+			if keys := sortedKeys(view.RoutingTable); !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("routing keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if placements := sortedKeys(view.Placements); !reflect.DeepEqual(placements, tt.wantPlacements) {
+				t.Errorf("placements = %v, want %v", placements, tt.wantPlacements)
+			}
+			if view.DefaultPlacement != tt.wantDefault {
+				t.Errorf("default placement = %s, want %s", view.DefaultPlacement, tt.wantDefault)
+			}
+			if view.Views != nil {
+				t.Error("view carries the views")
+			}
+			if err := view.Validate(); err != nil {
+				t.Errorf("view is invalid: %v", err)
+			}
+		})
+	}
+
+	if len(cfg.RoutingTable) != 3 || len(cfg.Placements) != 4 {
+		t.Error("ForDataPlane modified the full config")
+	}
+}
+
+func TestValidate_Views(t *testing.T) {
+	tests := []struct {
+		name    string
+		view    *ViewConfig
+		wantErr string
+	}{
+		{name: "empty selector", view: &ViewConfig{}, wantErr: "must have a selector"},
+		{name: "unknown default placement", view: &ViewConfig{Selector: map[string]string{"region": "eu"}, DefaultPlacement: "tier9"}, wantErr: "unknown default_placement"},
+		{name: "default placement outside the view", view: &ViewConfig{Selector: map[string]string{"region": "eu"}, DefaultPlacement: "us-tier1"}, wantErr: "excludes default placement 'us-tier1'"},
+		{name: "global default placement outside the view", view: &ViewConfig{Selector: map[string]string{"region": "eu"}}, wantErr: "excludes default placement 'us-tier1'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := regionalConfig(t)
+			cfg.Views["broken"] = tt.view
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
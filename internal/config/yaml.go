@@ -0,0 +1,460 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// parseYAML decodes the subset of YAML config files need into the values encoding/json produces
+//
+// Supported: block mappings and sequences, flow mappings and sequences (which may span lines, so
+// JSON documents parse too), plain, single- and double-quoted scalars, and comments.
+// Anchors, aliases, tags, block scalars (| and >) and multiple documents are rejected.
+// Plain scalars resolve like YAML 1.2: null, true/false, integers and floats; anything else is a string
+func parseYAML(data []byte) (interface{}, error) {
+	lines, err := yamlLines(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("yaml: empty document")
+	}
+
+	p := &yamlParser{lines: lines}
+	value, err := p.parseNode(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, p.errorf(p.lines[p.pos], "unexpected indentation")
+	}
+	return value, nil
+}
+
+// yamlLine is a non-blank line with its comment removed
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlLines splits a document into lines, skipping blank lines, comments and document markers
+func yamlLines(document string) ([]yamlLine, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(document, "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed in indentation", i+1)
+		}
+		text = strings.TrimRight(stripComment(text), " \t")
+		if text == "" {
+			continue
+		}
+		if text == "---" || text == "..." {
+			if len(lines) > 0 {
+				return nil, fmt.Errorf("yaml: line %d: multiple documents are not supported", i+1)
+			}
+			continue
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+	return lines, nil
+}
+
+// stripComment removes a # comment outside quotes from a line
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// bracketDepth returns how many [ and { brackets outside quotes a text leaves open
+func bracketDepth(text string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(line yamlLine, format string, args ...interface{}) error {
+	return fmt.Errorf("yaml: line %d: %s", line.number, fmt.Sprintf(format, args...))
+}
+
+// parseNode parses the block node starting at the current line
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	switch {
+	case isSequenceItem(line.text):
+		return p.parseSequence(indent)
+	case strings.HasPrefix(line.text, "{") || strings.HasPrefix(line.text, "["):
+		p.pos++
+		return p.parseFlow(line, line.text)
+	}
+	return p.parseMapping(indent)
+}
+
+// parseMapping parses "key: value" lines at the given indentation
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	mapping := make(map[string]interface{})
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(line, "unexpected indentation")
+		}
+		if isSequenceItem(line.text) {
+			return nil, p.errorf(line, "unexpected sequence item in a mapping")
+		}
+
+		key, rest, ok := splitMappingKey(line.text)
+		if !ok {
+			return nil, p.errorf(line, "expected \"key: value\"")
+		}
+		if _, exists := mapping[key]; exists {
+			return nil, p.errorf(line, "duplicate key %q", key)
+		}
+		p.pos++
+
+		var value interface{}
+		var err error
+		if rest != "" {
+			value, err = p.parseInline(line, rest)
+		} else if p.pos < len(p.lines) {
+			// A nested block, or a sequence at the key's own indentation
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isSequenceItem(next.text)) {
+				value, err = p.parseNode(next.indent)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		mapping[key] = value
+	}
+	return mapping, nil
+}
+
+// parseSequence parses "- item" lines at the given indentation
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := &p.lines[p.pos]
+		if line.indent < indent || (line.indent == indent && !isSequenceItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return nil, p.errorf(*line, "unexpected indentation")
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		var item interface{}
+		var err error
+		switch _, _, isMapping := splitMappingKey(rest); {
+		case rest == "":
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				item, err = p.parseNode(p.lines[p.pos].indent)
+			}
+		case isMapping && !strings.HasPrefix(rest, "{") && !strings.HasPrefix(rest, "["):
+			// "- key: value" starts a mapping indented to the key
+			line.indent += len(line.text) - len(rest)
+			line.text = rest
+			item, err = p.parseMapping(line.indent)
+		default:
+			p.pos++
+			item, err = p.parseInline(*line, rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseInline parses a value written on the same line as its key or sequence dash
+func (p *yamlParser) parseInline(line yamlLine, text string) (interface{}, error) {
+	switch text[0] {
+	case '{', '[':
+		return p.parseFlow(line, text)
+	case '|', '>':
+		return nil, p.errorf(line, "block scalars are not supported")
+	case '&', '*':
+		return nil, p.errorf(line, "anchors and aliases are not supported")
+	case '!':
+		return nil, p.errorf(line, "tags are not supported")
+	case '"', '\'':
+		value, rest, err := parseQuoted(text)
+		if err != nil {
+			return nil, p.errorf(line, "%v", err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, p.errorf(line, "unexpected %q after quoted string", rest)
+		}
+		return value, nil
+	}
+	return resolvePlain(text), nil
+}
+
+// parseFlow parses a flow collection, joining the following lines until its brackets close
+func (p *yamlParser) parseFlow(line yamlLine, text string) (interface{}, error) {
+	for bracketDepth(text) > 0 && p.pos < len(p.lines) {
+		text += " " + p.lines[p.pos].text
+		p.pos++
+	}
+
+	flow := &yamlFlow{text: text}
+	value, err := flow.value()
+	if err == nil {
+		flow.skipSpace()
+		if flow.pos < len(flow.text) {
+			err = fmt.Errorf("unexpected %q after flow collection", flow.text[flow.pos:])
+		}
+	}
+	if err != nil {
+		return nil, p.errorf(line, "%v", err)
+	}
+	return value, nil
+}
+
+// isSequenceItem reports whether a line starts a block sequence item
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitMappingKey splits "key: value" into its key and value text
+func splitMappingKey(text string) (key, rest string, ok bool) {
+	if text == "" {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		key, after, err := parseQuoted(text)
+		if err != nil || !strings.HasPrefix(after, ":") {
+			return "", "", false
+		}
+		after = after[1:]
+		if after != "" && after[0] != ' ' {
+			return "", "", false
+		}
+		return key.(string), strings.TrimSpace(after), true
+	}
+
+	if i := strings.Index(text, ": "); i > 0 {
+		return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:]), true
+	}
+	if strings.HasSuffix(text, ":") && len(text) > 1 {
+		return strings.TrimSpace(text[:len(text)-1]), "", true
+	}
+	return "", "", false
+}
+
+// parseQuoted parses a single- or double-quoted scalar at the start of text, returning the text after it
+func parseQuoted(text string) (interface{}, string, error) {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++ // '' is an escaped single quote
+		case text[i] == quote:
+			if quote == '\'' {
+				return strings.ReplaceAll(text[1:i], "''", "'"), text[i+1:], nil
+			}
+			value, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid double-quoted string %s", text[:i+1])
+			}
+			return value, text[i+1:], nil
+		}
+	}
+	return nil, "", fmt.Errorf("unterminated quoted string")
+}
+
+var (
+	yamlIntPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatPattern = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolvePlain resolves a plain scalar to null, a boolean, a number or a string
+func resolvePlain(text string) interface{} {
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if yamlIntPattern.MatchString(text) {
+		if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return value
+		}
+	}
+	if yamlFloatPattern.MatchString(text) {
+		if value, err := strconv.ParseFloat(text, 64); err == nil {
+			return value
+		}
+	}
+	return text
+}
+
+// yamlFlow parses a flow collection such as {region: eu, tier: "1"} or [a, b]
+type yamlFlow struct {
+	text string
+	pos  int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.text) && (f.text[f.pos] == ' ' || f.text[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+// value parses a flow collection or scalar, stopping at a delimiter of the enclosing collection
+func (f *yamlFlow) value() (interface{}, error) {
+	f.skipSpace()
+	if f.pos >= len(f.text) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.text[f.pos] {
+	case '{':
+		return f.mapping()
+	case '[':
+		return f.sequence()
+	}
+	return f.scalar(",]}")
+}
+
+// scalar parses a quoted scalar, or a plain one ending before any of the stop characters
+func (f *yamlFlow) scalar(stop string) (interface{}, error) {
+	if c := f.text[f.pos]; c == '"' || c == '\'' {
+		value, rest, err := parseQuoted(f.text[f.pos:])
+		if err != nil {
+			return nil, err
+		}
+		f.pos = len(f.text) - len(rest)
+		return value, nil
+	}
+	start := f.pos
+	for f.pos < len(f.text) && !strings.ContainsRune(stop, rune(f.text[f.pos])) {
+		f.pos++
+	}
+	return resolvePlain(strings.TrimSpace(f.text[start:f.pos])), nil
+}
+
+func (f *yamlFlow) mapping() (interface{}, error) {
+	mapping := make(map[string]interface{})
+	f.pos++ // {
+	for {
+		f.skipSpace()
+		if f.pos < len(f.text) && f.text[f.pos] == '}' {
+			f.pos++
+			return mapping, nil
+		}
+		if f.pos >= len(f.text) {
+			return nil, fmt.Errorf("unterminated flow collection")
+		}
+
+		key, err := f.scalar(":,}")
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos >= len(f.text) || f.text[f.pos] != ':' {
+			return nil, fmt.Errorf("expected ':' after key %v in flow mapping", key)
+		}
+		f.pos++
+		value, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprint(key)
+		if _, exists := mapping[name]; exists {
+			return nil, fmt.Errorf("duplicate key %q", name)
+		}
+		mapping[name] = value
+
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *yamlFlow) sequence() (interface{}, error) {
+	items := []interface{}{}
+	f.pos++ // [
+	for {
+		f.skipSpace()
+		if f.pos < len(f.text) && f.text[f.pos] == ']' {
+			f.pos++
+			return items, nil
+		}
+		if f.pos >= len(f.text) {
+			return nil, fmt.Errorf("unterminated flow collection")
+		}
+
+		item, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes the comma between entries, leaving a closing bracket for the caller
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	switch {
+	case f.pos < len(f.text) && f.text[f.pos] == ',':
+		f.pos++
+		return nil
+	case f.pos < len(f.text) && f.text[f.pos] == closing:
+		return nil
+	case f.pos >= len(f.text):
+		return fmt.Errorf("unterminated flow collection")
+	}
+	return fmt.Errorf("expected ',' or '%c' in flow collection", closing)
+}
@@ -0,0 +1,476 @@
+package controlplane
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+var (
+	// errNotFound is returned by config changes targeting a missing routing key or placement
+	errNotFound = errors.New("not found")
+	// errUnsupported is returned by config changes the active config format cannot express
+	errUnsupported = errors.New("unsupported change")
+)
+
+// routingKeyRequest is the body of a routing key PUT and the shape of its responses
+type routingKeyRequest struct {
+	RoutingKey string `json:"routing_key"`
+	Placement  string `json:"placement"`
+}
+
+// rollbackRequest is the body of a rollback request
+type rollbackRequest struct {
+	Version string `json:"version"`
+}
+
+// APIHandler serves the versioned REST API for routing table and placement changes
+// Writes require If-Match with the active config version (as returned in ETag) and are served by the leader only
+type APIHandler struct {
+	server *Server
+	mux    *http.ServeMux
+}
+
+// NewAPIHandler creates an API handler that persists changes through the server's loader
+func NewAPIHandler(server *Server) *APIHandler {
+	h := &APIHandler{
+		server: server,
+		mux:    http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET /api/v1/config", h.handleGetConfig)
+	h.mux.HandleFunc("GET /api/v1/routing-keys/{key}", h.handleGetRoutingKey)
+	h.mux.HandleFunc("PUT /api/v1/routing-keys/{key}", h.handlePutRoutingKey)
+	h.mux.HandleFunc("DELETE /api/v1/routing-keys/{key}", h.handleDeleteRoutingKey)
+	h.mux.HandleFunc("GET /api/v1/placements/{placement}", h.handleGetPlacement)
+	h.mux.HandleFunc("PUT /api/v1/placements/{placement}", h.handlePutPlacement)
+	h.mux.HandleFunc("DELETE /api/v1/placements/{placement}", h.handleDeletePlacement)
+	h.mux.HandleFunc("GET /api/v1/history", h.handleGetHistory)
+	h.mux.HandleFunc("GET /api/v1/history/{version}", h.handleGetHistoryVersion)
+	h.mux.HandleFunc("GET /api/v1/diff", h.handleDiff)
+	h.mux.HandleFunc("POST /api/v1/rollback", h.handleRollback)
+	h.mux.HandleFunc("GET /api/v1/rollout", h.handleGetRollout)
+	h.mux.HandleFunc("POST /api/v1/rollout/pause", h.handleControlRollout(h.server.PauseRollout))
+	h.mux.HandleFunc("POST /api/v1/rollout/resume", h.handleControlRollout(h.server.ResumeRollout))
+	h.mux.HandleFunc("POST /api/v1/rollout/abort", h.handleControlRollout(h.server.AbortRollout))
+	h.mux.HandleFunc("GET /api/v1/revoked-data-planes", h.handleGetRevoked)
+	h.mux.HandleFunc("PUT /api/v1/revoked-data-planes/{id}", h.handleRevoke)
+	h.mux.HandleFunc("DELETE /api/v1/revoked-data-planes/{id}", h.handleReinstate)
+	h.mux.HandleFunc("POST /api/v1/data-planes/{id}/credential", h.handleIssueCredential)
+
+	return h
+}
+
+// ServeHTTP handles /api/v1/ requests
+func (h *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// handleGetConfig returns the full active config
+func (h *APIHandler) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := h.server.configLoader.GetConfig()
+	writeJSON(w, cfg.Version, http.StatusOK, cfg)
+}
+
+// handleGetRoutingKey returns the placement a routing key maps to
+func (h *APIHandler) handleGetRoutingKey(w http.ResponseWriter, r *http.Request) {
+	cfg := h.server.configLoader.GetConfig()
+	routingKey := r.PathValue("key")
+
+	placement, exists := cfg.RoutingTable[routingKey]
+	if !exists {
+		http.Error(w, fmt.Sprintf("routing key %s not found", routingKey), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, cfg.Version, http.StatusOK, routingKeyRequest{RoutingKey: routingKey, Placement: placement})
+}
+
+// handlePutRoutingKey maps a routing key to a placement
+func (h *APIHandler) handlePutRoutingKey(w http.ResponseWriter, r *http.Request) {
+	routingKey := r.PathValue("key")
+
+	var req routingKeyRequest
+	if !decodeBody(w, r, func(data []byte) error { return config.DecodeStrict(data, &req) }) {
+		return
+	}
+	if req.Placement == "" {
+		http.Error(w, "Bad Request: placement is required", http.StatusBadRequest)
+		return
+	}
+
+	cfg, ok := h.update(w, r, func(cfg *config.Config) error {
+		if cfg.RoutingTable == nil {
+			cfg.RoutingTable = make(map[string]string)
+		}
+		cfg.RoutingTable[routingKey] = req.Placement
+		return nil
+	})
+	if ok {
+		writeJSON(w, cfg.Version, http.StatusOK, routingKeyRequest{RoutingKey: routingKey, Placement: req.Placement})
+	}
+}
+
+// handleDeleteRoutingKey removes a routing key, sending its traffic to the default placement
+func (h *APIHandler) handleDeleteRoutingKey(w http.ResponseWriter, r *http.Request) {
+	routingKey := r.PathValue("key")
+
+	cfg, ok := h.update(w, r, func(cfg *config.Config) error {
+		if _, exists := cfg.RoutingTable[routingKey]; !exists {
+			return fmt.Errorf("%w: routing key %s", errNotFound, routingKey)
+		}
+		delete(cfg.RoutingTable, routingKey)
+		return nil
+	})
+	if ok {
+		w.Header().Set("ETag", etag(cfg.Version))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleGetPlacement returns a placement's config
+func (h *APIHandler) handleGetPlacement(w http.ResponseWriter, r *http.Request) {
+	cfg := h.server.configLoader.GetConfig()
+	placementKey := r.PathValue("placement")
+
+	if placementCfg, exists := cfg.GetPlacementConfig(placementKey); exists {
+		writeJSON(w, cfg.Version, http.StatusOK, placementCfg)
+		return
+	}
+	if endpointURL, exists := cfg.CellEndpoints[placementKey]; exists {
+		writeJSON(w, cfg.Version, http.StatusOK, config.PlacementConfig{URL: endpointURL})
+		return
+	}
+	http.Error(w, fmt.Sprintf("placement %s not found", placementKey), http.StatusNotFound)
+}
+
+// handlePutPlacement creates or replaces a placement
+func (h *APIHandler) handlePutPlacement(w http.ResponseWriter, r *http.Request) {
+	placementKey := r.PathValue("placement")
+
+	var placementCfg *config.PlacementConfig
+	if !decodeBody(w, r, func(data []byte) (err error) {
+		placementCfg, err = config.ParsePlacement(data)
+		return err
+	}) {
+		return
+	}
+
+	cfg, ok := h.update(w, r, func(cfg *config.Config) error {
+		if len(cfg.CellEndpoints) > 0 {
+			// Legacy configs only carry endpoint URLs
+			if !isURLOnly(placementCfg) {
+				return fmt.Errorf("%w: config uses legacy cellEndpoints, only url can be set", errUnsupported)
+			}
+			cfg.CellEndpoints[placementKey] = placementCfg.URL
+			return nil
+		}
+		if cfg.Placements == nil {
+			cfg.Placements = make(map[string]*config.PlacementConfig)
+		}
+		cfg.Placements[placementKey] = placementCfg
+		return nil
+	})
+	if ok {
+		writeJSON(w, cfg.Version, http.StatusOK, placementCfg)
+	}
+}
+
+// handleDeletePlacement removes a placement; validation rejects it while still referenced
+func (h *APIHandler) handleDeletePlacement(w http.ResponseWriter, r *http.Request) {
+	placementKey := r.PathValue("placement")
+
+	cfg, ok := h.update(w, r, func(cfg *config.Config) error {
+		if _, exists := cfg.CellEndpoints[placementKey]; exists {
+			delete(cfg.CellEndpoints, placementKey)
+			return nil
+		}
+		if _, exists := cfg.Placements[placementKey]; exists {
+			delete(cfg.Placements, placementKey)
+			return nil
+		}
+		return fmt.Errorf("%w: placement %s", errNotFound, placementKey)
+	})
+	if ok {
+		w.Header().Set("ETag", etag(cfg.Version))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleGetHistory lists recorded config versions, newest first
+func (h *APIHandler) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	history, ok := h.history(w)
+	if !ok {
+		return
+	}
+	writeJSON(w, h.server.configLoader.GetConfigVersion(), http.StatusOK, map[string]interface{}{
+		"entries": history.Entries(),
+	})
+}
+
+// handleGetHistoryVersion returns a recorded config
+func (h *APIHandler) handleGetHistoryVersion(w http.ResponseWriter, r *http.Request) {
+	cfg, ok := h.historyConfig(w, r.PathValue("version"))
+	if ok {
+		writeJSON(w, h.server.configLoader.GetConfigVersion(), http.StatusOK, cfg)
+	}
+}
+
+// handleDiff returns the changes between two recorded versions (?from=&to=, to defaults to the active version)
+func (h *APIHandler) handleDiff(w http.ResponseWriter, r *http.Request) {
+	fromVersion := r.URL.Query().Get("from")
+	if fromVersion == "" {
+		http.Error(w, "Bad Request: from is required", http.StatusBadRequest)
+		return
+	}
+	toVersion := r.URL.Query().Get("to")
+	if toVersion == "" {
+		toVersion = h.server.configLoader.GetConfigVersion()
+	}
+
+	from, ok := h.historyConfig(w, fromVersion)
+	if !ok {
+		return
+	}
+	to, ok := h.historyConfig(w, toVersion)
+	if !ok {
+		return
+	}
+
+	changes, err := config.Diff(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, h.server.configLoader.GetConfigVersion(), http.StatusOK, map[string]interface{}{
+		"from":    fromVersion,
+		"to":      toVersion,
+		"changes": changes,
+	})
+}
+
+// handleRollback restores a recorded config as a new version
+// The restored content gets the next version so data planes and history see it as a change
+func (h *APIHandler) handleRollback(w http.ResponseWriter, r *http.Request) {
+	var req rollbackRequest
+	if !decodeBody(w, r, func(data []byte) error { return config.DecodeStrict(data, &req) }) {
+		return
+	}
+	if req.Version == "" {
+		http.Error(w, "Bad Request: version is required", http.StatusBadRequest)
+		return
+	}
+
+	target, ok := h.historyConfig(w, req.Version)
+	if !ok {
+		return
+	}
+
+	cfg, ok := h.update(w, r, func(cfg *config.Config) error {
+		restored, err := target.Clone()
+		if err != nil {
+			return err
+		}
+		*cfg = *restored
+		return nil
+	})
+	if ok {
+		writeJSON(w, cfg.Version, http.StatusOK, cfg)
+	}
+}
+
+// history returns the server's config history, writing 404 if history is disabled
+func (h *APIHandler) history(w http.ResponseWriter) (*config.History, bool) {
+	history := h.server.configLoader.History()
+	if history == nil {
+		http.Error(w, "config history is disabled", http.StatusNotFound)
+		return nil, false
+	}
+	return history, true
+}
+
+// historyConfig returns a recorded config, writing 404 if it is not in history
+func (h *APIHandler) historyConfig(w http.ResponseWriter, version string) (*config.Config, bool) {
+	history, ok := h.history(w)
+	if !ok {
+		return nil, false
+	}
+	cfg, _, exists := history.Get(version)
+	if !exists {
+		http.Error(w, fmt.Sprintf("version %s not found in history", version), http.StatusNotFound)
+		return nil, false
+	}
+	return cfg, true
+}
+
+// handleGetRollout returns the latest staged rollout
+func (h *APIHandler) handleGetRollout(w http.ResponseWriter, r *http.Request) {
+	status, exists := h.server.RolloutStatus()
+	if !exists {
+		http.Error(w, "no rollout has started", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, h.server.configLoader.GetConfigVersion(), http.StatusOK, status)
+}
+
+// handleControlRollout returns a handler that pauses, resumes or aborts the active rollout
+func (h *APIHandler) handleControlRollout(control func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.server.requireLeader(w) {
+			return
+		}
+		if err := control(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.handleGetRollout(w, r)
+	}
+}
+
+// handleGetRevoked lists revoked data plane IDs
+func (h *APIHandler) handleGetRevoked(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.server.configLoader.GetConfigVersion(), http.StatusOK, map[string]interface{}{
+		"revoked": h.server.RevokedDataPlanes(),
+	})
+}
+
+// handleRevoke revokes a data plane, disconnecting it and refusing its future connections
+func (h *APIHandler) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := h.server.RevokeDataPlane(r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.handleGetRevoked(w, r)
+}
+
+// handleReinstate allows a revoked data plane to connect again
+func (h *APIHandler) handleReinstate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	reinstated, err := h.server.ReinstateDataPlane(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !reinstated {
+		http.Error(w, fmt.Sprintf("data plane %s is not revoked", id), http.StatusNotFound)
+		return
+	}
+	h.handleGetRevoked(w, r)
+}
+
+// handleIssueCredential issues a data plane a new token credential, invalidating its previous one
+func (h *APIHandler) handleIssueCredential(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	credential, err := h.server.IssueCredential(id)
+	if errors.Is(err, ErrTokenAuthDisabled) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, h.server.configLoader.GetConfigVersion(), http.StatusCreated, map[string]interface{}{
+		"id":         id,
+		"credential": credential,
+	})
+}
+
+// update applies a config change guarded by If-Match and publishes the result
+// Writes an error response and returns false if the change is not applied
+func (h *APIHandler) update(w http.ResponseWriter, r *http.Request, change func(cfg *config.Config) error) (*config.Config, bool) {
+	if !h.server.requireLeader(w) {
+		return nil, false
+	}
+	expectedVersion, ok := parseIfMatch(r.Header.Get("If-Match"))
+	if !ok {
+		http.Error(w, "Precondition Required: If-Match with the current config version is required", http.StatusPreconditionRequired)
+		return nil, false
+	}
+	if expectedVersion == "*" {
+		expectedVersion = h.server.configLoader.GetConfigVersion()
+	}
+
+	cfg, err := h.server.configLoader.Update(expectedVersion, change)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, config.ErrVersionConflict):
+			status = http.StatusPreconditionFailed
+			w.Header().Set("ETag", etag(h.server.configLoader.GetConfigVersion()))
+		case errors.Is(err, config.ErrInvalidConfig), errors.Is(err, errUnsupported):
+			status = http.StatusUnprocessableEntity
+		case errors.Is(err, errNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, config.ErrReadOnlySource):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return nil, false
+	}
+
+	log.Printf("Config version %s written via API (%s %s), publishing to data planes", cfg.Version, r.Method, r.URL.Path)
+	h.server.PublishConfig()
+	return cfg, true
+}
+
+// isURLOnly reports whether a placement config sets nothing but its URL
+func isURLOnly(placementCfg *config.PlacementConfig) bool {
+	full, err := json.Marshal(placementCfg)
+	if err != nil {
+		return false
+	}
+	urlOnly, err := json.Marshal(config.PlacementConfig{URL: placementCfg.URL})
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(full, urlOnly)
+}
+
+// parseIfMatch extracts the version from an If-Match header
+func parseIfMatch(header string) (string, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return "", false
+	}
+	if header == "*" {
+		return header, true
+	}
+	header = strings.TrimPrefix(header, "W/")
+	return strings.Trim(header, `"`), true
+}
+
+// etag formats a config version as an entity tag
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+// maxAPIBody caps the size of API request bodies
+const maxAPIBody = 1 << 20
+
+// decodeBody reads a request body and passes it to decode, writing 400 with the offending JSON path if it fails
+func decodeBody(w http.ResponseWriter, r *http.Request, decode func(data []byte) error) bool {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAPIBody))
+	if err == nil {
+		err = decode(data)
+	}
+	if err != nil {
+		http.Error(w, "Bad Request: invalid JSON body: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// writeJSON writes a JSON response tagged with the config version
+func writeJSON(w http.ResponseWriter, version string, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag(version))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
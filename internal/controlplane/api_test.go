@@ -0,0 +1,193 @@
+package controlplane
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+func TestAPIHandler_RoutingKeys(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	initialConfig := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(initialConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	handler := NewAPIHandler(NewServer(loader))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		ifMatch    string
+		wantStatus int
+		wantETag   string
+	}{
+		{"get routing key", http.MethodGet, "/api/v1/routing-keys/acme", "", "", http.StatusOK, `"v1"`},
+		{"get missing routing key", http.MethodGet, "/api/v1/routing-keys/globex", "", "", http.StatusNotFound, ""},
+		{"put without If-Match", http.MethodPut, "/api/v1/routing-keys/globex", `{"placement":"tier2"}`, "", http.StatusPreconditionRequired, ""},
+		{"put routing key", http.MethodPut, "/api/v1/routing-keys/globex", `{"placement":"tier2"}`, `"v1"`, http.StatusOK, `"v2"`},
+		{"put with stale version", http.MethodPut, "/api/v1/routing-keys/globex", `{"placement":"tier1"}`, `"v1"`, http.StatusPreconditionFailed, `"v2"`},
+		{"put unknown placement", http.MethodPut, "/api/v1/routing-keys/globex", `{"placement":"tier9"}`, `"v2"`, http.StatusUnprocessableEntity, ""},
+		{"delete referenced placement", http.MethodDelete, "/api/v1/placements/tier2", "", `"v2"`, http.StatusUnprocessableEntity, ""},
+		{"put legacy placement with resilience config", http.MethodPut, "/api/v1/placements/tier3", `{"url":"http://cell-tier3:9003","fallback":"tier1"}`, `"v2"`, http.StatusUnprocessableEntity, ""},
+		{"put legacy placement", http.MethodPut, "/api/v1/placements/tier3", `{"url":"http://cell-tier3:9003"}`, `"v2"`, http.StatusOK, `"v3"`},
+		{"delete routing key", http.MethodDelete, "/api/v1/routing-keys/globex", "", `W/"v3"`, http.StatusNoContent, `"v4"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantETag != "" && rec.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("ETag = %s, want %s", rec.Header().Get("ETag"), tt.wantETag)
+			}
+		})
+	}
+
+	onDisk, err := config.LoadFromFile(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if onDisk.Version != "v4" || onDisk.CellEndpoints["tier3"] == "" || len(onDisk.RoutingTable) != 1 {
+		t.Errorf("persisted config = %+v, want v4 with tier3 and only acme routed", onDisk)
+	}
+}
+
+func TestAPIHandler_HistoryAndRollback(t *testing.T) {
+	dir := t.TempDir()
+	tmpFile := dir + "/config.json"
+	initialConfig := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(initialConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	history, err := config.NewHistory(dir+"/history", 10)
+	if err != nil {
+		t.Fatalf("NewHistory failed: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, time.Second)
+	loader.SetHistory(history)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	handler := NewAPIHandler(NewServer(loader))
+
+	do := func(method, path, body, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPut, "/api/v1/routing-keys/acme", `{"placement":"tier2"}`, `"v1"`); rec.Code != http.StatusOK {
+		t.Fatalf("PUT routing key status = %d (%s)", rec.Code, rec.Body.String())
+	}
+
+	rec := do(http.MethodGet, "/api/v1/diff?from=v1", "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `{"path":"routingTable.acme","from":"tier1","to":"tier2"}`) {
+		t.Errorf("GET diff = %d %s, want acme moved to tier2", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodGet, "/api/v1/diff?from=v9", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET diff from unknown version status = %d, want 404", rec.Code)
+	}
+
+	if rec := do(http.MethodPost, "/api/v1/rollback", `{"version":"v1"}`, ""); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("POST rollback without If-Match status = %d, want 428", rec.Code)
+	}
+	rec = do(http.MethodPost, "/api/v1/rollback", `{"version":"v1"}`, `"v2"`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"v3"` {
+		t.Fatalf("POST rollback = %d %s (ETag %s), want 200 with v3", rec.Code, rec.Body.String(), rec.Header().Get("ETag"))
+	}
+	if cfg := loader.GetConfig(); cfg.Version != "v3" || cfg.RoutingTable["acme"] != "tier1" {
+		t.Errorf("active config after rollback = %+v, want v3 routing acme to tier1", cfg)
+	}
+
+	entries := history.Entries()
+	if len(entries) != 3 || entries[0].Version != "v3" {
+		t.Errorf("history = %+v, want v3, v2, v1", entries)
+	}
+}
+
+func TestAPIHandler_RejectsUnknownFields(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	initialConfig := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"placements": {"tier1": {"url": "http://cell-tier1:9001"}},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(initialConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	handler := NewAPIHandler(NewServer(loader))
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		wantErr string
+	}{
+		{"placement typo", "/api/v1/placements/tier2", `{"url":"http://cell-tier2:9002","helth_check":{"path":"/health"}}`, `$.helth_check: unknown field (did you mean "health_check"?)`},
+		{"nested placement typo", "/api/v1/placements/tier2", `{"url":"http://cell-tier2:9002","queue":{"max_lenght":10}}`, `$.queue.max_lenght: unknown field`},
+		{"placement wrong type", "/api/v1/placements/tier2", `{"url":9002}`, `$.url: expected string, got number`},
+		{"routing key typo", "/api/v1/routing-keys/globex", `{"placment":"tier1"}`, `$.placment: unknown field (did you mean "placement"?)`},
+		{"trailing data", "/api/v1/routing-keys/globex", `{"placement":"tier1"} {}`, "unexpected data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("If-Match", `"v1"`)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, http.StatusBadRequest, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantErr) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tt.wantErr)
+			}
+		})
+	}
+
+	if version := loader.GetConfigVersion(); version != "v1" {
+		t.Errorf("config version = %s, want v1 after rejected bodies", version)
+	}
+}
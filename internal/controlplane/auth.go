@@ -0,0 +1,216 @@
+package controlplane
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+var (
+	// ErrUnauthenticated is returned when a data plane presents neither a verified client certificate nor a valid token
+	ErrUnauthenticated = errors.New("data plane not authenticated")
+	// ErrRevoked is returned when an authenticated data plane has been revoked
+	ErrRevoked = errors.New("data plane revoked")
+	// ErrTokenAuthDisabled is returned when issuing a credential while token authentication is off
+	ErrTokenAuthDisabled = errors.New("token authentication is not enabled")
+)
+
+// AuthMethod identifies how a data plane authenticated
+type AuthMethod string
+
+const (
+	AuthMTLS  AuthMethod = "mtls"  // Verified client certificate; the ID is its common name
+	AuthToken AuthMethod = "token" // Bearer token signed with the credential issued to the ID it asserts
+)
+
+// Identity is an authenticated data plane identity
+type Identity struct {
+	ID     string
+	Method AuthMethod
+}
+
+// Authenticator verifies data planes on the WebSocket handshake or config poll
+type Authenticator struct {
+	credentials *CredentialStore // Nil disables token authentication
+	mtls        bool             // Accept verified client certificates
+}
+
+// NewAuthenticator creates an authenticator accepting tokens signed with the credential issued
+// to the data plane (if credentials is set) and, when mtls is true, client certificates verified by the TLS server
+func NewAuthenticator(credentials *CredentialStore, mtls bool) *Authenticator {
+	return &Authenticator{
+		credentials: credentials,
+		mtls:        mtls,
+	}
+}
+
+// Authenticate returns the identity a handshake request proves
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, error) {
+	if a.mtls && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		id := cert.Subject.CommonName
+		if id == "" && len(cert.DNSNames) > 0 {
+			id = cert.DNSNames[0]
+		}
+		if id != "" {
+			return Identity{ID: id, Method: AuthMTLS}, nil
+		}
+	}
+
+	if a.credentials != nil {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			id, err := protocol.VerifyTokenFunc(a.credentials.Secret, token, time.Now())
+			if err != nil {
+				return Identity{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+			}
+			return Identity{ID: id, Method: AuthToken}, nil
+		}
+	}
+
+	return Identity{}, ErrUnauthenticated
+}
+
+// SetAuthenticator requires data planes to authenticate on /connect and /config; nil accepts any data plane
+// Must be called before connections are handled
+func (s *Server) SetAuthenticator(authenticator *Authenticator) {
+	s.authenticator = authenticator
+}
+
+// ConnectHandler authenticates data planes and upgrades /connect requests to WebSocket connections
+func (s *Server) ConnectHandler(upgrader *websocket.Upgrader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := s.authenticate(w, r)
+		if !ok || !s.allowDataPlane(w, r, identity.ID) {
+			return
+		}
+		labels, ok := dataPlaneLabels(w, r)
+		if !ok {
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("Failed to upgrade connection: %v", err)
+			return
+		}
+		s.handleConnection(conn, identity, labels)
+	})
+}
+
+// authenticate returns the identity a data plane request proves, writing 401 if it proves none
+// Without an authenticator every request passes with an empty identity
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (Identity, bool) {
+	if s.authenticator == nil {
+		return Identity{}, true
+	}
+	identity, err := s.authenticator.Authenticate(r)
+	if err != nil {
+		log.Printf("Rejected data plane connection from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return Identity{}, false
+	}
+	return identity, true
+}
+
+// allowDataPlane writes 403 and returns false if the data plane ID has been revoked
+func (s *Server) allowDataPlane(w http.ResponseWriter, r *http.Request, id string) bool {
+	if id == "" || !s.IsRevoked(id) {
+		return true
+	}
+	log.Printf("Rejected revoked data plane %s from %s", id, r.RemoteAddr)
+	http.Error(w, "Forbidden: "+ErrRevoked.Error(), http.StatusForbidden)
+	return false
+}
+
+// dataPlaneLabels parses the labels a data plane declares, writing 400 if they are malformed
+func dataPlaneLabels(w http.ResponseWriter, r *http.Request) (map[string]string, bool) {
+	labels, err := protocol.ParseLabels(r.Header.Get(protocol.HeaderDataPlaneLabels))
+	if err != nil {
+		http.Error(w, "Invalid "+protocol.HeaderDataPlaneLabels+": "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return labels, true
+}
+
+// IssueCredential generates a new token credential for a data plane, replacing its previous one
+func (s *Server) IssueCredential(id string) (string, error) {
+	if s.authenticator == nil || s.authenticator.credentials == nil {
+		return "", ErrTokenAuthDisabled
+	}
+	secret, err := s.authenticator.credentials.Issue(id)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Issued a new credential to data plane %s", id)
+	return secret, nil
+}
+
+// SetRevocationStore keeps revoked data plane IDs in store, which replicas may share
+// Must be called before connections are handled
+func (s *Server) SetRevocationStore(store *RevocationStore) {
+	s.revocations = store
+}
+
+// RevokeDataPlane refuses future connections from a data plane ID, deletes its token credential and disconnects it
+// A reinstated data plane needs a newly issued credential to authenticate with a token
+func (s *Server) RevokeDataPlane(id string) error {
+	if err := s.revocations.Revoke(id); err != nil {
+		return fmt.Errorf("failed to revoke data plane %s: %w", id, err)
+	}
+
+	if s.authenticator != nil && s.authenticator.credentials != nil {
+		if err := s.authenticator.credentials.Revoke(id); err != nil {
+			log.Printf("Failed to revoke credential of data plane %s: %v", id, err)
+		}
+	}
+
+	s.disconnect(func(dpID string) bool { return dpID == id })
+	log.Printf("Data plane %s revoked", id)
+	return nil
+}
+
+// ReinstateDataPlane allows a revoked data plane ID to connect again, returning false if it was not revoked
+func (s *Server) ReinstateDataPlane(id string) (bool, error) {
+	reinstated, err := s.revocations.Reinstate(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to reinstate data plane %s: %w", id, err)
+	}
+	if reinstated {
+		log.Printf("Data plane %s reinstated", id)
+	}
+	return reinstated, nil
+}
+
+// IsRevoked reports whether a data plane ID has been revoked
+func (s *Server) IsRevoked(id string) bool {
+	return s.revocations.IsRevoked(id)
+}
+
+// RevokedDataPlanes returns the revoked data plane IDs in sorted order
+func (s *Server) RevokedDataPlanes() []string {
+	return s.revocations.List()
+}
+
+// disconnectRevoked closes connections of data planes revoked since they connected, including by another replica
+func (s *Server) disconnectRevoked() {
+	s.disconnect(func(id string) bool { return id != "" && s.IsRevoked(id) })
+}
+
+// disconnect closes the connections of data planes whose ID matches
+func (s *Server) disconnect(matches func(id string) bool) {
+	for conn, dp := range s.connectedClients() {
+		s.fleetMutex.Lock()
+		id := dp.id
+		s.fleetMutex.Unlock()
+		if matches(id) {
+			log.Printf("Disconnecting revoked data plane %s", id)
+			conn.Close()
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package controlplane
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// testCA issues client certificates for mTLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test data plane CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate failed: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// clientCert issues a client certificate with the given common name
+func (ca *testCA) clientCert(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func newAuthTestServer(t *testing.T, credentials *CredentialStore, ca *testCA) (*Server, *httptest.Server) {
+	t.Helper()
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+
+	cpServer := NewServer(loader)
+	cpServer.SetAuthenticator(NewAuthenticator(credentials, ca != nil))
+	server := httptest.NewUnstartedServer(cpServer.ConnectHandler(&websocket.Upgrader{}))
+	if ca != nil {
+		server.TLS = &tls.Config{ClientCAs: ca.pool, ClientAuth: tls.VerifyClientCertIfGiven}
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	t.Cleanup(server.Close)
+	return cpServer, server
+}
+
+// dialAuthenticated connects to the test server, returning the handshake status code
+func dialAuthenticated(t *testing.T, server *httptest.Server, header http.Header, certs ...tls.Certificate) (*websocket.Conn, int) {
+	t.Helper()
+	dialer := *websocket.DefaultDialer
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	if server.TLS != nil {
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(server.Certificate())
+		dialer.TLSClientConfig = &tls.Config{RootCAs: rootCAs, Certificates: certs}
+	}
+
+	conn, resp, err := dialer.Dial(url, header)
+	if err != nil {
+		if resp == nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		return nil, resp.StatusCode
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, http.StatusSwitchingProtocols
+}
+
+// issueCredentials returns an in-memory credential store with a credential issued to each ID
+func issueCredentials(t *testing.T, ids ...string) *CredentialStore {
+	t.Helper()
+	credentials, err := NewCredentialStore("")
+	if err != nil {
+		t.Fatalf("NewCredentialStore failed: %v", err)
+	}
+	for _, id := range ids {
+		if _, err := credentials.Issue(id); err != nil {
+			t.Fatalf("Issue failed: %v", err)
+		}
+	}
+	return credentials
+}
+
+// bearer returns an Authorization header asserting id, signed with the credential issued to signer
+func bearer(t *testing.T, credentials *CredentialStore, signer, id string) http.Header {
+	t.Helper()
+	secret, ok := credentials.Secret(signer)
+	if !ok {
+		t.Fatalf("no credential issued to %s", signer)
+	}
+	return signedBearer(secret, id)
+}
+
+// revoke revokes a data plane, failing the test on error
+func revoke(t *testing.T, cpServer *Server, id string) {
+	t.Helper()
+	if err := cpServer.RevokeDataPlane(id); err != nil {
+		t.Fatalf("RevokeDataPlane failed: %v", err)
+	}
+}
+
+func signedBearer(secret []byte, id string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + protocol.SignToken(secret, id, time.Now().Add(time.Minute))}}
+}
+
+func TestServer_TokenAuthentication(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, cpServer *Server, credentials *CredentialStore) http.Header
+		wantStatus int
+	}{
+		{
+			name: "valid token",
+			setup: func(t *testing.T, cpServer *Server, credentials *CredentialStore) http.Header {
+				return bearer(t, credentials, "dp-1", "dp-1")
+			},
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "missing token",
+			setup:      func(t *testing.T, cpServer *Server, credentials *CredentialStore) http.Header { return nil },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "token signed with another secret",
+			setup: func(t *testing.T, cpServer *Server, credentials *CredentialStore) http.Header {
+				return signedBearer([]byte("other"), "dp-1")
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "token signed with another data plane's credential",
+			setup: func(t *testing.T, cpServer *Server, credentials *CredentialStore) http.Header {
+				return bearer(t, credentials, "dp-2", "dp-1")
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "revoked credential",
+			setup: func(t *testing.T, cpServer *Server, credentials *CredentialStore) http.Header {
+				header := bearer(t, credentials, "dp-1", "dp-1")
+				revoke(t, cpServer, "dp-1")
+				return header
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "revoked data plane with a new credential",
+			setup: func(t *testing.T, cpServer *Server, credentials *CredentialStore) http.Header {
+				revoke(t, cpServer, "dp-1")
+				if _, err := cpServer.IssueCredential("dp-1"); err != nil {
+					t.Fatalf("IssueCredential failed: %v", err)
+				}
+				return bearer(t, credentials, "dp-1", "dp-1")
+			},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credentials := issueCredentials(t, "dp-1", "dp-2")
+			cpServer, server := newAuthTestServer(t, credentials, nil)
+			header := tt.setup(t, cpServer, credentials)
+
+			conn, status := dialAuthenticated(t, server, header)
+			if status != tt.wantStatus {
+				t.Fatalf("handshake status = %d, want %d", status, tt.wantStatus)
+			}
+			if conn == nil {
+				return
+			}
+
+			startFakeDataPlane(t, conn, "dp-1")
+			waitForRollout(t, "dp-1 to apply v1", func() bool { return cpServer.FleetStatus().Applied == 1 })
+			dp := cpServer.FleetStatus().DataPlanes[0]
+			if dp.ID != "dp-1" || dp.Auth != AuthToken {
+				t.Errorf("data plane = %s (auth %q), want dp-1 (auth token)", dp.ID, dp.Auth)
+			}
+		})
+	}
+}
+
+func TestServer_MTLSAuthentication(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	tests := []struct {
+		name          string
+		certs         []tls.Certificate
+		helloID       string
+		wantStatus    int
+		wantConnected bool
+	}{
+		{name: "client certificate", certs: []tls.Certificate{ca.clientCert(t, "dp-cert")}, helloID: "dp-cert", wantStatus: http.StatusSwitchingProtocols, wantConnected: true},
+		{name: "no client certificate", wantStatus: http.StatusUnauthorized},
+		{name: "hello claims another identity", certs: []tls.Certificate{ca.clientCert(t, "dp-cert")}, helloID: "dp-other", wantStatus: http.StatusSwitchingProtocols},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpServer, server := newAuthTestServer(t, nil, ca)
+
+			conn, status := dialAuthenticated(t, server, nil, tt.certs...)
+			if status != tt.wantStatus {
+				t.Fatalf("handshake status = %d, want %d", status, tt.wantStatus)
+			}
+			if conn == nil {
+				return
+			}
+
+			dp := startFakeDataPlane(t, conn, tt.helloID)
+			if tt.wantConnected {
+				waitForRollout(t, "dp-cert to apply v1", func() bool { return cpServer.FleetStatus().Applied == 1 })
+				if dp := cpServer.FleetStatus().DataPlanes[0]; dp.ID != "dp-cert" || dp.Auth != AuthMTLS {
+					t.Errorf("data plane = %s (auth %q), want dp-cert (auth mtls)", dp.ID, dp.Auth)
+				}
+			} else {
+				select {
+				case <-dp.done:
+				case <-time.After(2 * time.Second):
+					t.Fatal("data plane claiming another identity was not disconnected")
+				}
+			}
+		})
+	}
+
+	t.Run("certificate from an untrusted CA", func(t *testing.T) {
+		_, server := newAuthTestServer(t, nil, ca)
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(server.Certificate())
+		dialer := websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: []tls.Certificate{otherCA.clientCert(t, "dp-cert")}}}
+		if conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil); err == nil {
+			conn.Close()
+			t.Fatal("Dial succeeded with a certificate from an untrusted CA")
+		}
+	})
+}
+
+func TestServer_RevokeDisconnectsDataPlane(t *testing.T) {
+	credentials := issueCredentials(t, "dp-1")
+	cpServer, server := newAuthTestServer(t, credentials, nil)
+	oldToken := bearer(t, credentials, "dp-1", "dp-1")
+
+	conn, _ := dialAuthenticated(t, server, oldToken)
+	dp := startFakeDataPlane(t, conn, "dp-1")
+	waitForRollout(t, "dp-1 to apply v1", func() bool { return cpServer.FleetStatus().Applied == 1 })
+
+	revoke(t, cpServer, "dp-1")
+	select {
+	case <-dp.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("revoked data plane was not disconnected")
+	}
+	if _, status := dialAuthenticated(t, server, oldToken); status != http.StatusUnauthorized {
+		t.Errorf("reconnect status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// Reinstating the ID does not bring back the revoked credential
+	if reinstated, err := cpServer.ReinstateDataPlane("dp-1"); err != nil || !reinstated {
+		t.Fatalf("ReinstateDataPlane = %v, %v; want true", reinstated, err)
+	}
+	if _, status := dialAuthenticated(t, server, oldToken); status != http.StatusUnauthorized {
+		t.Errorf("reconnect with the revoked credential status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if _, err := cpServer.IssueCredential("dp-1"); err != nil {
+		t.Fatalf("IssueCredential failed: %v", err)
+	}
+	if _, status := dialAuthenticated(t, server, bearer(t, credentials, "dp-1", "dp-1")); status != http.StatusSwitchingProtocols {
+		t.Errorf("reconnect with a new credential status = %d, want %d", status, http.StatusSwitchingProtocols)
+	}
+}
+
+func TestServer_RevocationSharedAcrossReplicas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revoked.json")
+	newReplica := func(credentials *CredentialStore) (*Server, *httptest.Server) {
+		cpServer, server := newAuthTestServer(t, credentials, nil)
+		store, err := NewRevocationStore(path)
+		if err != nil {
+			t.Fatalf("NewRevocationStore failed: %v", err)
+		}
+		cpServer.SetRevocationStore(store)
+		return cpServer, server
+	}
+	credentials := issueCredentials(t, "dp-1")
+	replicaA, _ := newReplica(issueCredentials(t))
+	replicaB, serverB := newReplica(credentials)
+
+	conn, _ := dialAuthenticated(t, serverB, bearer(t, credentials, "dp-1", "dp-1"))
+	dp := startFakeDataPlane(t, conn, "dp-1")
+	waitForRollout(t, "dp-1 to apply v1", func() bool { return replicaB.FleetStatus().Applied == 1 })
+
+	// Revoked on one replica, refused and disconnected by the other
+	revoke(t, replicaA, "dp-1")
+	if _, status := dialAuthenticated(t, serverB, bearer(t, credentials, "dp-1", "dp-1")); status != http.StatusForbidden {
+		t.Errorf("connect to the other replica status = %d, want %d", status, http.StatusForbidden)
+	}
+	replicaB.disconnectRevoked()
+	select {
+	case <-dp.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("data plane revoked on another replica was not disconnected")
+	}
+
+	// Survives a restart
+	restarted, _ := newReplica(credentials)
+	if got := restarted.RevokedDataPlanes(); len(got) != 1 || got[0] != "dp-1" {
+		t.Errorf("RevokedDataPlanes after restart = %v, want [dp-1]", got)
+	}
+
+	if reinstated, err := restarted.ReinstateDataPlane("dp-1"); err != nil || !reinstated {
+		t.Fatalf("ReinstateDataPlane = %v, %v; want true", reinstated, err)
+	}
+	if replicaB.IsRevoked("dp-1") {
+		t.Error("dp-1 still revoked on another replica after it was reinstated")
+	}
+}
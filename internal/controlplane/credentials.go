@@ -0,0 +1,129 @@
+package controlplane
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// CredentialStore holds the token secret issued to each data plane, keyed by data plane ID
+// A secret only signs tokens for its own ID, so a data plane cannot impersonate another
+// With a path the secrets are kept in a JSON file ({"<id>": "<secret>"}) that replicas may
+// share; changes made by another replica are picked up on the next lookup
+type CredentialStore struct {
+	file    *sharedFile // Nil keeps secrets in memory only
+	secrets map[string]string
+	mu      sync.Mutex
+}
+
+// NewCredentialStore creates a credential store backed by the file at path, loading it if it exists
+func NewCredentialStore(path string) (*CredentialStore, error) {
+	c := &CredentialStore{
+		secrets: make(map[string]string),
+	}
+	if path != "" {
+		c.file = &sharedFile{path: path}
+		if err := c.reload(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Secret returns the secret issued to a data plane
+func (c *CredentialStore) Secret(id string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.reload(); err != nil {
+		// Keep authenticating with the secrets last read
+		log.Printf("Failed to reload data plane credentials: %v", err)
+	}
+	secret, exists := c.secrets[id]
+	if !exists {
+		return nil, false
+	}
+	return []byte(secret), true
+}
+
+// Issue generates a new secret for a data plane, replacing any secret issued to it before
+func (c *CredentialStore) Issue(id string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate credential: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+
+	err := c.update(func(secrets map[string]string) {
+		secrets[id] = secret
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Revoke deletes a data plane's secret, so tokens it signs are no longer accepted
+func (c *CredentialStore) Revoke(id string) error {
+	return c.update(func(secrets map[string]string) {
+		delete(secrets, id)
+	})
+}
+
+// update applies a change to the secrets, persisting them under the file lock if the store has a file
+func (c *CredentialStore) update(change func(secrets map[string]string)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		change(c.secrets)
+		return nil
+	}
+
+	unlock, err := c.file.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Start from the file so changes made by other replicas are kept
+	if err := c.reload(); err != nil {
+		return err
+	}
+	secrets := make(map[string]string, len(c.secrets))
+	for id, secret := range c.secrets {
+		secrets[id] = secret
+	}
+	change(secrets)
+
+	// Only the control plane may read the file
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := c.file.write(data, 0600); err != nil {
+		return err
+	}
+	c.secrets = secrets
+	return nil
+}
+
+// reload rereads the file if it changed since it was last read (must be called with mu held)
+func (c *CredentialStore) reload() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.reload(func(data []byte) error {
+		secrets := make(map[string]string)
+		if data != nil {
+			if err := json.Unmarshal(data, &secrets); err != nil {
+				return err
+			}
+		}
+		c.secrets = secrets
+		return nil
+	})
+}
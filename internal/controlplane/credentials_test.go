@@ -0,0 +1,41 @@
+package controlplane
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialStore_SharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	replicaA, err := NewCredentialStore(path)
+	if err != nil {
+		t.Fatalf("NewCredentialStore failed: %v", err)
+	}
+	replicaB, err := NewCredentialStore(path)
+	if err != nil {
+		t.Fatalf("NewCredentialStore failed: %v", err)
+	}
+
+	secret, err := replicaA.Issue("dp-1")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if got, ok := replicaB.Secret("dp-1"); !ok || string(got) != secret {
+		t.Errorf("Secret(dp-1) on another replica = %q, %v; want the issued credential", got, ok)
+	}
+
+	if err := replicaB.Revoke("dp-1"); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, ok := replicaA.Secret("dp-1"); ok {
+		t.Error("Secret(dp-1) found after another replica revoked it")
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0600); err != nil {
+		t.Fatalf("Failed to write credentials: %v", err)
+	}
+	if _, err := NewCredentialStore(path); err == nil {
+		t.Error("NewCredentialStore(corrupt file) succeeded, want error")
+	}
+}
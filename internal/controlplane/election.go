@@ -0,0 +1,298 @@
+package controlplane
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultLeaseTTL is how long a leader's lease lasts without renewal
+const DefaultLeaseTTL = 15 * time.Second
+
+// Lease records which control plane replica leads and until when
+type Lease struct {
+	Holder    string    `json:"holder"`
+	URL       string    `json:"url,omitempty"` // Where the holder serves the API
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LeaseBackend stores the lease shared by control plane replicas
+type LeaseBackend interface {
+	// TryAcquire takes or renews lease unless another holder's lease is unexpired at now
+	// Returns the lease in effect afterwards
+	TryAcquire(lease Lease, now time.Time) (Lease, error)
+	// Release expires the lease if holder owns it
+	Release(holder string) error
+}
+
+// FileLease is a lease backend over a file on storage shared by the replicas
+// Reads and writes of the lease happen under an exclusive lock on a sibling lock file
+// (see lockFile for how it is taken on each platform)
+type FileLease struct {
+	path string
+}
+
+// NewFileLease creates a file lease backend at path
+func NewFileLease(path string) *FileLease {
+	return &FileLease{path: path}
+}
+
+// TryAcquire takes or renews the lease unless another holder's lease is unexpired
+func (f *FileLease) TryAcquire(lease Lease, now time.Time) (Lease, error) {
+	var result Lease
+	err := f.locked(func() error {
+		current, err := f.read()
+		if err != nil {
+			return err
+		}
+		if current.Holder != "" && current.Holder != lease.Holder && now.Before(current.ExpiresAt) {
+			result = current
+			return nil
+		}
+		result = lease
+		return f.write(lease)
+	})
+	return result, err
+}
+
+// Release expires the lease if holder owns it
+func (f *FileLease) Release(holder string) error {
+	return f.locked(func() error {
+		current, err := f.read()
+		if err != nil || current.Holder != holder {
+			return err
+		}
+		current.ExpiresAt = time.Time{}
+		return f.write(current)
+	})
+}
+
+// locked runs fn holding the exclusive lock
+func (f *FileLease) locked(fn func() error) error {
+	unlock, err := lockFile(f.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return fn()
+}
+
+// read returns the stored lease, or an empty one if none is stored
+func (f *FileLease) read() (Lease, error) {
+	var lease Lease
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lease, nil
+	}
+	if err != nil {
+		return lease, fmt.Errorf("failed to read lease: %w", err)
+	}
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return Lease{}, nil // A corrupt lease is treated as free
+	}
+	return lease, nil
+}
+
+// write stores the lease, replacing the file atomically
+func (f *FileLease) write(lease Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.tmp-%d", f.path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lease: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write lease: %w", err)
+	}
+	return nil
+}
+
+// Elector campaigns for leadership among control plane replicas sharing a lease backend
+type Elector struct {
+	backend LeaseBackend
+	id      string
+	url     string
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	lease    Lease // Last lease observed
+	leader   bool
+	onChange func(leader bool)
+
+	stopCh   chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewElector creates an elector campaigning as id, advertising url to followers
+func NewElector(backend LeaseBackend, id, url string, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	return &Elector{
+		backend: backend,
+		id:      id,
+		url:     url,
+		ttl:     ttl,
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// OnChange sets a function called (from the election loop) when this replica gains or loses leadership
+// Must be called before Start
+func (e *Elector) OnChange(fn func(leader bool)) {
+	e.onChange = fn
+}
+
+// Start campaigns once synchronously, then renews or retries every third of the lease TTL
+func (e *Elector) Start() {
+	e.campaign()
+	go e.loop()
+}
+
+// Stop stops campaigning and releases the lease if held, so another replica takes over quickly
+func (e *Elector) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+		<-e.done
+
+		if e.IsLeader() {
+			if err := e.backend.Release(e.id); err != nil {
+				log.Printf("Failed to release leader lease: %v", err)
+			}
+			e.setLeader(false)
+		}
+	})
+}
+
+// IsLeader reports whether this replica holds an unexpired lease
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader && time.Now().Before(e.lease.ExpiresAt)
+}
+
+// Leader returns the last lease observed
+func (e *Elector) Leader() Lease {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lease
+}
+
+// loop renews or retries the lease until stopped
+func (e *Elector) loop() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.campaign()
+		case <-e.stopCh:
+			return
+		}
+	}
+}
+
+// campaign tries to take or renew the lease
+func (e *Elector) campaign() {
+	now := time.Now()
+	lease, err := e.backend.TryAcquire(Lease{Holder: e.id, URL: e.url, ExpiresAt: now.Add(e.ttl)}, now)
+	if err != nil {
+		// Keep leading until the held lease runs out; another replica cannot take it before then
+		log.Printf("Leader election failed: %v", err)
+		if !e.IsLeader() {
+			e.setLeader(false)
+		}
+		return
+	}
+
+	e.mu.Lock()
+	e.lease = lease
+	e.mu.Unlock()
+	e.setLeader(lease.Holder == e.id)
+}
+
+// setLeader records leadership and reports changes
+func (e *Elector) setLeader(leader bool) {
+	e.mu.Lock()
+	changed := e.leader != leader
+	e.leader = leader
+	holder := e.lease.Holder
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if leader {
+		log.Printf("Control plane %s elected leader", e.id)
+	} else {
+		log.Printf("Control plane %s is a follower (leader: %s)", e.id, holder)
+	}
+	if e.onChange != nil {
+		e.onChange(leader)
+	}
+}
+
+// ServeHTTP handles /debug/leader requests
+func (e *Elector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	lease := e.Leader()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":               e.id,
+		"is_leader":        e.IsLeader(),
+		"leader":           lease.Holder,
+		"leader_url":       lease.URL,
+		"lease_expires_at": lease.ExpiresAt,
+	})
+}
+
+// SetElector makes the server lead (config writes, staged rollouts) only while the elector holds the lease
+// The leader saves its release to releases; followers serve that release to their data planes
+// instead of the config they load. Must be called before the elector starts
+func (s *Server) SetElector(elector *Elector, releases ReleaseStore) {
+	s.elector = elector
+	s.releases = releases
+	elector.OnChange(s.leadershipChanged)
+}
+
+// IsLeader reports whether this replica owns writes and rollouts; always true without an elector
+func (s *Server) IsLeader() bool {
+	return s.elector == nil || s.elector.IsLeader()
+}
+
+// leadershipChanged resumes the saved release on gaining leadership and hands the rollout over on losing it
+func (s *Server) leadershipChanged(leader bool) {
+	if leader {
+		s.resumeRelease()
+		return
+	}
+	s.handOverRollout()
+}
+
+// requireLeader writes 503 naming the leader and returns false if this replica is a follower
+func (s *Server) requireLeader(w http.ResponseWriter) bool {
+	if s.IsLeader() {
+		return true
+	}
+	lease := s.elector.Leader()
+	leader := lease.URL
+	if leader == "" {
+		leader = lease.Holder
+	}
+	w.Header().Set("X-Control-Plane-Leader", leader)
+	http.Error(w, fmt.Sprintf("not the leader; send writes to %s", leader), http.StatusServiceUnavailable)
+	return false
+}
@@ -0,0 +1,331 @@
+package controlplane
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+func TestFileLease_TryAcquire(t *testing.T) {
+	lease := NewFileLease(t.TempDir() + "/leader.lease")
+	t0 := time.Now()
+
+	tests := []struct {
+		name       string
+		holder     string
+		now        time.Time
+		wantHolder string
+	}{
+		{name: "free lease", holder: "cp-1", now: t0, wantHolder: "cp-1"},
+		{name: "held by another", holder: "cp-2", now: t0.Add(500 * time.Millisecond), wantHolder: "cp-1"},
+		{name: "holder renews", holder: "cp-1", now: t0.Add(900 * time.Millisecond), wantHolder: "cp-1"},
+		{name: "renewed lease still held", holder: "cp-2", now: t0.Add(1500 * time.Millisecond), wantHolder: "cp-1"},
+		{name: "expired lease taken over", holder: "cp-2", now: t0.Add(2 * time.Second), wantHolder: "cp-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lease.TryAcquire(Lease{Holder: tt.holder, ExpiresAt: tt.now.Add(time.Second)}, tt.now)
+			if err != nil {
+				t.Fatalf("TryAcquire error = %v", err)
+			}
+			if got.Holder != tt.wantHolder {
+				t.Errorf("lease holder = %s, want %s", got.Holder, tt.wantHolder)
+			}
+		})
+	}
+}
+
+func TestFileRelease_SaveRequiresLease(t *testing.T) {
+	lease := NewFileLease(t.TempDir() + "/leader.lease")
+	releases := NewFileRelease(lease)
+	now := time.Now()
+	if _, err := lease.TryAcquire(Lease{Holder: "cp-1", ExpiresAt: now.Add(time.Minute)}, now); err != nil {
+		t.Fatalf("TryAcquire error = %v", err)
+	}
+
+	if err := releases.Save(&Release{Stable: &config.Config{Version: "v1"}}, "cp-1"); err != nil {
+		t.Fatalf("Save by the holder error = %v", err)
+	}
+	if err := releases.Save(&Release{Stable: &config.Config{Version: "v2"}}, "cp-2"); !errors.Is(err, ErrNotLeaseHolder) {
+		t.Errorf("Save by another replica error = %v, want ErrNotLeaseHolder", err)
+	}
+
+	// A stale leader whose lease ran out cannot overwrite the release
+	if err := lease.Release("cp-1"); err != nil {
+		t.Fatalf("Release error = %v", err)
+	}
+	if err := releases.Save(&Release{Stable: &config.Config{Version: "v3"}}, "cp-1"); !errors.Is(err, ErrNotLeaseHolder) {
+		t.Errorf("Save with an expired lease error = %v, want ErrNotLeaseHolder", err)
+	}
+
+	release, err := releases.Load()
+	if err != nil {
+		t.Fatalf("Load error = %v", err)
+	}
+	if release.Stable.Version != "v1" {
+		t.Errorf("saved release version = %s, want v1", release.Stable.Version)
+	}
+}
+
+func TestElector_Handover(t *testing.T) {
+	backend := NewFileLease(t.TempDir() + "/leader.lease")
+	first := NewElector(backend, "cp-1", "http://cp-1:8081", 300*time.Millisecond)
+	second := NewElector(backend, "cp-2", "http://cp-2:8081", 300*time.Millisecond)
+
+	changes := make(chan bool, 1)
+	second.OnChange(func(leader bool) { changes <- leader })
+
+	first.Start()
+	second.Start()
+	defer second.Stop()
+
+	if !first.IsLeader() || second.IsLeader() {
+		t.Fatalf("leaders = cp-1: %v, cp-2: %v, want cp-1 only", first.IsLeader(), second.IsLeader())
+	}
+	if lease := second.Leader(); lease.Holder != "cp-1" || lease.URL != "http://cp-1:8081" {
+		t.Errorf("follower sees leader %+v, want cp-1", lease)
+	}
+
+	// Stopping releases the lease, so the follower takes over on its next renewal
+	first.Stop()
+	select {
+	case leader := <-changes:
+		if !leader {
+			t.Fatal("cp-2 reported losing leadership, want gaining it")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cp-2 did not take over leadership")
+	}
+	if first.IsLeader() {
+		t.Error("stopped elector still reports leadership")
+	}
+}
+
+func TestAPIHandler_FollowerRejectsWrites(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	initialConfig := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(initialConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+
+	backend := NewFileLease(t.TempDir() + "/leader.lease")
+	leader := NewElector(backend, "cp-1", "http://cp-1:8081", time.Minute)
+	leader.Start()
+	defer leader.Stop()
+
+	cpServer := NewServer(loader)
+	follower := NewElector(backend, "cp-2", "http://cp-2:8081", time.Minute)
+	cpServer.SetElector(follower, NewFileRelease(backend))
+	follower.Start()
+	defer follower.Stop()
+	handler := NewAPIHandler(cpServer)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "read", method: http.MethodGet, path: "/api/v1/routing-keys/acme", wantStatus: http.StatusOK},
+		{name: "write", method: http.MethodPut, path: "/api/v1/routing-keys/acme", wantStatus: http.StatusServiceUnavailable},
+		{name: "rollout control", method: http.MethodPost, path: "/api/v1/rollout/pause", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"placement":"tier2"}`))
+			req.Header.Set("If-Match", `"v1"`)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && rec.Header().Get("X-Control-Plane-Leader") != "http://cp-1:8081" {
+				t.Errorf("X-Control-Plane-Leader = %q, want http://cp-1:8081", rec.Header().Get("X-Control-Plane-Leader"))
+			}
+		})
+	}
+
+	if version := loader.GetConfigVersion(); version != "v1" {
+		t.Errorf("follower changed config to %s", version)
+	}
+}
+
+// startReplica runs a control plane replica over the shared config file, lease and release
+func startReplica(t *testing.T, cfgFile string, lease LeaseBackend, releases ReleaseStore, id string, strategy *RolloutStrategy) (*Server, *config.Loader, *Elector, string) {
+	t.Helper()
+	loader := config.NewLoader(cfgFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	cpServer := NewServer(loader)
+	cpServer.SetRolloutStrategy(strategy)
+	elector := NewElector(lease, id, "http://"+id+":8081", 300*time.Millisecond)
+	cpServer.SetElector(elector, releases)
+	elector.Start()
+	t.Cleanup(elector.Stop)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		cpServer.HandleConnection(conn)
+	}))
+	t.Cleanup(server.Close)
+	return cpServer, loader, elector, "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// writeReplicaConfig writes the config the replicas in a test share
+func writeReplicaConfig(t *testing.T) string {
+	t.Helper()
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	return tmpFile
+}
+
+func TestServer_FollowerServesLeaderRelease(t *testing.T) {
+	cfgFile := writeReplicaConfig(t)
+	dir := t.TempDir()
+	lease := NewFileLease(dir + "/leader.lease")
+	releases := NewFileRelease(lease)
+	strategy := &RolloutStrategy{Waves: []float64{0.5, 1}, BakeTime: time.Minute, AckTimeout: time.Second}
+	leader, leaderLoader, _, leaderURL := startReplica(t, cfgFile, lease, releases, "cp-1", strategy)
+	follower, followerLoader, _, followerURL := startReplica(t, cfgFile, lease, releases, "cp-2", strategy)
+	if !leader.IsLeader() || follower.IsLeader() {
+		t.Fatalf("leaders = cp-1: %v, cp-2: %v, want cp-1 only", leader.IsLeader(), follower.IsLeader())
+	}
+
+	dps := make(map[string]*fakeDataPlane)
+	for _, id := range []string{"dp-1", "dp-2"} {
+		dps[id] = dialFakeDataPlane(t, leaderURL, id)
+		defer dps[id].conn.Close()
+	}
+	dps["dp-3"] = dialFakeDataPlane(t, followerURL, "dp-3")
+	defer dps["dp-3"].conn.Close()
+	waitForRollout(t, "fleet converged on v1", func() bool {
+		return leader.FleetStatus().Applied == 2 && follower.FleetStatus().Applied == 1
+	})
+
+	if _, err := leaderLoader.Update("v1", func(cfg *config.Config) error {
+		cfg.RoutingTable["acme"] = "tier2"
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	leader.PublishConfig()
+	waitForRollout(t, "canary to receive v2", func() bool { return dps["dp-1"].receivedVersions() == "v1,v2" })
+
+	// The follower reloads v2 but only serves it to the data planes the leader's canary reached
+	if err := followerLoader.ApplyConfig(leaderLoader.GetConfig()); err != nil {
+		t.Fatalf("ApplyConfig failed: %v", err)
+	}
+	if version := followerLoader.GetConfigVersion(); version != "v2" {
+		t.Fatalf("follower loaded %s, want v2", version)
+	}
+	dps["dp-1"].conn.Close()
+	dps["dp-1"] = dialFakeDataPlane(t, followerURL, "dp-1")
+	defer dps["dp-1"].conn.Close()
+	waitForRollout(t, "dp-1 to receive v1 from the follower", func() bool { return dps["dp-1"].receivedVersions() == "v1" })
+	follower.PublishConfig()
+	waitForRollout(t, "dp-1 to receive v2 from the follower", func() bool { return dps["dp-1"].receivedVersions() == "v1,v2" })
+	if seen := dps["dp-3"].receivedVersions(); seen != "v1" {
+		t.Errorf("dp-3 received %s on the follower, want v1", seen)
+	}
+	if version := follower.StableConfig().Version; version != "v1" {
+		t.Errorf("follower stable version = %s, want v1", version)
+	}
+
+	// A rollback by the leader reaches data planes connected to the follower
+	if err := leader.AbortRollout(); err != nil {
+		t.Fatalf("AbortRollout failed: %v", err)
+	}
+	waitForRollout(t, "dp-1 to return to v1", func() bool {
+		follower.PublishConfig() // As the follower's watch loop does, until the rollback is saved
+		return dps["dp-1"].receivedVersions() == "v1,v2,v1"
+	})
+	if seen := dps["dp-3"].receivedVersions(); seen != "v1" {
+		t.Errorf("dp-3 received %s on the follower, want v1", seen)
+	}
+}
+
+func TestServer_NewLeaderResumesRollout(t *testing.T) {
+	cfgFile := writeReplicaConfig(t)
+	dir := t.TempDir()
+	lease := NewFileLease(dir + "/leader.lease")
+	releases := NewFileRelease(lease)
+	first, loader, firstElector, firstURL := startReplica(t, cfgFile, lease, releases, "cp-1",
+		&RolloutStrategy{Waves: []float64{0.5, 1}, BakeTime: time.Minute, AckTimeout: time.Second})
+	second, _, _, secondURL := startReplica(t, cfgFile, lease, releases, "cp-2",
+		&RolloutStrategy{Waves: []float64{0.5, 1}, BakeTime: 10 * time.Millisecond, AckTimeout: time.Second})
+
+	dps := make(map[string]*fakeDataPlane)
+	for id, url := range map[string]string{"dp-1": firstURL, "dp-2": firstURL, "dp-3": secondURL, "dp-4": secondURL} {
+		dps[id] = dialFakeDataPlane(t, url, id)
+		defer dps[id].conn.Close()
+	}
+	waitForRollout(t, "fleet converged on v1", func() bool {
+		return first.FleetStatus().Applied == 2 && second.FleetStatus().Applied == 2
+	})
+
+	if _, err := loader.Update("v1", func(cfg *config.Config) error {
+		cfg.RoutingTable["acme"] = "tier2"
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	first.PublishConfig()
+	waitForRollout(t, "canary to receive v2", func() bool { return dps["dp-1"].receivedVersions() == "v1,v2" })
+
+	// The first leader hands its rollout over without rolling back; the second resumes and completes it
+	firstElector.Stop()
+	if status, _ := first.RolloutStatus(); status.State != RolloutHandedOver {
+		t.Errorf("first leader's rollout state = %s, want %s", status.State, RolloutHandedOver)
+	}
+	waitForRollout(t, "second leader to complete the rollout", func() bool {
+		status, _ := second.RolloutStatus()
+		return status.Version == "v2" && status.State == RolloutCompleted
+	})
+	for _, id := range []string{"dp-3", "dp-4"} {
+		if seen := dps[id].receivedVersions(); seen != "v1,v2" {
+			t.Errorf("%s received %s, want v1,v2", id, seen)
+		}
+	}
+
+	// The first replica now follows the completed release
+	first.PublishConfig()
+	waitForRollout(t, "dp-2 to receive v2", func() bool { return dps["dp-2"].receivedVersions() == "v1,v2" })
+	if seen := dps["dp-1"].receivedVersions(); seen != "v1,v2" {
+		t.Errorf("dp-1 received %s, want v1,v2", seen)
+	}
+	if version := first.StableConfig().Version; version != "v2" {
+		t.Errorf("first replica stable version = %s, want v2", version)
+	}
+}
@@ -0,0 +1,200 @@
+package controlplane
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// DataPlaneStatus describes how far a data plane has converged on the current config
+type DataPlaneStatus string
+
+const (
+	// DataPlaneConverged means the data plane acked the current version
+	DataPlaneConverged DataPlaneStatus = "converged"
+	// DataPlanePending means the data plane has not yet acked or nacked the current version
+	DataPlanePending DataPlaneStatus = "pending"
+	// DataPlaneRejected means the data plane nacked the current version
+	DataPlaneRejected DataPlaneStatus = "rejected"
+	// DataPlaneDisconnected means the data plane is not connected
+	DataPlaneDisconnected DataPlaneStatus = "disconnected"
+)
+
+// dataPlane tracks a data plane's identity and config rollout state (guarded by Server.fleetMutex)
+type dataPlane struct {
+	id          string // From hello; the remote address until then
+	identified  bool   // Whether the data plane sent hello
+	hostname    string
+	build       string
+	remoteAddr  string
+	authMethod  AuthMethod        // Empty for unauthenticated data planes
+	labels      map[string]string // Declared on connect; select the config view it receives
+	view        string            // View of the last config sent; empty for the whole config
+	connected   bool
+	connectedAt time.Time
+	lastSeen    time.Time
+	writeMutex  sync.Mutex // Serializes writes to the WebSocket; a connection supports one concurrent writer
+
+	polling     bool          // Pulls configs from GET /config instead of holding a WebSocket
+	openPolls   int           // Polls waiting for a new config
+	pollTimeout time.Duration // A polling data plane is disconnected after not polling for this long
+	notify      chan struct{} // Wakes open polls when sentConfig changes
+
+	appliedVersion  string
+	sentConfig      *config.Config // Last config sent; deltas are computed from it once acked
+	lastAckAt       time.Time
+	lastNackVersion string
+	lastNackReason  protocol.NackReason
+	lastError       string
+}
+
+// DataPlaneInfo is a point-in-time view of a data plane
+type DataPlaneInfo struct {
+	ID              string              `json:"id"`
+	Hostname        string              `json:"hostname,omitempty"`
+	Build           string              `json:"build,omitempty"`
+	RemoteAddr      string              `json:"remote_addr"`
+	Auth            AuthMethod          `json:"auth,omitempty"`
+	Transport       string              `json:"transport"`
+	Labels          map[string]string   `json:"labels,omitempty"`
+	View            string              `json:"view,omitempty"`
+	Status          DataPlaneStatus     `json:"status"`
+	ConnectedAt     time.Time           `json:"connected_at"`
+	LastSeen        time.Time           `json:"last_seen"`
+	AppliedVersion  string              `json:"applied_version,omitempty"`
+	LastAckAt       *time.Time          `json:"last_ack_at,omitempty"`
+	LastNackVersion string              `json:"last_nack_version,omitempty"`
+	LastNackReason  protocol.NackReason `json:"last_nack_reason,omitempty"`
+	LastError       string              `json:"last_error,omitempty"`
+}
+
+// FleetStatus summarizes convergence of connected data planes on the stable config version
+// During a rollout, data planes it has sent the new version are measured against that version instead
+type FleetStatus struct {
+	Version    string          `json:"version"`                   // Stable version
+	Rollout    string          `json:"rollout_version,omitempty"` // Version of the rollout in progress
+	Converged  bool            `json:"converged"`                 // Every connected data plane applied Version
+	Connected  int             `json:"connected"`
+	Applied    int             `json:"applied"`
+	Pending    int             `json:"pending"`
+	Rejected   int             `json:"rejected"`
+	DataPlanes []DataPlaneInfo `json:"data_planes"`
+}
+
+// isConnected reports whether the data plane is connected (must be called with fleet lock held)
+// Polling data planes count as connected while a poll is open and for pollTimeout after the last one
+func (dp *dataPlane) isConnected(now time.Time) bool {
+	if dp.polling {
+		return dp.openPolls > 0 || now.Sub(dp.lastSeen) < dp.pollTimeout
+	}
+	return dp.connected
+}
+
+// info returns the data plane's view relative to its target version (must be called with fleet lock held)
+func (dp *dataPlane) info(targetVersion string) DataPlaneInfo {
+	info := DataPlaneInfo{
+		ID:              dp.id,
+		Hostname:        dp.hostname,
+		Build:           dp.build,
+		RemoteAddr:      dp.remoteAddr,
+		Auth:            dp.authMethod,
+		Transport:       "websocket",
+		Labels:          dp.labels,
+		View:            dp.view,
+		ConnectedAt:     dp.connectedAt,
+		LastSeen:        dp.lastSeen,
+		AppliedVersion:  dp.appliedVersion,
+		LastNackVersion: dp.lastNackVersion,
+		LastNackReason:  dp.lastNackReason,
+		LastError:       dp.lastError,
+	}
+	if dp.polling {
+		info.Transport = "polling"
+	}
+	if !dp.lastAckAt.IsZero() {
+		lastAckAt := dp.lastAckAt
+		info.LastAckAt = &lastAckAt
+	}
+
+	switch {
+	case !dp.isConnected(time.Now()):
+		info.Status = DataPlaneDisconnected
+	case dp.appliedVersion == targetVersion:
+		info.Status = DataPlaneConverged
+	case dp.lastNackVersion == targetVersion:
+		info.Status = DataPlaneRejected
+	default:
+		info.Status = DataPlanePending
+	}
+	return info
+}
+
+// FleetStatus returns rollout state for every known data plane, sorted by ID
+// Data planes are measured against the stable config, not the loader's, so a rolled back version is not waited for
+func (s *Server) FleetStatus() FleetStatus {
+	status := FleetStatus{
+		DataPlanes: []DataPlaneInfo{},
+	}
+
+	s.rolloutMutex.Lock()
+	status.Version = s.stableConfig.Version
+	rolling := make(map[string]bool)
+	if r := s.rollout; r != nil && !r.done() {
+		status.Rollout = r.cfg.Version
+		for id := range r.updated {
+			rolling[id] = true
+		}
+	}
+	s.rolloutMutex.Unlock()
+
+	s.fleetMutex.Lock()
+	for _, dp := range s.dataPlanes {
+		target := status.Version
+		if rolling[dp.id] {
+			target = status.Rollout
+		}
+		info := dp.info(target)
+		switch info.Status {
+		case DataPlaneConverged:
+			status.Applied++
+		case DataPlanePending:
+			status.Pending++
+		case DataPlaneRejected:
+			status.Rejected++
+		}
+		if info.Status != DataPlaneDisconnected {
+			status.Connected++
+		}
+		status.DataPlanes = append(status.DataPlanes, info)
+	}
+	s.fleetMutex.Unlock()
+
+	sort.Slice(status.DataPlanes, func(i, j int) bool {
+		return status.DataPlanes[i].ID < status.DataPlanes[j].ID
+	})
+	status.Converged = status.Applied == status.Connected
+	return status
+}
+
+// FleetHandler serves fleet convergence on the stable config version
+type FleetHandler struct {
+	server *Server
+}
+
+// NewFleetHandler creates a new fleet status handler
+func NewFleetHandler(server *Server) *FleetHandler {
+	return &FleetHandler{
+		server: server,
+	}
+}
+
+// ServeHTTP handles /debug/fleet requests
+func (h *FleetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.server.FleetStatus())
+}
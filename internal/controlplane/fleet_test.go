@@ -0,0 +1,81 @@
+package controlplane
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/dataplane"
+)
+
+func TestServer_FleetStatus(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	cpLoader := config.NewLoader(tmpFile, time.Second)
+	if err := cpLoader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	cpServer := NewServer(cpLoader)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		cpServer.HandleConnection(conn)
+	}))
+	defer server.Close()
+
+	dpLoader := config.NewLoader(tmpFile, time.Second)
+	if err := dpLoader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	client, err := dataplane.NewClient("ws"+strings.TrimPrefix(server.URL, "http"), dpLoader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.SetIdentity(dataplane.Identity{ID: "router-1", Hostname: "host-1", Build: "abc123"})
+	client.Start()
+
+	waitFor := func(desc string, cond func(FleetStatus) bool) FleetStatus {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			status := cpServer.FleetStatus()
+			if cond(status) {
+				return status
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s: %+v", desc, status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	status := waitFor("convergence", func(s FleetStatus) bool { return s.Converged && s.Applied == 1 })
+	dp := status.DataPlanes[0]
+	if dp.ID != "router-1" || dp.Hostname != "host-1" || dp.Build != "abc123" || dp.AppliedVersion != "v1" {
+		t.Errorf("data plane = %+v, want router-1 on host-1 (abc123) with v1 applied", dp)
+	}
+
+	client.Stop()
+	status = waitFor("disconnect", func(s FleetStatus) bool { return s.Connected == 0 })
+	if len(status.DataPlanes) != 1 || status.DataPlanes[0].Status != DataPlaneDisconnected {
+		t.Errorf("data planes after disconnect = %+v, want router-1 disconnected", status.DataPlanes)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build !unix
+
+package controlplane
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// lockWait bounds how long lockFile waits for another replica to release the lock
+	lockWait = 5 * time.Second
+	// staleLockAge is how old a lock file must be to be taken over from a replica that died holding it
+	// Lease operations take milliseconds, so a lock this old is never legitimately held
+	staleLockAge = 10 * time.Second
+)
+
+// lockFile takes an exclusive lock by creating path, for platforms without flock
+// Unlike flock the lock outlives a crashed holder, so a lock file older than staleLockAge is removed
+func lockFile(path string) (unlock func(), err error) {
+	deadline := time.Now().Add(lockWait)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock lease: %w", err)
+		}
+
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock lease: %s is held by another replica", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,267 @@
+package controlplane
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// maxPollWait caps how long a config poll is held open, below typical server write timeouts
+const maxPollWait = 25 * time.Second
+
+// PollHandler serves config polling for data planes that cannot hold a WebSocket open
+//
+// GET /config returns the config assigned to the data plane as a snapshot message with its version
+// as ETag. With If-None-Match set to that version it waits up to ?wait= (e.g. 20s) for a new one,
+// answering 304 Not Modified if none arrives. POST /config/ack takes the same ack and nack messages
+// WebSocket data planes send. Data planes identify themselves with X-Data-Plane-* headers
+func (s *Server) PollHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /config", s.handlePoll)
+	mux.HandleFunc("POST /config"+protocol.PollAckPath, s.handlePollAck)
+	return mux
+}
+
+// handlePoll serves the data plane's config once its version differs from If-None-Match
+func (s *Server) handlePoll(w http.ResponseWriter, r *http.Request) {
+	wait := time.Duration(0)
+	if value := r.URL.Query().Get("wait"); value != "" {
+		var err error
+		if wait, err = time.ParseDuration(value); err != nil || wait < 0 {
+			http.Error(w, "wait must be a non-negative duration", http.StatusBadRequest)
+			return
+		}
+	}
+	if wait > maxPollWait {
+		wait = maxPollWait
+	}
+
+	identity, ok := s.pollIdentity(w, r)
+	if !ok {
+		return
+	}
+	labels, ok := dataPlaneLabels(w, r)
+	if !ok {
+		return
+	}
+	dp := s.beginPoll(identity, labels, r)
+	defer s.endPoll(dp)
+
+	held := protocol.IfNoneMatch(r)
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		cfg := s.polledConfig(dp)
+		if cfg.Version != held {
+			s.writeSnapshot(w, dp, cfg)
+			return
+		}
+
+		select {
+		case <-dp.notify:
+		case <-timeout.C:
+			w.Header().Set("ETag", protocol.ETag(held))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handlePollAck records an ack or nack posted by a polling data plane
+func (s *Server) handlePollAck(w http.ResponseWriter, r *http.Request) {
+	identity, ok := s.pollIdentity(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	var baseMsg protocol.Message
+	if err := json.Unmarshal(data, &baseMsg); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var update func(dp *dataPlane)
+	switch baseMsg.Type {
+	case protocol.MessageTypeAck:
+		var ackMsg protocol.AckMessage
+		if err := json.Unmarshal(data, &ackMsg); err != nil {
+			http.Error(w, "Invalid ack: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		update = recordAck(ackMsg)
+	case protocol.MessageTypeNack:
+		var nackMsg protocol.NackMessage
+		if err := json.Unmarshal(data, &nackMsg); err != nil {
+			http.Error(w, "Invalid nack: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		update = recordNack(nackMsg)
+	default:
+		http.Error(w, "Expected an ack or nack message, got "+string(baseMsg.Type), http.StatusBadRequest)
+		return
+	}
+
+	s.fleetMutex.Lock()
+	dp, exists := s.dataPlanes[identity.ID]
+	if exists && dp.polling {
+		dp.lastSeen = time.Now()
+		update(dp)
+	}
+	s.fleetMutex.Unlock()
+
+	if !exists || !dp.polling {
+		http.Error(w, "Unknown polling data plane "+identity.ID, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pollIdentity authenticates a polling data plane and resolves its ID from the X-Data-Plane-ID header
+// An authenticated data plane may only name itself; an unauthenticated one must send the header
+func (s *Server) pollIdentity(w http.ResponseWriter, r *http.Request) (Identity, bool) {
+	identity, ok := s.authenticate(w, r)
+	if !ok {
+		return Identity{}, false
+	}
+
+	claimed := r.Header.Get(protocol.HeaderDataPlaneID)
+	switch {
+	case identity.ID == "" && claimed == "":
+		http.Error(w, protocol.HeaderDataPlaneID+" header is required", http.StatusBadRequest)
+		return Identity{}, false
+	case identity.ID == "":
+		identity.ID = claimed
+	case claimed != "" && claimed != identity.ID:
+		log.Printf("Data plane authenticated as %s (%s) claimed ID %s when polling; rejecting", identity.ID, identity.Method, claimed)
+		http.Error(w, "Forbidden: "+protocol.HeaderDataPlaneID+" does not match the authenticated data plane", http.StatusForbidden)
+		return Identity{}, false
+	}
+
+	if !s.allowDataPlane(w, r, identity.ID) {
+		return Identity{}, false
+	}
+	return identity, true
+}
+
+// beginPoll records an open poll, adding the data plane to the fleet on its first poll
+// A data plane whose labels changed is assigned a fresh view
+func (s *Server) beginPoll(identity Identity, labels map[string]string, r *http.Request) *dataPlane {
+	now := time.Now()
+
+	s.fleetMutex.Lock()
+	defer s.fleetMutex.Unlock()
+
+	dp, exists := s.dataPlanes[identity.ID]
+	if !exists || !dp.polling {
+		if exists && dp.isConnected(now) {
+			log.Printf("Data plane ID %s is already connected from %s; tracking the poller from %s", identity.ID, dp.remoteAddr, r.RemoteAddr)
+		}
+		dp = &dataPlane{
+			id:         identity.ID,
+			identified: true,
+			polling:    true,
+			notify:     make(chan struct{}, 1),
+		}
+		s.dataPlanes[dp.id] = dp
+		log.Printf("Polling data plane %s connected from %s", dp.id, r.RemoteAddr)
+	}
+	if !dp.isConnected(now) {
+		dp.connectedAt = now
+	}
+	if protocol.FormatLabels(labels) != protocol.FormatLabels(dp.labels) {
+		dp.labels = labels
+		dp.sentConfig = nil
+	}
+
+	dp.remoteAddr = r.RemoteAddr
+	dp.authMethod = identity.Method
+	dp.hostname = r.Header.Get(protocol.HeaderDataPlaneHostname)
+	dp.build = r.Header.Get(protocol.HeaderDataPlaneBuild)
+	dp.pollTimeout = s.pongTimeout
+	dp.lastSeen = now
+	dp.openPolls++
+	return dp
+}
+
+// endPoll records that a poll was answered or abandoned
+func (s *Server) endPoll(dp *dataPlane) {
+	s.fleetMutex.Lock()
+	dp.openPolls--
+	dp.lastSeen = time.Now()
+	s.fleetMutex.Unlock()
+}
+
+// polledConfig returns the config view assigned to a polling data plane
+// A data plane no rollout wave has reached yet gets the stable config, like a new WebSocket connection
+func (s *Server) polledConfig(dp *dataPlane) *config.Config {
+	s.fleetMutex.Lock()
+	cfg, labels := dp.sentConfig, dp.labels
+	s.fleetMutex.Unlock()
+	if cfg != nil {
+		return cfg
+	}
+
+	view := s.newViewCache(s.StableConfig()).get(labels)
+	s.fleetMutex.Lock()
+	defer s.fleetMutex.Unlock()
+	if dp.sentConfig == nil {
+		dp.sentConfig, dp.view = view.cfg, view.name
+	}
+	return dp.sentConfig
+}
+
+// writeSnapshot answers a poll with a signed config snapshot
+func (s *Server) writeSnapshot(w http.ResponseWriter, dp *dataPlane, cfg *config.Config) {
+	signature, err := s.sign(cfg)
+	if err != nil {
+		log.Printf("Failed to sign config: %v", err)
+		http.Error(w, "Failed to sign config", http.StatusInternalServerError)
+		return
+	}
+	data, err := snapshotMessage(cfg, signature)
+	if err != nil {
+		log.Printf("Failed to marshal config: %v", err)
+		http.Error(w, "Failed to marshal config", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", protocol.ETag(cfg.Version))
+	w.Write(data)
+	log.Printf("Served config snapshot version %s to polling data plane %s", cfg.Version, dp.id)
+}
+
+// offerConfig assigns a config to the connected polling data planes selected by include and wakes their open polls
+// Returns the IDs of the data planes it was offered to
+func (s *Server) offerConfig(views *viewCache, include func(dp *dataPlane) bool) []string {
+	now := time.Now()
+
+	s.fleetMutex.Lock()
+	defer s.fleetMutex.Unlock()
+
+	var offered []string
+	for id, dp := range s.dataPlanes {
+		if !dp.polling || !dp.isConnected(now) || !include(dp) {
+			continue
+		}
+		view := views.get(dp.labels)
+		dp.sentConfig, dp.view = view.cfg, view.name
+		signal(dp.notify)
+		log.Printf("Offered config version %s to polling data plane %s%s", view.cfg.Version, id, viewSuffix(view.name))
+		offered = append(offered, id)
+	}
+	return offered
+}
@@ -0,0 +1,239 @@
+package controlplane
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/dataplane"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// pollRequest sends a poll or ack request as data plane id and returns the response status, ETag and body
+func pollRequest(t *testing.T, method, url, id, etag string, body string, header http.Header) (int, string, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if id != "" {
+		req.Header.Set(protocol.HeaderDataPlaneID, id)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", protocol.ETag(etag))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, protocol.ParseETag(resp.Header.Get("ETag")), data
+}
+
+func TestPollHandler(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	cpServer := NewServer(loader)
+	server := httptest.NewServer(cpServer.PollHandler())
+	defer server.Close()
+	pollURL := server.URL + "/config"
+
+	// The first poll answers at once with the current config
+	status, etag, body := pollRequest(t, http.MethodGet, pollURL+"?wait=5s", "dp-1", "", "", nil)
+	if status != http.StatusOK || etag != "v1" {
+		t.Fatalf("first poll = %d (ETag %q), want 200 (ETag v1)", status, etag)
+	}
+	var snapshot protocol.ConfigSnapshotMessage
+	if err := json.Unmarshal(body, &snapshot); err != nil || snapshot.Type != protocol.MessageTypeConfigSnapshot || snapshot.Version != "v1" {
+		t.Fatalf("first poll body = %s, want a v1 snapshot", body)
+	}
+
+	ack := `{"type": "ack", "version": "v1"}`
+	if status, _, _ := pollRequest(t, http.MethodPost, pollURL+protocol.PollAckPath, "dp-1", "", ack, nil); status != http.StatusNoContent {
+		t.Fatalf("ack status = %d, want %d", status, http.StatusNoContent)
+	}
+	fleet := cpServer.FleetStatus()
+	if fleet.Applied != 1 || fleet.DataPlanes[0].Transport != "polling" {
+		t.Fatalf("fleet = %+v, want dp-1 polling and converged on v1", fleet)
+	}
+
+	// Holding the current version waits out the poll
+	start := time.Now()
+	if status, etag, _ := pollRequest(t, http.MethodGet, pollURL+"?wait=50ms", "dp-1", "v1", "", nil); status != http.StatusNotModified || etag != "v1" {
+		t.Fatalf("conditional poll = %d (ETag %q), want 304 (ETag v1)", status, etag)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("conditional poll returned after %v, want it held for the wait", elapsed)
+	}
+
+	// A published config wakes an open poll
+	req, _ := http.NewRequest(http.MethodGet, pollURL+"?wait=5s", nil)
+	req.Header.Set(protocol.HeaderDataPlaneID, "dp-1")
+	req.Header.Set("If-None-Match", protocol.ETag("v1"))
+	results := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			close(results)
+			return
+		}
+		resp.Body.Close()
+		results <- resp
+	}()
+	waitForRollout(t, "poll to open", func() bool {
+		cpServer.fleetMutex.Lock()
+		defer cpServer.fleetMutex.Unlock()
+		return cpServer.dataPlanes["dp-1"].openPolls == 1
+	})
+	if _, err := loader.Update("v1", func(cfg *config.Config) error {
+		cfg.RoutingTable["acme"] = "tier2"
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	cpServer.PublishConfig()
+
+	select {
+	case resp := <-results:
+		if resp == nil {
+			t.Fatal("open poll failed")
+		}
+		if etag := protocol.ParseETag(resp.Header.Get("ETag")); resp.StatusCode != http.StatusOK || etag != loader.GetConfigVersion() {
+			t.Errorf("woken poll = %d (ETag %q), want 200 (ETag %s)", resp.StatusCode, etag, loader.GetConfigVersion())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("open poll was not woken by the published config")
+	}
+
+	nack := `{"type": "nack", "version": "` + loader.GetConfigVersion() + `", "reason": "invalid_config", "error": "boom"}`
+	pollRequest(t, http.MethodPost, pollURL+protocol.PollAckPath, "dp-1", "", nack, nil)
+	if fleet := cpServer.FleetStatus(); fleet.Rejected != 1 || fleet.DataPlanes[0].LastError != "boom" {
+		t.Errorf("fleet = %+v, want dp-1 rejecting the new version", fleet)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		id         string
+		body       string
+		wantStatus int
+	}{
+		{name: "poll without data plane ID", method: http.MethodGet, path: "", wantStatus: http.StatusBadRequest},
+		{name: "invalid wait", method: http.MethodGet, path: "?wait=soon", id: "dp-1", wantStatus: http.StatusBadRequest},
+		{name: "ack from unknown data plane", method: http.MethodPost, path: protocol.PollAckPath, id: "dp-9", body: ack, wantStatus: http.StatusNotFound},
+		{name: "resync request", method: http.MethodPost, path: protocol.PollAckPath, id: "dp-1", body: `{"type": "resync_request"}`, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _, _ := pollRequest(t, tt.method, pollURL+tt.path, tt.id, "", tt.body, nil); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestPollHandler_Authentication(t *testing.T) {
+	credentials := issueCredentials(t, "dp-1", "dp-revoked")
+	cpServer, _ := newAuthTestServer(t, credentials, nil)
+	server := httptest.NewServer(cpServer.PollHandler())
+	defer server.Close()
+	revokedToken := bearer(t, credentials, "dp-revoked", "dp-revoked")
+	revoke(t, cpServer, "dp-revoked")
+
+	tests := []struct {
+		name       string
+		id         string
+		header     http.Header
+		wantStatus int
+	}{
+		{name: "valid token", header: bearer(t, credentials, "dp-1", "dp-1"), wantStatus: http.StatusOK},
+		{name: "valid token naming itself", id: "dp-1", header: bearer(t, credentials, "dp-1", "dp-1"), wantStatus: http.StatusOK},
+		{name: "missing token", id: "dp-1", wantStatus: http.StatusUnauthorized},
+		{name: "header claims another identity", id: "dp-2", header: bearer(t, credentials, "dp-1", "dp-1"), wantStatus: http.StatusForbidden},
+		{name: "revoked data plane", header: revokedToken, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _, _ := pollRequest(t, http.MethodGet, server.URL+"/config", tt.id, "", "", tt.header); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServer_PollingDataPlaneConverges(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	cpLoader := config.NewLoader(tmpFile, time.Second)
+	if err := cpLoader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	cpServer := NewServer(cpLoader)
+	server := httptest.NewServer(cpServer.PollHandler())
+	defer server.Close()
+
+	dpLoader := config.NewLoader(tmpFile, time.Second)
+	if err := dpLoader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	client, err := dataplane.NewClient(server.URL+"/config", dpLoader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.SetIdentity(dataplane.Identity{ID: "router-1", Hostname: "host-1", Build: "abc123"})
+	client.SetHeartbeat(200*time.Millisecond, time.Second)
+	client.Start()
+	defer client.Stop()
+
+	waitForRollout(t, "router-1 to ack v1", func() bool { return cpServer.FleetStatus().Applied == 1 })
+	if dp := cpServer.FleetStatus().DataPlanes[0]; dp.ID != "router-1" || dp.Hostname != "host-1" || dp.Transport != "polling" {
+		t.Errorf("data plane = %+v, want router-1 on host-1 polling", dp)
+	}
+
+	if _, err := cpLoader.Update("v1", func(cfg *config.Config) error {
+		cfg.RoutingTable["acme"] = "tier2"
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	cpServer.PublishConfig()
+
+	waitForRollout(t, "router-1 to apply the new version", func() bool {
+		return dpLoader.GetConfigVersion() == cpLoader.GetConfigVersion() && cpServer.FleetStatus().Converged
+	})
+	if !client.Connected() {
+		t.Error("polling client not reported as connected")
+	}
+}
@@ -0,0 +1,219 @@
+package controlplane
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+// Release is what the leader publishes to the other replicas: the stable config and the rollout in progress
+// Followers serve it to their data planes instead of the file they reload; a new leader resumes its rollout
+type Release struct {
+	Stable  *config.Config `json:"stable"`
+	Rollout *RolloutRecord `json:"rollout,omitempty"`
+}
+
+// RolloutRecord is the resumable state of a rollout in progress
+type RolloutRecord struct {
+	Config     *config.Config `json:"config"`
+	State      RolloutState   `json:"state"`
+	Wave       int            `json:"wave"`
+	Updated    []string       `json:"updated"`
+	Superseded []string       `json:"superseded,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+}
+
+// ErrNotLeaseHolder is returned when saving a release without holding an unexpired leader lease
+var ErrNotLeaseHolder = errors.New("leader lease not held")
+
+// ReleaseStore shares the leader's release with the other control plane replicas
+type ReleaseStore interface {
+	// Load returns the last saved release, or nil if none was saved
+	Load() (*Release, error)
+	// Save replaces the saved release if holder holds an unexpired lease, otherwise returns ErrNotLeaseHolder
+	// A replica that lost leadership without noticing cannot overwrite the new leader's release
+	Save(release *Release, holder string) error
+}
+
+// FileRelease is a release store over a file on the storage shared by the replicas, next to the lease
+type FileRelease struct {
+	path  string
+	lease *FileLease
+}
+
+// NewFileRelease creates a release store next to a file lease, fencing saves with that lease
+func NewFileRelease(lease *FileLease) *FileRelease {
+	return &FileRelease{path: lease.path + ".release", lease: lease}
+}
+
+// Load returns the saved release, or nil if none was saved
+func (f *FileRelease) Load() (*Release, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release: %w", err)
+	}
+	var release Release
+	if err := json.Unmarshal(data, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse release %s: %w", f.path, err)
+	}
+	if release.Stable == nil {
+		return nil, fmt.Errorf("release %s has no stable config", f.path)
+	}
+	return &release, nil
+}
+
+// Save replaces the saved release atomically if holder holds the lease
+// The lease is checked and the release written under the lease lock, so the lease cannot change hands in between
+func (f *FileRelease) Save(release *Release, holder string) error {
+	data, err := json.Marshal(release)
+	if err != nil {
+		return err
+	}
+
+	return f.lease.locked(func() error {
+		lease, err := f.lease.read()
+		if err != nil {
+			return err
+		}
+		if lease.Holder != holder || !time.Now().Before(lease.ExpiresAt) {
+			return fmt.Errorf("%w by %s (held by %s)", ErrNotLeaseHolder, holder, lease.Holder)
+		}
+
+		tmp := fmt.Sprintf("%s.tmp-%d", f.path, os.Getpid())
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return fmt.Errorf("failed to write release: %w", err)
+		}
+		if err := os.Rename(tmp, f.path); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write release: %w", err)
+		}
+		return nil
+	})
+}
+
+// record returns the resumable state of the rollout (must be called with rolloutMutex held)
+func (r *rollout) record() *RolloutRecord {
+	return &RolloutRecord{
+		Config:     r.cfg,
+		State:      r.state,
+		Wave:       r.wave,
+		Updated:    sortedIDs(r.updated),
+		Superseded: sortedIDs(r.superseded),
+		StartedAt:  r.startedAt,
+	}
+}
+
+// saveRelease publishes the stable config and the rollout in progress if this replica leads
+func (s *Server) saveRelease() {
+	if s.releases == nil || !s.IsLeader() {
+		return
+	}
+
+	s.releaseMutex.Lock()
+	defer s.releaseMutex.Unlock()
+
+	s.rolloutMutex.Lock()
+	release := &Release{Stable: s.stableConfig}
+	if r := s.rollout; r != nil && !r.done() {
+		release.Rollout = r.record()
+	}
+	s.rolloutMutex.Unlock()
+
+	if err := s.releases.Save(release, s.elector.id); err != nil {
+		log.Printf("Failed to save release: %v", err)
+	}
+}
+
+// followRelease sends each data plane the version the leader's release assigns it:
+// the rollout version to data planes the rollout reached, the stable version to the rest
+func (s *Server) followRelease() {
+	if s.releases == nil {
+		return
+	}
+	release, err := s.releases.Load()
+	if err != nil {
+		log.Printf("Failed to load release: %v", err)
+		return
+	}
+	if release == nil {
+		return // No leader has published yet; keep serving the config loaded at startup
+	}
+
+	if s.StableConfig().Version != release.Stable.Version {
+		log.Printf("Following leader's stable config version %s", release.Stable.Version)
+	}
+	s.setStableConfig(release.Stable)
+	s.broadcastMutex.Lock()
+	s.broadcastVersion = s.configLoader.GetConfigVersion()
+	s.broadcastMutex.Unlock()
+
+	rolling := make(map[string]bool)
+	if release.Rollout != nil {
+		for _, id := range release.Rollout.Updated {
+			rolling[id] = true
+		}
+		s.sendConfig(release.Rollout.Config, func(dp *dataPlane) bool {
+			return rolling[dp.id] && !dp.wasSent(release.Rollout.Config.Version)
+		})
+	}
+	s.sendConfig(release.Stable, func(dp *dataPlane) bool {
+		return !rolling[dp.id] && !dp.wasSent(release.Stable.Version)
+	})
+}
+
+// resumeRelease takes over the release of the previous leader, resuming its rollout if one was in progress
+func (s *Server) resumeRelease() {
+	if s.releases == nil {
+		return
+	}
+	release, err := s.releases.Load()
+	if err != nil {
+		log.Printf("Failed to load release: %v", err)
+		return
+	}
+	if release == nil {
+		s.saveRelease()
+		return
+	}
+
+	s.rolloutMutex.Lock()
+	strategy := s.rolloutStrategy
+	s.stableConfig = release.Stable
+	s.rolloutMutex.Unlock()
+
+	version := release.Stable.Version
+	if record := release.Rollout; record != nil && strategy != nil {
+		version = record.Config.Version
+		s.resumeRollout(release.Stable, record, *strategy)
+	}
+
+	// The loader's version is published as usual if the release lags behind it
+	s.broadcastMutex.Lock()
+	s.broadcastVersion = version
+	s.broadcastMutex.Unlock()
+	s.saveRelease()
+}
+
+// sortedIDs returns the IDs in a set in sorted order
+func sortedIDs(set map[string]bool) []string {
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// wasSent reports whether the last config sent to the data plane has version (must be called with fleet lock held)
+func (dp *dataPlane) wasSent(version string) bool {
+	return dp.sentConfig != nil && dp.sentConfig.Version == version
+}
@@ -0,0 +1,133 @@
+package controlplane
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// RevocationStore holds the data plane IDs refused on connect
+// With a path the IDs are kept in a JSON file (["<id>", ...]) that replicas may share, so a
+// revocation made on one replica survives restarts and is enforced by every replica reading the file
+type RevocationStore struct {
+	file    *sharedFile // Nil keeps revocations in memory only
+	revoked map[string]bool
+	mu      sync.Mutex
+}
+
+// NewRevocationStore creates a revocation store backed by the file at path, loading it if it exists
+func NewRevocationStore(path string) (*RevocationStore, error) {
+	r := &RevocationStore{
+		revoked: make(map[string]bool),
+	}
+	if path != "" {
+		r.file = &sharedFile{path: path}
+		if err := r.reload(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// IsRevoked reports whether a data plane ID has been revoked
+func (r *RevocationStore) IsRevoked(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.reload(); err != nil {
+		// Keep enforcing the revocations last read
+		log.Printf("Failed to reload revoked data planes: %v", err)
+	}
+	return r.revoked[id]
+}
+
+// List returns the revoked data plane IDs in sorted order
+func (r *RevocationStore) List() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.reload(); err != nil {
+		log.Printf("Failed to reload revoked data planes: %v", err)
+	}
+	return sortedIDs(r.revoked)
+}
+
+// Revoke adds a data plane ID to the store
+func (r *RevocationStore) Revoke(id string) error {
+	_, err := r.update(func(revoked map[string]bool) bool {
+		revoked[id] = true
+		return true
+	})
+	return err
+}
+
+// Reinstate removes a data plane ID from the store, returning false if it was not revoked
+func (r *RevocationStore) Reinstate(id string) (bool, error) {
+	return r.update(func(revoked map[string]bool) bool {
+		if !revoked[id] {
+			return false
+		}
+		delete(revoked, id)
+		return true
+	})
+}
+
+// update applies a change to the revoked IDs, persisting them under the file lock if the store has a file
+// Nothing is written when change returns false
+func (r *RevocationStore) update(change func(revoked map[string]bool) bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return change(r.revoked), nil
+	}
+
+	unlock, err := r.file.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	// Start from the file so revocations made by other replicas are kept
+	if err := r.reload(); err != nil {
+		return false, err
+	}
+	revoked := make(map[string]bool, len(r.revoked))
+	for id := range r.revoked {
+		revoked[id] = true
+	}
+	if !change(revoked) {
+		return false, nil
+	}
+
+	data, err := json.MarshalIndent(sortedIDs(revoked), "", "  ")
+	if err != nil {
+		return false, err
+	}
+	if err := r.file.write(data, 0644); err != nil {
+		return false, err
+	}
+	r.revoked = revoked
+	return true, nil
+}
+
+// reload rereads the file if it changed since it was last read (must be called with mu held)
+func (r *RevocationStore) reload() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.reload(func(data []byte) error {
+		var ids []string
+		if data != nil {
+			if err := json.Unmarshal(data, &ids); err != nil {
+				return err
+			}
+		}
+		revoked := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			revoked[id] = true
+		}
+		r.revoked = revoked
+		return nil
+	})
+}
@@ -0,0 +1,535 @@
+package controlplane
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+// rolloutPollInterval is how often a rollout checks data plane acks
+const rolloutPollInterval = 50 * time.Millisecond
+
+// ErrNoActiveRollout is returned by rollout controls when no rollout can be changed
+var ErrNoActiveRollout = errors.New("no active rollout")
+
+// RolloutStrategy stages new config versions across the fleet
+type RolloutStrategy struct {
+	Waves        []float64     // Cumulative fraction of data planes per wave; the first wave is the canary
+	BakeTime     time.Duration // Wait after a wave is acked before starting the next
+	AckTimeout   time.Duration // Max wait for a wave's acks; silent data planes count as failed
+	MaxNackRatio float64       // Roll back when a wave's failed fraction exceeds this
+}
+
+// Validate checks that waves increase up to the whole fleet and ratios are in range
+func (s RolloutStrategy) Validate() error {
+	if len(s.Waves) == 0 {
+		return fmt.Errorf("at least one wave is required")
+	}
+	previous := 0.0
+	for _, fraction := range s.Waves {
+		if fraction <= previous || fraction > 1 {
+			return fmt.Errorf("waves must increase within (0, 1], got %v", s.Waves)
+		}
+		previous = fraction
+	}
+	if previous != 1 {
+		return fmt.Errorf("last wave must cover the whole fleet (1), got %v", previous)
+	}
+	if s.BakeTime < 0 || s.AckTimeout <= 0 {
+		return fmt.Errorf("bake time must be non-negative and ack timeout positive")
+	}
+	if s.MaxNackRatio < 0 || s.MaxNackRatio > 1 {
+		return fmt.Errorf("max nack ratio must be within [0, 1], got %v", s.MaxNackRatio)
+	}
+	return nil
+}
+
+// RolloutState is the lifecycle state of a rollout
+type RolloutState string
+
+const (
+	RolloutRunning    RolloutState = "running"
+	RolloutPaused     RolloutState = "paused"
+	RolloutCompleted  RolloutState = "completed"
+	RolloutRolledBack RolloutState = "rolled_back" // Halted on failures, data planes returned to the previous version
+	RolloutAborted    RolloutState = "aborted"     // Stopped by an operator, data planes returned to the previous version
+	RolloutSuperseded RolloutState = "superseded"  // Replaced by a rollout of a newer version
+	RolloutHandedOver RolloutState = "handed_over" // Leadership moved to another replica, which resumes it
+)
+
+// rollout tracks one version being staged across the fleet (guarded by Server.rolloutMutex)
+type rollout struct {
+	cfg        *config.Config
+	previous   *config.Config
+	strategy   RolloutStrategy
+	state      RolloutState
+	wave       int             // Waves dispatched so far
+	updated    map[string]bool // Data planes sent the new version
+	superseded map[string]bool // Data planes sent a version by rollouts this one superseded
+	failed     []string
+	reason     string
+	startedAt  time.Time
+	finishedAt time.Time
+	wake       chan struct{} // Signals state changes to the rollout goroutine
+}
+
+// done reports whether the rollout has reached a final state
+func (r *rollout) done() bool {
+	return r.state != RolloutRunning && r.state != RolloutPaused
+}
+
+// RolloutStatus is a point-in-time view of a rollout
+type RolloutStatus struct {
+	Version         string       `json:"version"`
+	PreviousVersion string       `json:"previous_version"`
+	State           RolloutState `json:"state"`
+	Wave            int          `json:"wave"`
+	Waves           int          `json:"waves"`
+	Updated         []string     `json:"updated"`
+	Failed          []string     `json:"failed,omitempty"`
+	Reason          string       `json:"reason,omitempty"`
+	StartedAt       time.Time    `json:"started_at"`
+	FinishedAt      *time.Time   `json:"finished_at,omitempty"`
+}
+
+// SetRolloutStrategy stages future config versions; nil pushes them to every data plane at once
+func (s *Server) SetRolloutStrategy(strategy *RolloutStrategy) {
+	s.rolloutMutex.Lock()
+	defer s.rolloutMutex.Unlock()
+	s.rolloutStrategy = strategy
+}
+
+// StableConfig returns the config data planes receive on connect
+func (s *Server) StableConfig() *config.Config {
+	s.rolloutMutex.Lock()
+	defer s.rolloutMutex.Unlock()
+	return s.stableConfig
+}
+
+// setStableConfig records the config every data plane should converge on
+func (s *Server) setStableConfig(cfg *config.Config) {
+	s.rolloutMutex.Lock()
+	defer s.rolloutMutex.Unlock()
+	s.stableConfig = cfg
+}
+
+// PublishConfig pushes the loader's current config to data planes, in waves if a strategy is set
+// Only the leader publishes the loader's config; followers serve the release the leader saved
+func (s *Server) PublishConfig() {
+	if !s.IsLeader() {
+		s.followRelease()
+		return
+	}
+
+	s.rolloutMutex.Lock()
+	strategy := s.rolloutStrategy
+	s.rolloutMutex.Unlock()
+
+	if strategy == nil {
+		s.BroadcastConfig()
+		return
+	}
+	s.startRollout(s.configLoader.GetConfig(), *strategy)
+}
+
+// startRollout begins staging cfg across the fleet, superseding any rollout in progress
+func (s *Server) startRollout(cfg *config.Config, strategy RolloutStrategy) {
+	s.broadcastMutex.Lock()
+	s.broadcastVersion = cfg.Version
+	s.broadcastMutex.Unlock()
+
+	r := &rollout{
+		cfg:        cfg,
+		strategy:   strategy,
+		state:      RolloutRunning,
+		updated:    make(map[string]bool),
+		superseded: make(map[string]bool),
+		startedAt:  time.Now(),
+		wake:       make(chan struct{}, 1),
+	}
+
+	s.rolloutMutex.Lock()
+	if previous := s.rollout; previous != nil && !previous.done() {
+		previous.state = RolloutSuperseded
+		previous.finishedAt = time.Now()
+		signal(previous.wake)
+		log.Printf("Rollout of version %s superseded by %s", previous.cfg.Version, cfg.Version)
+
+		// Data planes the superseded rollout reached run neither version; a rollback must return them too
+		for id := range previous.updated {
+			r.superseded[id] = true
+		}
+		for id := range previous.superseded {
+			r.superseded[id] = true
+		}
+	}
+	r.previous = s.stableConfig
+	s.rollout = r
+	s.rolloutMutex.Unlock()
+	s.saveRelease()
+
+	log.Printf("Starting rollout of version %s (previous %s) in %d waves", cfg.Version, r.previous.Version, len(strategy.Waves))
+	go s.runRollout(r, 0)
+}
+
+// resumeRollout continues a rollout the previous leader saved, from its last dispatched wave
+// The wave is dispatched again so data planes now connected here that it covers are included
+func (s *Server) resumeRollout(stable *config.Config, record *RolloutRecord, strategy RolloutStrategy) {
+	r := &rollout{
+		cfg:        record.Config,
+		previous:   stable,
+		strategy:   strategy,
+		state:      RolloutRunning,
+		wave:       record.Wave,
+		updated:    make(map[string]bool),
+		superseded: make(map[string]bool),
+		startedAt:  record.StartedAt,
+		wake:       make(chan struct{}, 1),
+	}
+	if record.State == RolloutPaused {
+		r.state = RolloutPaused
+	}
+	for _, id := range record.Updated {
+		r.updated[id] = true
+	}
+	for _, id := range record.Superseded {
+		r.superseded[id] = true
+	}
+
+	s.rolloutMutex.Lock()
+	if previous := s.rollout; previous != nil && !previous.done() {
+		previous.state = RolloutSuperseded
+		previous.finishedAt = time.Now()
+		signal(previous.wake)
+	}
+	s.rollout = r
+	s.rolloutMutex.Unlock()
+
+	start := min(max(record.Wave-1, 0), len(strategy.Waves)-1)
+	log.Printf("Resuming rollout of version %s (previous %s) at wave %d/%d", r.cfg.Version, stable.Version, start+1, len(strategy.Waves))
+	go s.runRollout(r, start)
+}
+
+// handOverRollout stops driving the rollout in progress without rolling it back, for the next leader to resume
+func (s *Server) handOverRollout() {
+	s.rolloutMutex.Lock()
+	r := s.rollout
+	if r == nil || r.done() {
+		s.rolloutMutex.Unlock()
+		return
+	}
+	r.state = RolloutHandedOver
+	r.finishedAt = time.Now()
+	s.rolloutMutex.Unlock()
+
+	signal(r.wake)
+	log.Printf("Handed over rollout of version %s after losing leadership", r.cfg.Version)
+}
+
+// RolloutStatus returns the latest rollout, or false if none has started
+func (s *Server) RolloutStatus() (RolloutStatus, bool) {
+	s.rolloutMutex.Lock()
+	defer s.rolloutMutex.Unlock()
+
+	r := s.rollout
+	if r == nil {
+		return RolloutStatus{}, false
+	}
+
+	status := RolloutStatus{
+		Version:         r.cfg.Version,
+		PreviousVersion: r.previous.Version,
+		State:           r.state,
+		Wave:            r.wave,
+		Waves:           len(r.strategy.Waves),
+		Updated:         make([]string, 0, len(r.updated)),
+		Failed:          r.failed,
+		Reason:          r.reason,
+		StartedAt:       r.startedAt,
+	}
+	for id := range r.updated {
+		status.Updated = append(status.Updated, id)
+	}
+	sort.Strings(status.Updated)
+	if !r.finishedAt.IsZero() {
+		finishedAt := r.finishedAt
+		status.FinishedAt = &finishedAt
+	}
+	return status, true
+}
+
+// PauseRollout stops a running rollout before its next wave
+func (s *Server) PauseRollout() error {
+	return s.controlRollout(RolloutRunning, RolloutPaused)
+}
+
+// ResumeRollout continues a paused rollout
+func (s *Server) ResumeRollout() error {
+	return s.controlRollout(RolloutPaused, RolloutRunning)
+}
+
+// AbortRollout stops a rollout and returns updated data planes to the previous version
+func (s *Server) AbortRollout() error {
+	s.rolloutMutex.Lock()
+	r := s.rollout
+	if r == nil || r.done() {
+		s.rolloutMutex.Unlock()
+		return ErrNoActiveRollout
+	}
+	r.state = RolloutAborted
+	r.reason = "aborted by operator"
+	s.rolloutMutex.Unlock()
+
+	signal(r.wake)
+	return nil
+}
+
+// controlRollout moves the active rollout between running and paused
+func (s *Server) controlRollout(from, to RolloutState) error {
+	s.rolloutMutex.Lock()
+	r := s.rollout
+	if r == nil || r.state != from {
+		s.rolloutMutex.Unlock()
+		return fmt.Errorf("%w: no %s rollout", ErrNoActiveRollout, from)
+	}
+	r.state = to
+	s.rolloutMutex.Unlock()
+
+	signal(r.wake)
+	log.Printf("Rollout of version %s %s", r.cfg.Version, to)
+	s.saveRelease()
+	return nil
+}
+
+// runRollout dispatches waves from index start, checking acks and baking between them
+func (s *Server) runRollout(r *rollout, start int) {
+	for i := start; i < len(r.strategy.Waves); i++ {
+		fraction := r.strategy.Waves[i]
+		if !s.awaitRunning(r, time.Time{}) {
+			s.stopRollout(r)
+			return
+		}
+
+		targets := s.dispatchWave(r, i, fraction)
+		failed, connected, ok := s.awaitAcks(r, targets)
+		if !ok {
+			s.stopRollout(r)
+			return
+		}
+
+		if ratio := failureRatio(failed, connected); ratio > r.strategy.MaxNackRatio {
+			s.rollBack(r, RolloutRolledBack, fmt.Sprintf("wave %d: %d of %d data planes failed to apply (ratio %.2f > %.2f)",
+				i+1, len(failed), connected, ratio, r.strategy.MaxNackRatio))
+			return
+		}
+
+		if i < len(r.strategy.Waves)-1 && r.strategy.BakeTime > 0 {
+			if !s.awaitRunning(r, time.Now().Add(r.strategy.BakeTime)) {
+				s.stopRollout(r)
+				return
+			}
+		}
+	}
+	s.completeRollout(r)
+}
+
+// dispatchWave sends the new version to the data planes added by a wave
+// Waves cover the first fraction of connected data planes ordered by ID, so the canary set is stable
+func (s *Server) dispatchWave(r *rollout, wave int, fraction float64) map[string]bool {
+	connected := s.connectedDataPlanes()
+	count := int(math.Ceil(fraction * float64(len(connected))))
+
+	s.rolloutMutex.Lock()
+	if r.done() {
+		// Superseded or aborted since the last check; a newer rollout now owns the fleet
+		s.rolloutMutex.Unlock()
+		return nil
+	}
+	targets := make(map[string]bool)
+	for _, id := range connected[:count] {
+		if !r.updated[id] {
+			targets[id] = true
+			r.updated[id] = true
+		}
+	}
+	r.wave = wave + 1
+	s.rolloutMutex.Unlock()
+	s.saveRelease() // Before sending, so a new leader knows which data planes may have it
+
+	sent := s.sendConfig(r.cfg, func(dp *dataPlane) bool { return targets[dp.id] })
+	log.Printf("Rollout of version %s: wave %d/%d sent to %d data planes", r.cfg.Version, wave+1, len(r.strategy.Waves), len(sent))
+	return targets
+}
+
+// awaitAcks waits until every target acked or nacked the new version, or the ack timeout passes
+// Returns the data planes that nacked or stayed silent, how many targets are still connected,
+// and false if the rollout was stopped
+func (s *Server) awaitAcks(r *rollout, targets map[string]bool) ([]string, int, bool) {
+	deadline := time.Now().Add(r.strategy.AckTimeout)
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.rolloutMutex.Lock()
+		stopped := r.state != RolloutRunning && r.state != RolloutPaused
+		s.rolloutMutex.Unlock()
+		if stopped {
+			return nil, 0, false
+		}
+
+		failed, pending, connected := s.waveProgress(r.cfg.Version, targets)
+		timedOut := time.Now().After(deadline)
+		if len(pending) == 0 || timedOut {
+			failed = append(failed, pending...)
+			sort.Strings(failed)
+
+			s.rolloutMutex.Lock()
+			r.failed = append(r.failed, failed...)
+			s.rolloutMutex.Unlock()
+			return failed, connected, true
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// waveProgress splits connected targets into those that nacked and those yet to respond, and counts the connected targets
+func (s *Server) waveProgress(version string, targets map[string]bool) (failed, pending []string, connected int) {
+	s.fleetMutex.Lock()
+	defer s.fleetMutex.Unlock()
+
+	now := time.Now()
+	for id := range targets {
+		dp, exists := s.dataPlanes[id]
+		if !exists || !dp.isConnected(now) {
+			// Disconnected data planes no longer count towards the wave
+			continue
+		}
+		connected++
+		switch {
+		case dp.appliedVersion == version:
+		case dp.lastNackVersion == version:
+			failed = append(failed, id)
+		default:
+			pending = append(pending, id)
+		}
+	}
+	return failed, pending, connected
+}
+
+// awaitRunning blocks until the rollout is running and, if set, the deadline has passed
+// Returns false if the rollout was stopped
+func (s *Server) awaitRunning(r *rollout, until time.Time) bool {
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.rolloutMutex.Lock()
+		state := r.state
+		s.rolloutMutex.Unlock()
+
+		switch {
+		case state != RolloutRunning && state != RolloutPaused:
+			return false
+		case state == RolloutRunning && !time.Now().Before(until):
+			return true
+		}
+
+		select {
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// stopRollout handles an operator abort or a newer rollout taking over
+func (s *Server) stopRollout(r *rollout) {
+	s.rolloutMutex.Lock()
+	state, reason := r.state, r.reason
+	s.rolloutMutex.Unlock()
+
+	if state == RolloutAborted {
+		s.rollBack(r, RolloutAborted, reason)
+	}
+}
+
+// rollBack returns data planes sent the new version, or a version of a superseded rollout, to the previous one
+func (s *Server) rollBack(r *rollout, state RolloutState, reason string) {
+	s.rolloutMutex.Lock()
+	r.state = state
+	r.reason = reason
+	r.finishedAt = time.Now()
+	updated := make(map[string]bool, len(r.updated)+len(r.superseded))
+	for id := range r.updated {
+		updated[id] = true
+	}
+	for id := range r.superseded {
+		updated[id] = true
+	}
+	s.rolloutMutex.Unlock()
+	s.saveRelease()
+
+	log.Printf("Rolling back version %s to %s: %s", r.cfg.Version, r.previous.Version, reason)
+	s.sendConfig(r.previous, func(dp *dataPlane) bool { return updated[dp.id] })
+}
+
+// completeRollout makes the new version stable and sends it to data planes that joined mid-rollout
+func (s *Server) completeRollout(r *rollout) {
+	s.rolloutMutex.Lock()
+	if r.done() {
+		s.rolloutMutex.Unlock()
+		return
+	}
+	r.state = RolloutCompleted
+	r.finishedAt = time.Now()
+	s.stableConfig = r.cfg
+	updated := make(map[string]bool, len(r.updated))
+	for id := range r.updated {
+		updated[id] = true
+	}
+	s.rolloutMutex.Unlock()
+	s.saveRelease()
+
+	s.sendConfig(r.cfg, func(dp *dataPlane) bool { return !updated[dp.id] })
+	log.Printf("Rollout of version %s completed", r.cfg.Version)
+}
+
+// connectedDataPlanes returns the IDs of connected data planes in sorted order
+func (s *Server) connectedDataPlanes() []string {
+	s.fleetMutex.Lock()
+	defer s.fleetMutex.Unlock()
+
+	now := time.Now()
+	var ids []string
+	for id, dp := range s.dataPlanes {
+		if dp.isConnected(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// failureRatio returns the fraction of a wave's connected targets that failed
+func failureRatio(failed []string, connected int) float64 {
+	if connected == 0 {
+		return 0
+	}
+	return float64(len(failed)) / float64(connected)
+}
+
+// signal wakes a goroutine waiting on ch without blocking
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
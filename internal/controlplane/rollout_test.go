@@ -0,0 +1,347 @@
+package controlplane
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// fakeDataPlane acks every snapshot or delta except versions listed in reject
+type fakeDataPlane struct {
+	conn   *websocket.Conn
+	reject map[string]bool
+	done   chan struct{} // Closed once the connection fails
+
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	received []string
+	kinds    []protocol.MessageType
+}
+
+func dialFakeDataPlane(t *testing.T, url, id string, reject ...string) *fakeDataPlane {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	return startFakeDataPlane(t, conn, id, reject...)
+}
+
+func startFakeDataPlane(t *testing.T, conn *websocket.Conn, id string, reject ...string) *fakeDataPlane {
+	t.Helper()
+	dp := &fakeDataPlane{conn: conn, reject: make(map[string]bool), done: make(chan struct{})}
+	for _, version := range reject {
+		dp.reject[version] = true
+	}
+	if err := dp.send(protocol.HelloMessage{Type: protocol.MessageTypeHello, ID: id}); err != nil {
+		t.Fatalf("hello failed: %v", err)
+	}
+	go dp.run()
+	return dp
+}
+
+func (dp *fakeDataPlane) run() {
+	defer close(dp.done)
+	for {
+		var msg protocol.Message
+		if err := dp.conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		if msg.Type != protocol.MessageTypeConfigSnapshot && msg.Type != protocol.MessageTypeConfigDelta {
+			continue
+		}
+
+		dp.mu.Lock()
+		dp.received = append(dp.received, msg.Version)
+		dp.kinds = append(dp.kinds, msg.Type)
+		dp.mu.Unlock()
+
+		if dp.reject[msg.Version] {
+			dp.send(protocol.NackMessage{Type: protocol.MessageTypeNack, Version: msg.Version, Error: "rejected"})
+		} else {
+			dp.send(protocol.AckMessage{Type: protocol.MessageTypeAck, Version: msg.Version})
+		}
+	}
+}
+
+func (dp *fakeDataPlane) send(msg interface{}) error {
+	dp.writeMu.Lock()
+	defer dp.writeMu.Unlock()
+	return dp.conn.WriteJSON(msg)
+}
+
+func (dp *fakeDataPlane) receivedVersions() string {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	return strings.Join(dp.received, ",")
+}
+
+func (dp *fakeDataPlane) receivedKinds() []protocol.MessageType {
+	dp.mu.Lock()
+	defer dp.mu.Unlock()
+	return append([]protocol.MessageType(nil), dp.kinds...)
+}
+
+func TestServer_Rollout(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  RolloutStrategy
+		reject    map[string]string // Data plane ID -> rejected version
+		control   func(t *testing.T, s *Server, dps map[string]*fakeDataPlane)
+		wantState RolloutState
+		wantSeen  map[string]string // Data plane ID -> snapshot versions received
+	}{
+		{
+			name:      "all waves acked",
+			strategy:  RolloutStrategy{Waves: []float64{0.25, 1}, AckTimeout: time.Second},
+			wantState: RolloutCompleted,
+			wantSeen:  map[string]string{"dp-1": "v1,v2", "dp-2": "v1,v2", "dp-3": "v1,v2", "dp-4": "v1,v2"},
+		},
+		{
+			name:      "canary nack rolls back",
+			strategy:  RolloutStrategy{Waves: []float64{0.25, 1}, AckTimeout: time.Second},
+			reject:    map[string]string{"dp-1": "v2"},
+			wantState: RolloutRolledBack,
+			wantSeen:  map[string]string{"dp-1": "v1,v2,v1", "dp-2": "v1", "dp-3": "v1", "dp-4": "v1"},
+		},
+		{
+			name:      "nacks within ratio proceed",
+			strategy:  RolloutStrategy{Waves: []float64{0.5, 1}, AckTimeout: time.Second, MaxNackRatio: 0.5},
+			reject:    map[string]string{"dp-1": "v2"},
+			wantState: RolloutCompleted,
+			wantSeen:  map[string]string{"dp-1": "v1,v2", "dp-2": "v1,v2", "dp-3": "v1,v2", "dp-4": "v1,v2"},
+		},
+		{
+			name:     "paused rollout holds the next wave until resumed",
+			strategy: RolloutStrategy{Waves: []float64{0.25, 1}, BakeTime: 100 * time.Millisecond, AckTimeout: time.Second},
+			control: func(t *testing.T, s *Server, dps map[string]*fakeDataPlane) {
+				waitForRollout(t, "canary to receive v2", func() bool { return dps["dp-1"].receivedVersions() == "v1,v2" })
+				if err := s.PauseRollout(); err != nil {
+					t.Fatalf("PauseRollout error = %v", err)
+				}
+				time.Sleep(300 * time.Millisecond)
+				if status, _ := s.RolloutStatus(); status.State != RolloutPaused || status.Wave != 1 {
+					t.Errorf("paused status = %+v, want paused in wave 1", status)
+				}
+				if seen := dps["dp-4"].receivedVersions(); seen != "v1" {
+					t.Errorf("dp-4 received %s while paused, want v1", seen)
+				}
+				if err := s.ResumeRollout(); err != nil {
+					t.Fatalf("ResumeRollout error = %v", err)
+				}
+			},
+			wantState: RolloutCompleted,
+			wantSeen:  map[string]string{"dp-1": "v1,v2", "dp-2": "v1,v2", "dp-3": "v1,v2", "dp-4": "v1,v2"},
+		},
+		{
+			name:     "abort rolls back the canary",
+			strategy: RolloutStrategy{Waves: []float64{0.25, 1}, BakeTime: time.Minute, AckTimeout: time.Second},
+			control: func(t *testing.T, s *Server, dps map[string]*fakeDataPlane) {
+				waitForRollout(t, "canary to receive v2", func() bool { return dps["dp-1"].receivedVersions() == "v1,v2" })
+				if err := s.AbortRollout(); err != nil {
+					t.Fatalf("AbortRollout error = %v", err)
+				}
+			},
+			wantState: RolloutAborted,
+			wantSeen:  map[string]string{"dp-1": "v1,v2,v1", "dp-2": "v1", "dp-3": "v1", "dp-4": "v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile := t.TempDir() + "/config.json"
+			cfgJSON := `{
+				"version": "v1",
+				"routingTable": {"acme": "tier1"},
+				"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+				"defaultPlacement": "tier1"
+			}`
+			if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+			loader := config.NewLoader(tmpFile, time.Second)
+			if err := loader.LoadInitial(); err != nil {
+				t.Fatalf("LoadInitial failed: %v", err)
+			}
+
+			cpServer := NewServer(loader)
+			strategy := tt.strategy
+			cpServer.SetRolloutStrategy(&strategy)
+
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				cpServer.HandleConnection(conn)
+			}))
+			defer server.Close()
+
+			dps := make(map[string]*fakeDataPlane)
+			for i := 1; i <= 4; i++ {
+				id := fmt.Sprintf("dp-%d", i)
+				dp := dialFakeDataPlane(t, "ws"+strings.TrimPrefix(server.URL, "http"), id, tt.reject[id])
+				defer dp.conn.Close()
+				dps[id] = dp
+			}
+			waitForRollout(t, "fleet converged on v1", func() bool {
+				status := cpServer.FleetStatus()
+				return status.Applied == 4 && status.DataPlanes[0].ID == "dp-1"
+			})
+
+			if _, err := loader.Update("v1", func(cfg *config.Config) error {
+				cfg.RoutingTable["acme"] = "tier2"
+				return nil
+			}); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			cpServer.PublishConfig()
+
+			if tt.control != nil {
+				tt.control(t, cpServer, dps)
+			}
+
+			waitForRollout(t, "rollout to finish", func() bool {
+				status, _ := cpServer.RolloutStatus()
+				return status.FinishedAt != nil
+			})
+			if status, _ := cpServer.RolloutStatus(); status.State != tt.wantState {
+				t.Fatalf("rollout state = %s (%s), want %s", status.State, status.Reason, tt.wantState)
+			}
+
+			waitForRollout(t, "data planes to receive snapshots", func() bool {
+				for id, want := range tt.wantSeen {
+					if dps[id].receivedVersions() != want {
+						return false
+					}
+				}
+				return true
+			})
+
+			wantStable := "v2"
+			if tt.wantState != RolloutCompleted {
+				wantStable = "v1"
+			}
+			if stable := cpServer.StableConfig().Version; stable != wantStable {
+				t.Errorf("stable version = %s, want %s", stable, wantStable)
+			}
+
+			// Fleet status measures the stable version, so a rolled back fleet shows as converged
+			wantApplied := 4
+			for _, rejected := range tt.reject {
+				if rejected == wantStable {
+					wantApplied--
+				}
+			}
+			waitForRollout(t, "fleet status to settle on the stable version", func() bool {
+				status := cpServer.FleetStatus()
+				return status.Version == wantStable && status.Applied == wantApplied && status.Pending == 0
+			})
+		})
+	}
+}
+
+func TestServer_WaveFailureRatioCountsConnectedTargets(t *testing.T) {
+	cpServer := &Server{dataPlanes: map[string]*dataPlane{
+		"dp-1": {id: "dp-1", connected: true, lastNackVersion: "v2"},
+		"dp-2": {id: "dp-2", connected: false},
+	}}
+	targets := map[string]bool{"dp-1": true, "dp-2": true}
+
+	failed, pending, connected := cpServer.waveProgress("v2", targets)
+	if len(failed) != 1 || len(pending) != 0 || connected != 1 {
+		t.Fatalf("waveProgress = failed %v, pending %v, connected %d; want dp-1 failed of 1 connected", failed, pending, connected)
+	}
+
+	// The disconnected target must not dilute the nack: 1 of 1 connected failed, above a 0.5 threshold
+	if ratio := failureRatio(failed, connected); ratio != 1 {
+		t.Errorf("failureRatio = %.2f, want 1", ratio)
+	}
+}
+
+func TestServer_RolloutRollsBackSupersededRollout(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+
+	cpServer := NewServer(loader)
+	cpServer.SetRolloutStrategy(&RolloutStrategy{Waves: []float64{0.2, 1}, BakeTime: time.Minute, AckTimeout: time.Second})
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		cpServer.HandleConnection(conn)
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	dps := make(map[string]*fakeDataPlane)
+	for _, id := range []string{"dp-1", "dp-2", "dp-3", "dp-4"} {
+		dps[id] = dialFakeDataPlane(t, url, id)
+		defer dps[id].conn.Close()
+	}
+	waitForRollout(t, "fleet converged on v1", func() bool { return cpServer.FleetStatus().Applied == 4 })
+
+	publish := func(placement string) {
+		t.Helper()
+		if _, err := loader.Update(loader.GetConfigVersion(), func(cfg *config.Config) error {
+			cfg.RoutingTable["acme"] = placement
+			return nil
+		}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		cpServer.PublishConfig()
+	}
+
+	// v2's canary is dp-1; dp-0 joins before v3, so v3's canary is dp-0 alone
+	publish("tier2")
+	waitForRollout(t, "canary to receive v2", func() bool { return dps["dp-1"].receivedVersions() == "v1,v2" })
+	dps["dp-0"] = dialFakeDataPlane(t, url, "dp-0", "v3")
+	defer dps["dp-0"].conn.Close()
+	waitForRollout(t, "dp-0 to receive v1", func() bool { return dps["dp-0"].receivedVersions() == "v1" })
+
+	publish("tier1")
+	waitForRollout(t, "v3 to roll back", func() bool {
+		status, _ := cpServer.RolloutStatus()
+		return status.Version == "v3" && status.State == RolloutRolledBack
+	})
+	waitForRollout(t, "dp-1 to return to v1", func() bool { return dps["dp-1"].receivedVersions() == "v1,v2,v1" })
+	if seen := dps["dp-2"].receivedVersions(); seen != "v1" {
+		t.Errorf("dp-2 received %s, want v1", seen)
+	}
+}
+
+func waitForRollout(t *testing.T, desc string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", desc)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,85 +1,323 @@
 package controlplane
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/admin"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
 )
 
 // Server manages WebSocket connections to data plane instances
 type Server struct {
-	clients      map[*websocket.Conn]bool
-	clientsMutex sync.RWMutex
+	clients      map[*websocket.Conn]*dataPlane
+	clientsMutex sync.RWMutex // Never held while writing to a connection
 	configLoader *config.Loader
+
+	overrides      map[string]protocol.OverrideMessage // Active overrides keyed by placement and kind
+	overridesMutex sync.Mutex
+
+	broadcastVersion string // Last config version published to data planes
+	broadcastMutex   sync.Mutex
+
+	stableConfig    *config.Config   // Config new data planes receive; the previous one during a rollout
+	rolloutStrategy *RolloutStrategy // Nil to push new versions to every data plane at once
+	rollout         *rollout         // Latest staged rollout, nil if none started
+	rolloutMutex    sync.Mutex
+
+	dataPlanes map[string]*dataPlane // Rollout state keyed by data plane ID, kept after disconnect
+	fleetMutex sync.Mutex
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration // Data planes not heard from for this long are disconnected
+
+	authenticator *Authenticator   // Nil accepts any data plane on /connect
+	revocations   *RevocationStore // Data plane IDs refused on connect
+
+	signingKey ed25519.PrivateKey // Signs snapshots and deltas if set
+
+	elector      *Elector     // Nil for a single control plane, which always leads
+	releases     ReleaseStore // Where the leader publishes its release to followers; set with elector
+	releaseMutex sync.Mutex   // Serializes release saves
 }
 
 // NewServer creates a new control plane server
 func NewServer(configLoader *config.Loader) *Server {
 	return &Server{
-		clients:      make(map[*websocket.Conn]bool),
+		clients:      make(map[*websocket.Conn]*dataPlane),
 		configLoader: configLoader,
+		overrides:    make(map[string]protocol.OverrideMessage),
+
+		broadcastVersion: configLoader.GetConfig().Version,
+		stableConfig:     configLoader.GetConfig(),
+		dataPlanes:       make(map[string]*dataPlane),
+		revocations:      &RevocationStore{revoked: make(map[string]bool)},
+
+		pingInterval: protocol.DefaultPingInterval,
+		pongTimeout:  protocol.DefaultPongTimeout,
 	}
 }
 
-// RegisterClient adds a new data plane connection
+// SetSigningKey signs every config sent to data planes so they can verify it came from the control plane
+// Must be called before connections are handled
+func (s *Server) SetSigningKey(key ed25519.PrivateKey) {
+	s.signingKey = key
+}
+
+// sign returns the config's signature, or an empty one if no signing key is set
+func (s *Server) sign(cfg *config.Config) (string, error) {
+	if s.signingKey == nil {
+		return "", nil
+	}
+	return config.SignConfig(s.signingKey, cfg)
+}
+
+// SetHeartbeat sets how often data planes are pinged and how long one may stay silent before it is dropped
+// Must be called before connections are handled
+func (s *Server) SetHeartbeat(pingInterval, pongTimeout time.Duration) {
+	s.pingInterval = pingInterval
+	s.pongTimeout = pongTimeout
+}
+
+// RegisterClient adds a new unauthenticated data plane connection
+// The data plane is tracked by its remote address until it identifies itself with hello
 func (s *Server) RegisterClient(conn *websocket.Conn) {
+	s.registerClient(conn, Identity{}, nil)
+}
+
+// registerClient adds a new data plane connection, identified up front if it authenticated
+// labels select the view of the config it receives
+func (s *Server) registerClient(conn *websocket.Conn, identity Identity, labels map[string]string) {
+	now := time.Now()
+	remoteAddr := conn.RemoteAddr().String()
+	dp := &dataPlane{
+		id:          remoteAddr,
+		remoteAddr:  remoteAddr,
+		authMethod:  identity.Method,
+		labels:      labels,
+		connected:   true,
+		connectedAt: now,
+		lastSeen:    now,
+	}
+	if identity.ID != "" {
+		dp.id = identity.ID
+		dp.identified = true
+	}
+
 	s.clientsMutex.Lock()
-	s.clients[conn] = true
+	s.clients[conn] = dp
+	s.fleetMutex.Lock()
+	s.dataPlanes[dp.id] = dp
+	s.fleetMutex.Unlock()
+	total := len(s.clients)
 	s.clientsMutex.Unlock()
-	log.Printf("Data plane connected (total clients: %d)", len(s.clients))
+	log.Printf("Data plane connected (total clients: %d)", total)
 }
 
 // UnregisterClient removes a disconnected data plane
+// Identified data planes stay in the fleet view as disconnected
 func (s *Server) UnregisterClient(conn *websocket.Conn) {
 	s.clientsMutex.Lock()
+	dp := s.clients[conn]
 	delete(s.clients, conn)
+	if dp != nil {
+		s.fleetMutex.Lock()
+		dp.connected = false
+		if !dp.identified && s.dataPlanes[dp.id] == dp {
+			delete(s.dataPlanes, dp.id)
+		}
+		s.fleetMutex.Unlock()
+	}
+	total := len(s.clients)
 	s.clientsMutex.Unlock()
 	conn.Close()
-	log.Printf("Data plane disconnected (total clients: %d)", len(s.clients))
+	log.Printf("Data plane disconnected (total clients: %d)", total)
+}
+
+// identifyClient records the identity a data plane sent in its hello message
+func (s *Server) identifyClient(conn *websocket.Conn, hello protocol.HelloMessage) {
+	if hello.ID == "" {
+		log.Printf("Ignoring hello without data plane ID")
+		return
+	}
+
+	s.clientsMutex.RLock()
+	dp := s.clients[conn]
+	s.clientsMutex.RUnlock()
+	if dp == nil {
+		return
+	}
+
+	s.fleetMutex.Lock()
+	if dp.authMethod != "" && hello.ID != dp.id {
+		s.fleetMutex.Unlock()
+		log.Printf("Data plane authenticated as %s (%s) claimed ID %s in hello; disconnecting", dp.id, dp.authMethod, hello.ID)
+		conn.Close()
+		return
+	}
+	if s.dataPlanes[dp.id] == dp {
+		delete(s.dataPlanes, dp.id)
+	}
+	if existing, exists := s.dataPlanes[hello.ID]; exists && existing.isConnected(time.Now()) {
+		log.Printf("Data plane ID %s is already connected from %s; tracking the new connection from %s", hello.ID, existing.remoteAddr, dp.remoteAddr)
+	}
+	dp.id = hello.ID
+	dp.identified = true
+	dp.hostname = hello.Hostname
+	dp.build = hello.Build
+	s.dataPlanes[dp.id] = dp
+	s.fleetMutex.Unlock()
+
+	log.Printf("Data plane %s identified (hostname: %s, build: %s)", hello.ID, hello.Hostname, hello.Build)
+}
+
+// recordClientMessage updates a data plane's rollout state from a received message
+func (s *Server) recordClientMessage(conn *websocket.Conn, update func(dp *dataPlane)) {
+	s.clientsMutex.RLock()
+	dp := s.clients[conn]
+	s.clientsMutex.RUnlock()
+	if dp == nil {
+		return
+	}
+
+	s.fleetMutex.Lock()
+	dp.lastSeen = time.Now()
+	update(dp)
+	s.fleetMutex.Unlock()
 }
 
 // BroadcastConfig sends current config to all connected data planes
 func (s *Server) BroadcastConfig() {
 	cfg := s.configLoader.GetConfig()
 
-	msg := protocol.ConfigSnapshotMessage{
+	s.broadcastMutex.Lock()
+	s.broadcastVersion = cfg.Version
+	s.broadcastMutex.Unlock()
+	s.setStableConfig(cfg)
+	s.saveRelease()
+
+	s.sendConfig(cfg, func(dp *dataPlane) bool { return true })
+}
+
+// sendConfig sends a config to the connected data planes selected by include, each getting its view
+// Data planes that acked the last config sent to them get a delta from it, others a full snapshot;
+// polling data planes are offered it on their next poll
+// Returns the IDs of the data planes it was sent to
+func (s *Server) sendConfig(cfg *config.Config, include func(dp *dataPlane) bool) []string {
+	views := s.newViewCache(cfg)
+
+	// Each data plane is written to concurrently, so one stalled connection delays no other
+	var (
+		sent   []string
+		sentMu sync.Mutex
+		wg     sync.WaitGroup
+	)
+	for conn, dp := range s.connectedClients() {
+		s.fleetMutex.Lock()
+		id, labels, selected := dp.id, dp.labels, include(dp)
+		var base *config.Config
+		if dp.sentConfig != nil && dp.appliedVersion == dp.sentConfig.Version {
+			base = dp.sentConfig
+		}
+		s.fleetMutex.Unlock()
+		if !selected {
+			continue
+		}
+
+		view := views.get(labels)
+		data, kind := view.encode(s), "snapshot"
+		if data == nil {
+			continue
+		}
+		if base != nil && base.Version != cfg.Version {
+			if delta := view.delta(base); delta != nil {
+				data, kind = delta, "delta from "+base.Version
+			}
+		}
+
+		wg.Add(1)
+		go func(conn *websocket.Conn, dp *dataPlane) {
+			defer wg.Done()
+			if err := s.writeMessage(conn, dp, data); err != nil {
+				log.Printf("Failed to send config to data plane %s: %v", id, err)
+				return
+			}
+			s.fleetMutex.Lock()
+			dp.sentConfig, dp.view = view.cfg, view.name
+			s.fleetMutex.Unlock()
+			log.Printf("Pushed config version %s (%s%s) to data plane %s", cfg.Version, kind, viewSuffix(view.name), id)
+
+			sentMu.Lock()
+			sent = append(sent, id)
+			sentMu.Unlock()
+		}(conn, dp)
+	}
+	wg.Wait()
+	return append(sent, s.offerConfig(views, include)...)
+}
+
+// viewSuffix describes the view a config was sent as in log messages
+func viewSuffix(view string) string {
+	if view == "" {
+		return ""
+	}
+	return ", view " + view
+}
+
+// snapshotMessage encodes a config and its signature as a snapshot message
+func snapshotMessage(cfg *config.Config, signature string) ([]byte, error) {
+	return json.Marshal(protocol.ConfigSnapshotMessage{
 		Type:             protocol.MessageTypeConfigSnapshot,
 		Version:          cfg.Version,
 		RoutingTable:     cfg.RoutingTable,
 		CellEndpoints:    cfg.CellEndpoints,
+		Placements:       cfg.Placements,
 		DefaultPlacement: cfg.DefaultPlacement,
-	}
+		RateLimitMaxKeys: cfg.RateLimitMaxKeys,
+		Signature:        signature,
+	})
+}
 
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Failed to marshal config: %v", err)
-		return
+// deltaMessage encodes the delta from base to cfg, or returns nil if a snapshot should be sent instead
+func deltaMessage(base, cfg *config.Config, signature string) []byte {
+	delta, ok := protocol.NewConfigDelta(base, cfg)
+	if !ok {
+		return nil
 	}
-
-	s.clientsMutex.RLock()
-	defer s.clientsMutex.RUnlock()
-
-	for conn := range s.clients {
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			log.Printf("Failed to send config to client: %v", err)
-		} else {
-			log.Printf("Pushed config version %s to data plane", cfg.Version)
-		}
+	delta.Signature = signature
+	data, err := json.Marshal(delta)
+	if err != nil {
+		log.Printf("Failed to marshal config delta: %v", err)
+		return nil
 	}
+	return data
 }
 
-// HandleConnection manages a WebSocket connection from a data plane
+// HandleConnection manages a WebSocket connection from an unauthenticated data plane
 func (s *Server) HandleConnection(conn *websocket.Conn) {
-	s.RegisterClient(conn)
+	s.handleConnection(conn, Identity{}, nil)
+}
+
+// handleConnection manages a WebSocket connection from a data plane
+func (s *Server) handleConnection(conn *websocket.Conn, identity Identity, labels map[string]string) {
+	s.registerClient(conn, identity, labels)
 	defer s.UnregisterClient(conn)
 
-	// Send initial config snapshot
+	// Drop data planes that stop answering pings (e.g. half-open connections)
+	heartbeat := protocol.StartHeartbeat(conn, s.pingInterval, s.pongTimeout, func() {
+		s.recordClientMessage(conn, func(dp *dataPlane) {})
+	})
+	defer heartbeat.Stop()
+
+	// Send initial config snapshot and any active overrides
 	s.sendConfigToClient(conn)
+	s.sendOverridesToClient(conn)
 
 	// Read acknowledgments from data plane
 	for {
@@ -90,40 +328,181 @@ func (s *Server) HandleConnection(conn *websocket.Conn) {
 			}
 			break
 		}
+		heartbeat.Touch()
 
 		if messageType == websocket.TextMessage {
-			s.handleDataPlaneMessage(data)
+			s.handleDataPlaneMessage(conn, data)
 		}
 	}
 }
 
-// sendConfigToClient sends current config to a specific client
+// sendConfigToClient sends the stable config to a specific client
+// During a staged rollout new data planes get the previous version until a wave includes them
 func (s *Server) sendConfigToClient(conn *websocket.Conn) {
-	cfg := s.configLoader.GetConfig()
+	s.sendSnapshotToClient(conn, s.StableConfig())
+}
 
-	msg := protocol.ConfigSnapshotMessage{
-		Type:             protocol.MessageTypeConfigSnapshot,
-		Version:          cfg.Version,
-		RoutingTable:     cfg.RoutingTable,
-		CellEndpoints:    cfg.CellEndpoints,
-		DefaultPlacement: cfg.DefaultPlacement,
+// sendSnapshotToClient sends a full config snapshot of a client's view to a specific client
+func (s *Server) sendSnapshotToClient(conn *websocket.Conn, cfg *config.Config) {
+	s.clientsMutex.RLock()
+	dp := s.clients[conn]
+	s.clientsMutex.RUnlock()
+	if dp == nil {
+		return
+	}
+
+	s.fleetMutex.Lock()
+	labels := dp.labels
+	s.fleetMutex.Unlock()
+
+	view := s.newViewCache(cfg).get(labels)
+	data := view.encode(s)
+	if data == nil {
+		return
 	}
 
-	data, err := json.Marshal(msg)
+	if err := s.writeMessage(conn, dp, data); err != nil {
+		log.Printf("Failed to send config snapshot: %v", err)
+		return
+	}
+	s.fleetMutex.Lock()
+	dp.sentConfig, dp.view = view.cfg, view.name
+	s.fleetMutex.Unlock()
+	log.Printf("Sent config snapshot version %s%s to data plane", cfg.Version, viewSuffix(view.name))
+}
+
+// resyncClient resends the config last sent to a data plane as a full snapshot
+func (s *Server) resyncClient(conn *websocket.Conn) {
+	s.clientsMutex.RLock()
+	dp := s.clients[conn]
+	s.clientsMutex.RUnlock()
+	if dp == nil {
+		return
+	}
+
+	s.fleetMutex.Lock()
+	cfg := dp.sentConfig
+	s.fleetMutex.Unlock()
+	if cfg == nil {
+		cfg = s.StableConfig()
+	}
+	s.sendSnapshotToClient(conn, cfg)
+}
+
+// ApplyOverride records an operator override and fans it out to all connected data planes
+func (s *Server) ApplyOverride(override protocol.OverrideMessage) error {
+	if _, exists := s.configLoader.GetConfig().GetCellEndpoints()[override.Placement]; !exists {
+		return fmt.Errorf("%w: %s", admin.ErrUnknownPlacement, override.Placement)
+	}
+
+	key, active := overrideKey(override)
+	s.overridesMutex.Lock()
+	if active {
+		s.overrides[key] = override
+	} else {
+		delete(s.overrides, key)
+	}
+	s.overridesMutex.Unlock()
+
+	data, err := json.Marshal(override)
 	if err != nil {
-		log.Printf("Failed to marshal config: %v", err)
+		return fmt.Errorf("failed to marshal override: %w", err)
+	}
+
+	clients := s.connectedClients()
+	var wg sync.WaitGroup
+	for conn, dp := range clients {
+		wg.Add(1)
+		go func(conn *websocket.Conn, dp *dataPlane) {
+			defer wg.Done()
+			if err := s.writeMessage(conn, dp, data); err != nil {
+				log.Printf("Failed to send override to client: %v", err)
+			}
+		}(conn, dp)
+	}
+	wg.Wait()
+	log.Printf("Pushed override %s for placement %s to %d data planes", override.Action, override.Placement, len(clients))
+	return nil
+}
+
+// sendOverridesToClient replays unexpired overrides to a newly connected client
+func (s *Server) sendOverridesToClient(conn *websocket.Conn) {
+	now := time.Now()
+
+	s.overridesMutex.Lock()
+	var active []protocol.OverrideMessage
+	for key, override := range s.overrides {
+		if now.Before(override.ExpiresAt) {
+			active = append(active, override)
+		} else {
+			delete(s.overrides, key)
+		}
+	}
+	s.overridesMutex.Unlock()
+
+	s.clientsMutex.RLock()
+	dp := s.clients[conn]
+	s.clientsMutex.RUnlock()
+	if dp == nil {
 		return
 	}
 
+	for _, override := range active {
+		data, err := json.Marshal(override)
+		if err != nil {
+			log.Printf("Failed to marshal override: %v", err)
+			continue
+		}
+		if err := s.writeMessage(conn, dp, data); err != nil {
+			log.Printf("Failed to send override: %v", err)
+			return
+		}
+	}
+}
+
+// overrideKey returns the key an override is stored under and whether it sets (rather than clears) state
+func overrideKey(override protocol.OverrideMessage) (string, bool) {
+	switch override.Action {
+	case protocol.OverrideDrain:
+		return override.Placement + "/drain", true
+	case protocol.OverrideUndrain:
+		return override.Placement + "/drain", false
+	case protocol.OverrideClearCircuit:
+		return override.Placement + "/circuit", false
+	default:
+		return override.Placement + "/circuit", true
+	}
+}
+
+// connectedClients returns a copy of the WebSocket connections and their data planes,
+// so messages are written without holding clientsMutex
+func (s *Server) connectedClients() map[*websocket.Conn]*dataPlane {
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+
+	clients := make(map[*websocket.Conn]*dataPlane, len(s.clients))
+	for conn, dp := range s.clients {
+		clients[conn] = dp
+	}
+	return clients
+}
+
+// writeMessage sends a text message to a data plane connection, giving up after the heartbeat timeout
+// A failed write closes the connection, so a half-open data plane is dropped instead of retried
+func (s *Server) writeMessage(conn *websocket.Conn, dp *dataPlane, data []byte) error {
+	dp.writeMutex.Lock()
+	defer dp.writeMutex.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(s.pongTimeout))
 	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		log.Printf("Failed to send initial config: %v", err)
-	} else {
-		log.Printf("Sent initial config version %s to new data plane", cfg.Version)
+		conn.Close()
+		return err
 	}
+	return nil
 }
 
-// handleDataPlaneMessage processes ack/nack messages from data plane
-func (s *Server) handleDataPlaneMessage(data []byte) {
+// handleDataPlaneMessage processes hello, ack/nack and resync messages from data plane
+func (s *Server) handleDataPlaneMessage(conn *websocket.Conn, data []byte) {
 	var baseMsg protocol.Message
 	if err := json.Unmarshal(data, &baseMsg); err != nil {
 		log.Printf("Failed to parse data plane message: %v", err)
@@ -131,31 +510,78 @@ func (s *Server) handleDataPlaneMessage(data []byte) {
 	}
 
 	switch baseMsg.Type {
+	case protocol.MessageTypeHello:
+		var helloMsg protocol.HelloMessage
+		if err := json.Unmarshal(data, &helloMsg); err == nil {
+			s.identifyClient(conn, helloMsg)
+		}
 	case protocol.MessageTypeAck:
 		var ackMsg protocol.AckMessage
 		if err := json.Unmarshal(data, &ackMsg); err == nil {
-			log.Printf("Data plane acknowledged config version %s", ackMsg.Version)
+			s.recordClientMessage(conn, recordAck(ackMsg))
+		}
+	case protocol.MessageTypeResyncRequest:
+		var resyncMsg protocol.ResyncRequestMessage
+		if err := json.Unmarshal(data, &resyncMsg); err == nil {
+			s.recordClientMessage(conn, func(dp *dataPlane) {
+				log.Printf("Data plane %s requested a full snapshot (applied version %s): %s", dp.id, resyncMsg.Version, resyncMsg.Reason)
+			})
+			s.resyncClient(conn)
 		}
 	case protocol.MessageTypeNack:
 		var nackMsg protocol.NackMessage
 		if err := json.Unmarshal(data, &nackMsg); err == nil {
-			log.Printf("Data plane rejected config version %s: %s", nackMsg.Version, nackMsg.Error)
+			s.recordClientMessage(conn, recordNack(nackMsg))
 		}
 	}
 }
 
-// WatchConfigChanges monitors config file and broadcasts updates
+// recordAck returns an update recording that a data plane applied a config version
+func recordAck(ackMsg protocol.AckMessage) func(dp *dataPlane) {
+	return func(dp *dataPlane) {
+		dp.appliedVersion = ackMsg.Version
+		dp.lastAckAt = dp.lastSeen
+		log.Printf("Data plane %s acknowledged config version %s", dp.id, ackMsg.Version)
+	}
+}
+
+// recordNack returns an update recording that a data plane rejected a config version
+func recordNack(nackMsg protocol.NackMessage) func(dp *dataPlane) {
+	return func(dp *dataPlane) {
+		dp.lastNackVersion = nackMsg.Version
+		dp.lastNackReason = nackMsg.Reason
+		dp.lastError = nackMsg.Error
+		log.Printf("Data plane %s rejected config version %s (%s): %s", dp.id, nackMsg.Version, nackMsg.Reason, nackMsg.Error)
+	}
+}
+
+// WatchConfigChanges monitors config file and publishes updates
+// Versions already published (e.g. by the REST API) are not pushed again; followers poll the leader's release instead
 func (s *Server) WatchConfigChanges() {
-	lastVersion := s.configLoader.GetConfig().Version
 	ticker := time.NewTicker(2 * time.Second)
 	defer ticker.Stop()
 
+	if !s.IsLeader() {
+		s.followRelease()
+	}
 	for range ticker.C {
+		// Revocations made on other replicas sharing the store
+		s.disconnectRevoked()
+
+		if !s.IsLeader() {
+			s.followRelease()
+			continue
+		}
+
 		currentVersion := s.configLoader.GetConfig().Version
+
+		s.broadcastMutex.Lock()
+		lastVersion := s.broadcastVersion
+		s.broadcastMutex.Unlock()
+
 		if currentVersion != lastVersion {
-			log.Printf("Config changed from %s to %s, broadcasting to data planes", lastVersion, currentVersion)
-			s.BroadcastConfig()
-			lastVersion = currentVersion
+			log.Printf("Config changed from %s to %s, publishing to data planes", lastVersion, currentVersion)
+			s.PublishConfig()
 		}
 	}
 }
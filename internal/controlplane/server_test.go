@@ -0,0 +1,151 @@
+package controlplane
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+func TestServer_SendsDeltasOnceAcked(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	cpServer := NewServer(loader)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		cpServer.HandleConnection(conn)
+	}))
+	defer server.Close()
+
+	// dp-2 rejects v2, so v3 must reach it as a snapshot
+	dp1 := dialFakeDataPlane(t, "ws"+strings.TrimPrefix(server.URL, "http"), "dp-1")
+	defer dp1.conn.Close()
+	dp2 := dialFakeDataPlane(t, "ws"+strings.TrimPrefix(server.URL, "http"), "dp-2", "v2")
+	defer dp2.conn.Close()
+	waitForRollout(t, "fleet converged on v1", func() bool { return cpServer.FleetStatus().Applied == 2 })
+
+	publish := func(placement string) {
+		t.Helper()
+		if _, err := loader.Update(loader.GetConfigVersion(), func(cfg *config.Config) error {
+			cfg.RoutingTable["acme"] = placement
+			return nil
+		}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		cpServer.PublishConfig()
+	}
+
+	publish("tier2")
+	waitForRollout(t, "v2 acked or rejected", func() bool {
+		status := cpServer.FleetStatus()
+		return status.Applied == 1 && status.Rejected == 1
+	})
+	publish("tier1")
+	waitForRollout(t, "fleet converged on v3", func() bool { return cpServer.FleetStatus().Applied == 2 })
+
+	delta, snapshot := protocol.MessageTypeConfigDelta, protocol.MessageTypeConfigSnapshot
+	if kinds := dp1.receivedKinds(); !reflect.DeepEqual(kinds, []protocol.MessageType{snapshot, delta, delta}) {
+		t.Errorf("dp-1 received %v, want a snapshot then deltas", kinds)
+	}
+	if kinds := dp2.receivedKinds(); !reflect.DeepEqual(kinds, []protocol.MessageType{snapshot, delta, snapshot}) {
+		t.Errorf("dp-2 received %v, want a snapshot after rejecting a delta", kinds)
+	}
+
+	// A resync request is answered with a snapshot of the last config sent
+	if err := dp1.send(protocol.ResyncRequestMessage{Type: protocol.MessageTypeResyncRequest, Version: "v0"}); err != nil {
+		t.Fatalf("resync request failed: %v", err)
+	}
+	waitForRollout(t, "resync snapshot", func() bool { return len(dp1.receivedKinds()) == 4 })
+	if kinds := dp1.receivedKinds(); kinds[3] != snapshot || dp1.receivedVersions() != "v1,v2,v3,v3" {
+		t.Errorf("dp-1 after resync received %v (%s), want a v3 snapshot", kinds, dp1.receivedVersions())
+	}
+}
+
+func TestServer_StuckWriteDoesNotBlockOtherDataPlanes(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://cell-tier1:9001", "tier2": "http://cell-tier2:9002"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	cpServer := NewServer(loader)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		cpServer.HandleConnection(conn)
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	dp1 := dialFakeDataPlane(t, url, "dp-1")
+	defer dp1.conn.Close()
+	dp2 := dialFakeDataPlane(t, url, "dp-2")
+	defer dp2.conn.Close()
+	waitForRollout(t, "fleet converged on v1", func() bool { return cpServer.FleetStatus().Applied == 2 })
+
+	// Hold dp-1's writer as a write to a half-open connection would until its deadline
+	cpServer.fleetMutex.Lock()
+	stuck := cpServer.dataPlanes["dp-1"]
+	cpServer.fleetMutex.Unlock()
+	stuck.writeMutex.Lock()
+
+	if _, err := loader.Update("v1", func(cfg *config.Config) error {
+		cfg.RoutingTable["acme"] = "tier2"
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	published := make(chan struct{})
+	go func() {
+		cpServer.PublishConfig()
+		close(published)
+	}()
+
+	waitForRollout(t, "dp-2 to receive v2", func() bool { return dp2.receivedVersions() == "v1,v2" })
+	dp2.conn.Close()
+	dp3 := dialFakeDataPlane(t, url, "dp-3")
+	defer dp3.conn.Close()
+	waitForRollout(t, "dp-3 to connect while dp-2 disconnects", func() bool {
+		return dp3.receivedVersions() == "v2" && cpServer.FleetStatus().Connected == 2
+	})
+
+	stuck.writeMutex.Unlock()
+	<-published
+	waitForRollout(t, "dp-1 to receive v2", func() bool { return dp1.receivedVersions() == "v1,v2" })
+}
@@ -0,0 +1,61 @@
+package controlplane
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// sharedFile is a file on storage the replicas may share, reread only when another writer changed it
+type sharedFile struct {
+	path    string
+	modTime time.Time // Modification time and size of the file when last read or written
+	size    int64
+}
+
+// lock takes the exclusive lock guarding read-modify-write cycles on the file
+func (f *sharedFile) lock() (func(), error) {
+	return lockFile(f.path + ".lock")
+}
+
+// reload passes the file's contents to parse if it changed since it was last read, or nil if it does not exist
+func (f *sharedFile) reload(parse func(data []byte) error) error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		f.modTime, f.size = time.Time{}, 0
+		return parse(nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+	if err := parse(data); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.path, err)
+	}
+	f.modTime, f.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// write replaces the file atomically with data
+func (f *sharedFile) write(data []byte, perm os.FileMode) error {
+	tmp := fmt.Sprintf("%s.tmp-%d", f.path, os.Getpid())
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	if info, err := os.Stat(f.path); err == nil {
+		f.modTime, f.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package controlplane
+
+import (
+	"log"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+// dataPlaneView is the part of a config sent to data planes matching one view, encoded on first use
+type dataPlaneView struct {
+	name string // Empty for data planes no view matches
+	cfg  *config.Config
+
+	encoded   bool
+	snapshot  []byte            // Nil if signing or encoding failed
+	signature string            // Signature of cfg
+	deltas    map[string][]byte // Encoded deltas by base version; nil entries mean send a snapshot
+}
+
+// viewCache computes each view of a config once while it is sent to many data planes
+// Not safe for concurrent use
+type viewCache struct {
+	server *Server
+	cfg    *config.Config
+	views  map[string]*dataPlaneView
+}
+
+// newViewCache creates a cache of the views of cfg
+func (s *Server) newViewCache(cfg *config.Config) *viewCache {
+	return &viewCache{
+		server: s,
+		cfg:    cfg,
+		views:  make(map[string]*dataPlaneView),
+	}
+}
+
+// get returns the view for a data plane's labels
+func (c *viewCache) get(labels map[string]string) *dataPlaneView {
+	name := c.cfg.MatchView(labels)
+	if view, exists := c.views[name]; exists {
+		return view
+	}
+
+	cfg, _, err := c.cfg.ForDataPlane(labels)
+	if err != nil {
+		// Cannot happen for a config that was loaded from JSON; fall back to the whole config
+		log.Printf("Failed to compute view %q of config version %s: %v", name, c.cfg.Version, err)
+		cfg = c.cfg
+	}
+	view := &dataPlaneView{name: name, cfg: cfg, deltas: make(map[string][]byte)}
+	c.views[name] = view
+	return view
+}
+
+// encode signs the view and encodes it as a snapshot message, returning nil on failure
+func (v *dataPlaneView) encode(s *Server) []byte {
+	if v.encoded {
+		return v.snapshot
+	}
+	v.encoded = true
+
+	signature, err := s.sign(v.cfg)
+	if err != nil {
+		log.Printf("Failed to sign config: %v", err)
+		return nil
+	}
+	snapshot, err := snapshotMessage(v.cfg, signature)
+	if err != nil {
+		log.Printf("Failed to marshal config: %v", err)
+		return nil
+	}
+	v.signature, v.snapshot = signature, snapshot
+	return snapshot
+}
+
+// delta returns the encoded delta from base to the view, or nil if a snapshot should be sent instead
+// Must be called after encode succeeded
+func (v *dataPlaneView) delta(base *config.Config) []byte {
+	delta, exists := v.deltas[base.Version]
+	if !exists {
+		delta = deltaMessage(base, v.cfg, v.signature)
+		v.deltas[base.Version] = delta
+	}
+	return delta
+}
@@ -0,0 +1,111 @@
+package controlplane
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/dataplane"
+)
+
+func TestServer_SendsViewsByLabels(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	cfgJSON := `{
+		"version": "v1",
+		"routingTable": {"acme": "eu-tier1", "globex": "us-tier1"},
+		"placements": {
+			"eu-tier1": {"url": "http://eu-tier1:9001", "labels": {"region": "eu"}},
+			"us-tier1": {"url": "http://us-tier1:9002", "labels": {"region": "us"}}
+		},
+		"defaultPlacement": "us-tier1",
+		"views": {
+			"eu": {"selector": {"region": "eu"}, "default_placement": "eu-tier1"},
+			"us": {"selector": {"region": "us"}}
+		}
+	}`
+	if err := os.WriteFile(tmpFile, []byte(cfgJSON), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	cpLoader := config.NewLoader(tmpFile, time.Second)
+	if err := cpLoader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	cpServer := NewServer(cpLoader)
+	cpServer.SetSigningKey(privateKey)
+
+	mux := http.NewServeMux()
+	mux.Handle("/connect", cpServer.ConnectHandler(&websocket.Upgrader{}))
+	mux.Handle("/config", cpServer.PollHandler())
+	mux.Handle("/config/ack", cpServer.PollHandler())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// The EU router holds a WebSocket, the US router polls; both verify the signed views
+	startRouter := func(id, url, region string) *config.Loader {
+		t.Helper()
+		dpFile := t.TempDir() + "/initial.json"
+		if err := os.WriteFile(dpFile, []byte(cfgJSON), 0644); err != nil {
+			t.Fatalf("Failed to write test file: %v", err)
+		}
+		loader := config.NewLoader(dpFile, time.Second)
+		if err := loader.LoadInitial(); err != nil {
+			t.Fatalf("LoadInitial failed: %v", err)
+		}
+		client, err := dataplane.NewClient(url, loader)
+		if err != nil {
+			t.Fatalf("NewClient failed: %v", err)
+		}
+		client.SetIdentity(dataplane.Identity{ID: id, Labels: map[string]string{"region": region}})
+		client.SetVerifyKey(publicKey)
+		client.SetHeartbeat(200*time.Millisecond, time.Second)
+		client.Start()
+		t.Cleanup(client.Stop)
+		return loader
+	}
+	euLoader := startRouter("router-eu", "ws"+strings.TrimPrefix(server.URL, "http")+"/connect", "eu")
+	usLoader := startRouter("router-us", server.URL+"/config", "us")
+	waitForRollout(t, "routers to apply v1", func() bool { return cpServer.FleetStatus().Applied == 2 })
+
+	eu, us := euLoader.GetConfig(), usLoader.GetConfig()
+	if _, exists := eu.RoutingTable["globex"]; exists || eu.RoutingTable["acme"] != "eu-tier1" || eu.DefaultPlacement != "eu-tier1" {
+		t.Errorf("EU router config = %+v, want only EU tenants defaulting to eu-tier1", eu)
+	}
+	if _, exists := us.Placements["eu-tier1"]; exists || us.RoutingTable["globex"] != "us-tier1" || us.DefaultPlacement != "us-tier1" {
+		t.Errorf("US router config = %+v, want only US placements defaulting to us-tier1", us)
+	}
+	for _, dp := range cpServer.FleetStatus().DataPlanes {
+		if want := strings.TrimPrefix(dp.ID, "router-"); dp.View != want || dp.Labels["region"] != want {
+			t.Errorf("data plane %s view = %q (labels %v), want %s", dp.ID, dp.View, dp.Labels, want)
+		}
+	}
+
+	// A new EU tenant reaches only the EU router, as a delta of its view
+	if _, err := cpLoader.Update("v1", func(cfg *config.Config) error {
+		cfg.RoutingTable["initech"] = "eu-tier1"
+		return nil
+	}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	cpServer.PublishConfig()
+	waitForRollout(t, "routers to apply the new version", func() bool {
+		return cpServer.FleetStatus().Converged && euLoader.GetConfigVersion() == cpLoader.GetConfigVersion()
+	})
+
+	if euLoader.GetConfig().RoutingTable["initech"] != "eu-tier1" {
+		t.Error("EU router did not get the new EU tenant")
+	}
+	if _, exists := usLoader.GetConfig().RoutingTable["initech"]; exists {
+		t.Error("US router got the new EU tenant")
+	}
+}
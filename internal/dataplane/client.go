@@ -1,9 +1,19 @@
 package dataplane
 
 import (
+	"crypto/ed25519"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -11,26 +21,166 @@ import (
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
 )
 
+// OverrideApplier applies operator overrides pushed by the control plane.
+type OverrideApplier interface {
+	ApplyOverride(override protocol.OverrideMessage) error
+}
+
+// Identity identifies a data plane to the control plane.
+type Identity struct {
+	ID       string
+	Hostname string
+	Build    string
+	Labels   map[string]string // Select the view of the config the control plane sends, e.g. region
+}
+
+// DefaultIdentity identifies the data plane by hostname and the binary's VCS revision.
+func DefaultIdentity() Identity {
+	hostname, _ := os.Hostname()
+	return Identity{
+		ID:       hostname,
+		Hostname: hostname,
+		Build:    buildRevision(),
+	}
+}
+
+// buildRevision returns the VCS revision embedded in the binary, or "unknown".
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return info.Main.Version
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
 // Client connects to the control plane and receives config updates.
 type Client struct {
-	cpURL     string
+	cpURLs    []string
+	urlIndex  int // Control plane URL currently in use (guarded by mu)
 	loader    *config.Loader
+	identity  Identity
+	overrides OverrideApplier
+	cacheFile string
+	tlsConfig *tls.Config
+	secret    []byte
+	verifyKey ed25519.PublicKey
 	conn      *websocket.Conn
+	poll      *pollSession // Set instead of conn while polling an http(s) control plane URL
 	mu        sync.Mutex
+	writeMu   sync.Mutex // Serializes writes to conn; a connection supports one concurrent writer
 	stopCh    chan struct{}
 	done      chan struct{}
 	reconnect bool
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	startedAt    time.Time
+	lastContact  atomic.Int64 // Unix nanoseconds the control plane was last heard from
+	connected    atomic.Bool
 }
 
-// NewClient creates a new data plane WebSocket client.
-func NewClient(cpURL string, loader *config.Loader) *Client {
+// NewClient creates a new data plane client.
+// cpURL may list several control plane replicas separated by commas; the client fails over between them.
+// ws:// and wss:// URLs are connected to over WebSocket, http:// and https:// URLs are polled.
+// Returns an error if cpURL lists no control plane.
+func NewClient(cpURL string, loader *config.Loader) (*Client, error) {
+	var cpURLs []string
+	for _, url := range strings.Split(cpURL, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			cpURLs = append(cpURLs, url)
+		}
+	}
+	if len(cpURLs) == 0 {
+		return nil, fmt.Errorf("no control plane URL in %q", cpURL)
+	}
+
 	return &Client{
-		cpURL:     cpURL,
+		cpURLs:    cpURLs,
 		loader:    loader,
+		identity:  DefaultIdentity(),
 		stopCh:    make(chan struct{}),
 		done:      make(chan struct{}),
 		reconnect: true,
+
+		pingInterval: protocol.DefaultPingInterval,
+		pongTimeout:  protocol.DefaultPongTimeout,
+		startedAt:    time.Now(),
+	}, nil
+}
+
+// SetHeartbeat sets how often the control plane is pinged and how long it may stay silent
+// before the connection is dropped and re-established. Must be called before Start.
+func (c *Client) SetHeartbeat(pingInterval, pongTimeout time.Duration) {
+	c.pingInterval = pingInterval
+	c.pongTimeout = pongTimeout
+}
+
+// Connected reports whether the client has a live connection to the control plane.
+func (c *Client) Connected() bool {
+	return c.connected.Load()
+}
+
+// Staleness returns how long ago the control plane was last heard from (message, ping, pong or poll response),
+// or how long the client has run if it never was. While connected it stays below the ping interval.
+func (c *Client) Staleness() time.Duration {
+	last := c.lastContact.Load()
+	if last == 0 {
+		return time.Since(c.startedAt)
 	}
+	return time.Since(time.Unix(0, last))
+}
+
+// SetIdentity sets how the data plane identifies itself. Must be called before Start.
+func (c *Client) SetIdentity(identity Identity) {
+	c.identity = identity
+}
+
+// SetOverrideApplier sets where operator overrides from the control plane are applied.
+// Must be called before Start; overrides are ignored when unset.
+func (c *Client) SetOverrideApplier(applier OverrideApplier) {
+	c.overrides = applier
+}
+
+// SetCacheFile sets where acked configs are persisted for Loader.LoadInitialWithCache.
+// Must be called before Start; configs are not persisted when unset.
+func (c *Client) SetCacheFile(path string) {
+	c.cacheFile = path
+}
+
+// SetTLSConfig sets the TLS settings (CA pool, client certificate for mTLS) used for wss:// and https:// control plane URLs.
+// Must be called before Start.
+func (c *Client) SetTLSConfig(tlsConfig *tls.Config) {
+	c.tlsConfig = tlsConfig
+}
+
+// SetTokenSecret makes the client authenticate with a token signed for its identity's ID on every connect or poll.
+// Must be called before Start.
+func (c *Client) SetTokenSecret(secret []byte) {
+	c.secret = secret
+}
+
+// SetVerifyKey makes the client nack configs that are unsigned or not signed by the matching private key.
+// Must be called before Start.
+func (c *Client) SetVerifyKey(key ed25519.PublicKey) {
+	c.verifyKey = key
 }
 
 // Start begins connecting to the control plane.
@@ -45,6 +195,9 @@ func (c *Client) Stop() {
 	if c.conn != nil {
 		c.conn.Close()
 	}
+	if c.poll != nil {
+		c.poll.cancel()
+	}
 	c.mu.Unlock()
 
 	close(c.stopCh)
@@ -57,6 +210,7 @@ func (c *Client) connectionLoop() {
 
 	backoff := 1 * time.Second
 	maxBackoff := 60 * time.Second
+	failures := 0 // Consecutive failed connection attempts across all control planes
 
 	for {
 		select {
@@ -73,8 +227,15 @@ func (c *Client) connectionLoop() {
 			return
 		}
 
-		if err := c.connect(); err != nil {
-			log.Printf("[DP] Failed to connect to control plane: %v. Retrying in %v", err, backoff)
+		url, err := c.connect()
+		if err != nil {
+			// Try the next replica right away; back off once every replica has failed
+			failures++
+			if failures%len(c.cpURLs) != 0 {
+				log.Printf("[DP] Failed to connect to control plane at %s: %v. Trying next control plane", url, err)
+				continue
+			}
+			log.Printf("[DP] Failed to connect to control plane at %s: %v. Retrying in %v", url, err, backoff)
 			time.Sleep(backoff)
 			backoff *= 2
 			if backoff > maxBackoff {
@@ -85,44 +246,99 @@ func (c *Client) connectionLoop() {
 
 		// Connected successfully - reset backoff
 		backoff = 1 * time.Second
-		log.Printf("[DP] Connected to control plane at %s", c.cpURL)
-
-		// Handle messages until connection fails
-		c.handleMessages()
+		failures = 0
+		log.Printf("[DP] Connected to control plane at %s", url)
+		c.connected.Store(true)
+		if c.polling() {
+			// Poll until a request fails
+			c.pollConfig()
+		} else {
+			c.sendHello()
+
+			// Handle messages until connection fails or the control plane stops answering pings
+			c.handleMessages()
+		}
 
-		log.Printf("[DP] Connection to control plane lost")
+		c.connected.Store(false)
+		log.Printf("[DP] Connection to control plane lost (last heard from %v ago)", c.Staleness().Round(time.Millisecond))
 	}
 }
 
-// connect establishes WebSocket connection to the control plane.
-func (c *Client) connect() error {
+// connect establishes a WebSocket connection to the current control plane, or its first config poll
+// for an http(s) URL, returning its URL. On failure the next control plane in the list becomes current.
+func (c *Client) connect() (string, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	conn, _, err := websocket.DefaultDialer.Dial(c.cpURL, nil)
+	url := c.cpURLs[c.urlIndex]
+	c.conn, c.poll = nil, nil
+	var err error
+	if protocol.IsPollingURL(url) {
+		c.poll, err = c.openPoll(url)
+	} else {
+		c.conn, err = c.dial(url)
+	}
 	if err != nil {
-		return err
+		c.urlIndex = (c.urlIndex + 1) % len(c.cpURLs)
+		return url, err
 	}
+	return url, nil
+}
 
-	c.conn = conn
-	return nil
+// ControlPlaneURL returns the URL of the control plane in use (or tried next).
+func (c *Client) ControlPlaneURL() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cpURLs[c.urlIndex]
+}
+
+// dial opens an authenticated WebSocket connection to a control plane.
+func (c *Client) dial(url string) (*websocket.Conn, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = c.tlsConfig
+
+	header := http.Header{}
+	if len(c.identity.Labels) > 0 {
+		header.Set(protocol.HeaderDataPlaneLabels, protocol.FormatLabels(c.identity.Labels))
+	}
+	if c.secret != nil {
+		token := protocol.SignToken(c.secret, c.identity.ID, time.Now().Add(protocol.DefaultTokenTTL))
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	conn, resp, err := dialer.Dial(url, header)
+	if err != nil {
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return nil, fmt.Errorf("control plane refused data plane %s: %s", c.identity.ID, resp.Status)
+		}
+		return nil, err
+	}
+	return conn, nil
 }
 
 // handleMessages reads and processes messages from the control plane.
 func (c *Client) handleMessages() {
-	for {
-		c.mu.Lock()
-		conn := c.conn
-		c.mu.Unlock()
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
 
-		if conn == nil {
-			return
-		}
+	if conn == nil {
+		return
+	}
+
+	heartbeat := protocol.StartHeartbeat(conn, c.pingInterval, c.pongTimeout, c.recordContact)
+	defer heartbeat.Stop()
 
+	for {
 		_, msgBytes, err := conn.ReadMessage()
 		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				log.Printf("[DP] Control plane silent for %v, reconnecting", c.pongTimeout)
+			}
+			conn.Close()
 			return
 		}
+		heartbeat.Touch()
 
 		var msg protocol.Message
 		if err := json.Unmarshal(msgBytes, &msg); err != nil {
@@ -133,6 +349,10 @@ func (c *Client) handleMessages() {
 		switch msg.Type {
 		case protocol.MessageTypeConfigSnapshot:
 			c.handleConfigSnapshot(msgBytes)
+		case protocol.MessageTypeConfigDelta:
+			c.handleConfigDelta(msgBytes)
+		case protocol.MessageTypeOverride:
+			c.handleOverride(msgBytes)
 		default:
 			log.Printf("[DP] Unknown message type: %s", msg.Type)
 		}
@@ -144,7 +364,7 @@ func (c *Client) handleConfigSnapshot(msgBytes []byte) {
 	var snapshot protocol.ConfigSnapshotMessage
 	if err := json.Unmarshal(msgBytes, &snapshot); err != nil {
 		log.Printf("[DP] Failed to unmarshal config snapshot: %v", err)
-		c.sendNack(err.Error())
+		c.sendNack("", protocol.NackMalformed, err)
 		return
 	}
 
@@ -155,68 +375,212 @@ func (c *Client) handleConfigSnapshot(msgBytes []byte) {
 		Version:          snapshot.Version,
 		RoutingTable:     snapshot.RoutingTable,
 		CellEndpoints:    snapshot.CellEndpoints,
+		Placements:       snapshot.Placements,
 		DefaultPlacement: snapshot.DefaultPlacement,
+		RateLimitMaxKeys: snapshot.RateLimitMaxKeys,
+	}
+
+	if err := c.verify(cfg, snapshot.Signature); err != nil {
+		log.Printf("[DP] Rejected config: %v (keeping last-known-good config)", err)
+		c.sendNack(snapshot.Version, protocol.NackBadSignature, err)
+		return
 	}
 
 	if err := c.loader.ApplyConfig(cfg); err != nil {
-		log.Printf("[DP] Failed to apply config: %v", err)
-		c.sendNack(err.Error())
+		log.Printf("[DP] Rejected config: %v (keeping last-known-good config)", err)
+		c.sendNack(snapshot.Version, nackReason(err), err)
 		return
 	}
 
 	log.Printf("[DP] Applied config snapshot version %s from control plane", snapshot.Version)
-	c.sendAck()
+	c.persist(cfg, snapshot.Signature)
+	c.sendAck(snapshot.Version)
 }
 
-// sendAck sends an acknowledgment to the control plane.
-func (c *Client) sendAck() {
-	c.mu.Lock()
-	conn := c.conn
-	c.mu.Unlock()
+// handleConfigDelta applies a config delta on top of the active config.
+// If the active version is not the delta's base, the delta is dropped and a full snapshot requested.
+func (c *Client) handleConfigDelta(msgBytes []byte) {
+	var delta protocol.ConfigDeltaMessage
+	if err := json.Unmarshal(msgBytes, &delta); err != nil {
+		log.Printf("[DP] Failed to unmarshal config delta: %v", err)
+		c.sendResyncRequest(err.Error())
+		return
+	}
 
-	if conn == nil {
+	log.Printf("[DP] Received config delta %s -> %s", delta.BaseVersion, delta.Version)
+
+	cfg, err := protocol.ApplyConfigDelta(c.activeConfig(), delta)
+	if err != nil {
+		log.Printf("[DP] Cannot apply config delta: %v. Requesting full snapshot", err)
+		c.sendResyncRequest(err.Error())
 		return
 	}
 
-	msg := protocol.AckMessage{
-		Type:    protocol.MessageTypeAck,
-		Version: "",
+	// The signature covers the resulting config; a mismatch is settled by a (verified) full snapshot
+	if err := c.verify(cfg, delta.Signature); err != nil {
+		log.Printf("[DP] Config delta result rejected: %v. Requesting full snapshot", err)
+		c.sendResyncRequest(err.Error())
+		return
 	}
 
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("[DP] Failed to marshal ack: %v", err)
+	if err := c.loader.ApplyConfig(cfg); err != nil {
+		log.Printf("[DP] Rejected config: %v (keeping last-known-good config)", err)
+		c.sendNack(delta.Version, nackReason(err), err)
+		return
+	}
+
+	log.Printf("[DP] Applied config delta %s -> %s from control plane", delta.BaseVersion, delta.Version)
+	c.persist(cfg, delta.Signature)
+	c.sendAck(delta.Version)
+}
+
+// handleOverride applies an operator override from the control plane.
+func (c *Client) handleOverride(msgBytes []byte) {
+	var override protocol.OverrideMessage
+	if err := json.Unmarshal(msgBytes, &override); err != nil {
+		log.Printf("[DP] Failed to unmarshal override: %v", err)
 		return
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, msgBytes); err != nil {
-		log.Printf("[DP] Failed to send ack: %v", err)
+	if c.overrides == nil {
+		log.Printf("[DP] Ignoring override %s for placement %s: no override applier", override.Action, override.Placement)
+		return
 	}
+
+	if err := c.overrides.ApplyOverride(override); err != nil {
+		log.Printf("[DP] Failed to apply override %s for placement %s: %v", override.Action, override.Placement, err)
+		return
+	}
+
+	log.Printf("[DP] Applied override %s for placement %s from control plane", override.Action, override.Placement)
 }
 
-// sendNack sends a negative acknowledgment to the control plane.
-func (c *Client) sendNack(reason string) {
-	c.mu.Lock()
-	conn := c.conn
-	c.mu.Unlock()
+// verify checks a config's signature if the client has a verify key.
+func (c *Client) verify(cfg *config.Config, signature string) error {
+	if c.verifyKey == nil {
+		return nil
+	}
+	return config.VerifyConfig(c.verifyKey, cfg, signature)
+}
 
-	if conn == nil {
+// persist writes an applied config and its signature to the cache file so a restart can serve it
+// without the control plane.
+func (c *Client) persist(cfg *config.Config, signature string) {
+	if c.cacheFile == "" {
+		return
+	}
+	if err := config.SaveToFile(c.cacheFile, cfg); err != nil {
+		log.Printf("[DP] Failed to cache config version %s: %v", cfg.Version, err)
 		return
 	}
+	if signature != "" {
+		if err := config.WriteSignatureFile(c.cacheFile, signature); err != nil {
+			log.Printf("[DP] Failed to cache signature for config version %s: %v", cfg.Version, err)
+		}
+	}
+}
+
+// sendHello identifies this data plane to the control plane.
+func (c *Client) sendHello() {
+	c.send(protocol.HelloMessage{
+		Type:     protocol.MessageTypeHello,
+		ID:       c.identity.ID,
+		Hostname: c.identity.Hostname,
+		Build:    c.identity.Build,
+	}, "hello")
+}
+
+// sendAck sends an acknowledgment to the control plane.
+func (c *Client) sendAck(version string) {
+	c.send(protocol.AckMessage{
+		Type:    protocol.MessageTypeAck,
+		Version: version,
+	}, "ack")
+}
 
-	msg := protocol.NackMessage{
+// sendNack sends a negative acknowledgment to the control plane.
+func (c *Client) sendNack(version string, reason protocol.NackReason, err error) {
+	c.send(protocol.NackMessage{
 		Type:    protocol.MessageTypeNack,
-		Version: "",
-		Error:   reason,
+		Version: version,
+		Reason:  reason,
+		Error:   err.Error(),
+	}, "nack")
+}
+
+// nackReason classifies a config apply error.
+func nackReason(err error) protocol.NackReason {
+	switch {
+	case errors.Is(err, config.ErrInvalidConfig):
+		return protocol.NackInvalidConfig
+	case errors.Is(err, config.ErrPreApplyCheckFailed):
+		return protocol.NackPreApplyCheckFailed
+	default:
+		return protocol.NackApplyFailed
+	}
+}
+
+// sendResyncRequest asks the control plane for a full snapshot.
+func (c *Client) sendResyncRequest(reason string) {
+	msg := protocol.ResyncRequestMessage{
+		Type:   protocol.MessageTypeResyncRequest,
+		Reason: reason,
+	}
+	if cfg := c.activeConfig(); cfg != nil {
+		msg.Version = cfg.Version
+	}
+	c.send(msg, "resync request")
+}
+
+// recordContact notes that the control plane was heard from.
+func (c *Client) recordContact() {
+	c.lastContact.Store(time.Now().UnixNano())
+}
+
+// activeConfig returns the loader's active config, or nil if none was loaded yet.
+func (c *Client) activeConfig() *config.Config {
+	if c.loader.LastReloadTime().IsZero() {
+		return nil
+	}
+	return c.loader.GetConfig()
+}
+
+// send writes a message to the control plane connection, or posts it when polling.
+func (c *Client) send(msg interface{}, kind string) {
+	c.mu.Lock()
+	conn, poll := c.conn, c.poll
+	c.mu.Unlock()
+
+	if conn == nil && poll == nil {
+		return
 	}
 
 	msgBytes, err := json.Marshal(msg)
 	if err != nil {
-		log.Printf("[DP] Failed to marshal nack: %v", err)
+		log.Printf("[DP] Failed to marshal %s: %v", kind, err)
 		return
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, msgBytes); err != nil {
-		log.Printf("[DP] Failed to send nack: %v", err)
+	if poll != nil {
+		err = c.post(poll, msgBytes)
+	} else {
+		err = c.write(conn, msgBytes)
+	}
+	if err != nil {
+		log.Printf("[DP] Failed to send %s: %v", kind, err)
 	}
 }
+
+// write sends a text message on conn, giving up after the heartbeat timeout.
+// A failed write closes the connection so the read loop reconnects.
+func (c *Client) write(conn *websocket.Conn, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(c.pongTimeout))
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		conn.Close()
+		return err
+	}
+	return nil
+}
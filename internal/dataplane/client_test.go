@@ -1,9 +1,14 @@
 package dataplane
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -31,7 +36,10 @@ func TestClientConnectsToControlPlane(t *testing.T) {
 
 	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
 	loader := config.NewLoader("test-config.json", 5*time.Second)
-	client := NewClient(wsURL, loader)
+	client, err := NewClient(wsURL, loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
 	client.Start()
 	defer client.Stop()
 
@@ -62,6 +70,7 @@ func TestClientReceivesConfigSnapshot(t *testing.T) {
 			},
 			CellEndpoints: map[string]string{
 				"tier1": "http://localhost:9001",
+				"tier3": "http://localhost:9003",
 			},
 			DefaultPlacement: "tier3",
 		}
@@ -71,14 +80,18 @@ func TestClientReceivesConfigSnapshot(t *testing.T) {
 			return
 		}
 
-		_, msgBytes, err := conn.ReadMessage()
-		if err != nil {
-			return
-		}
+		// The client identifies itself before acking
+		for {
+			_, msgBytes, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
 
-		var ack protocol.AckMessage
-		if err := json.Unmarshal(msgBytes, &ack); err == nil && ack.Type == protocol.MessageTypeAck {
-			receivedAck <- true
+			var ack protocol.AckMessage
+			if err := json.Unmarshal(msgBytes, &ack); err == nil && ack.Type == protocol.MessageTypeAck {
+				receivedAck <- ack.Version == "1.0.0"
+				return
+			}
 		}
 	}))
 	defer server.Close()
@@ -86,16 +99,32 @@ func TestClientReceivesConfigSnapshot(t *testing.T) {
 	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
 	loader := config.NewLoader("test-config.json", 5*time.Second)
 	loader.LoadInitial()
-	client := NewClient(wsURL, loader)
+	cacheFile := t.TempDir() + "/cache.json"
+	client, err := NewClient(wsURL, loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.SetCacheFile(cacheFile)
 	client.Start()
 	defer client.Stop()
 
 	select {
-	case <-receivedAck:
-		// Success
+	case versioned := <-receivedAck:
+		if !versioned {
+			t.Error("ACK does not carry the applied version 1.0.0")
+		}
 	case <-time.After(2 * time.Second):
 		t.Fatal("Did not receive ACK from client")
 	}
+
+	// Acked configs are cached before the ack is sent
+	cached, err := config.LoadFromFile(cacheFile)
+	if err != nil {
+		t.Fatalf("LoadFromFile(cache) failed: %v", err)
+	}
+	if cached.Version != "1.0.0" || cached.RoutingTable["acme"] != "tier1" {
+		t.Errorf("cached config = %+v, want version 1.0.0", cached)
+	}
 }
 
 func TestClientReconnectsAfterDisconnection(t *testing.T) {
@@ -124,7 +153,10 @@ func TestClientReconnectsAfterDisconnection(t *testing.T) {
 
 	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
 	loader := config.NewLoader("test-config.json", 5*time.Second)
-	client := NewClient(wsURL, loader)
+	client, err := NewClient(wsURL, loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
 	client.Start()
 	defer client.Stop()
 
@@ -146,3 +178,361 @@ func TestClientReconnectsAfterDisconnection(t *testing.T) {
 		t.Fatal("Reconnection timeout")
 	}
 }
+
+func TestClientAppliesConfigDelta(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	replies := make(chan protocol.Message, 4)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteJSON(protocol.ConfigSnapshotMessage{
+			Type:             protocol.MessageTypeConfigSnapshot,
+			Version:          "1.0.0",
+			RoutingTable:     map[string]string{"acme": "tier1"},
+			CellEndpoints:    map[string]string{"tier1": "http://localhost:9001", "tier2": "http://localhost:9002"},
+			DefaultPlacement: "tier1",
+		})
+		conn.WriteJSON(protocol.ConfigDeltaMessage{
+			Type:         protocol.MessageTypeConfigDelta,
+			BaseVersion:  "1.0.0",
+			Version:      "1.0.1",
+			RoutingTable: map[string]string{"acme": "tier2"},
+		})
+		// Stale base: the client must ask for a snapshot instead of applying it
+		conn.WriteJSON(protocol.ConfigDeltaMessage{
+			Type:               protocol.MessageTypeConfigDelta,
+			BaseVersion:        "1.0.0",
+			Version:            "1.0.2",
+			RemovedRoutingKeys: []string{"acme"},
+		})
+
+		for {
+			var msg protocol.Message
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			if msg.Type != protocol.MessageTypeHello {
+				replies <- msg
+			}
+		}
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	loader := config.NewLoader("test-config.json", 5*time.Second)
+	client, err := NewClient(wsURL, loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.Start()
+	defer client.Stop()
+
+	want := []protocol.Message{
+		{Type: protocol.MessageTypeAck, Version: "1.0.0"},
+		{Type: protocol.MessageTypeAck, Version: "1.0.1"},
+		{Type: protocol.MessageTypeResyncRequest, Version: "1.0.1"},
+	}
+	for _, w := range want {
+		select {
+		case msg := <-replies:
+			if msg != w {
+				t.Fatalf("reply = %+v, want %+v", msg, w)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %+v", w)
+		}
+	}
+
+	if placement := loader.GetConfig().RoutingTable["acme"]; placement != "tier2" {
+		t.Errorf("acme routed to %s after delta, want tier2", placement)
+	}
+}
+
+func TestClientNacksInvalidConfigSnapshot(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	receivedNack := make(chan protocol.NackMessage, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// The routing table references a placement without an endpoint
+		conn.WriteJSON(protocol.ConfigSnapshotMessage{
+			Type:             protocol.MessageTypeConfigSnapshot,
+			Version:          "1.0.1",
+			RoutingTable:     map[string]string{"acme": "tier9"},
+			CellEndpoints:    map[string]string{"tier1": "http://localhost:9001"},
+			DefaultPlacement: "tier1",
+		})
+
+		for {
+			var nack protocol.NackMessage
+			if err := conn.ReadJSON(&nack); err != nil {
+				return
+			}
+			if nack.Type == protocol.MessageTypeNack {
+				receivedNack <- nack
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	tmpFile := t.TempDir() + "/config.json"
+	validConfig := `{
+		"version": "1.0.0",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://localhost:9001"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(validConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, 5*time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	client, err := NewClient(wsURL, loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.Start()
+	defer client.Stop()
+
+	select {
+	case nack := <-receivedNack:
+		if nack.Version != "1.0.1" || nack.Reason != protocol.NackInvalidConfig || nack.Error == "" {
+			t.Errorf("nack = %+v, want 1.0.1 rejected as %s", nack, protocol.NackInvalidConfig)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Did not receive NACK from client")
+	}
+
+	if version := loader.GetConfigVersion(); version != "1.0.0" {
+		t.Errorf("active version = %s, want last-known-good 1.0.0", version)
+	}
+}
+
+func TestClientAuthenticatesWithTokenOverTLS(t *testing.T) {
+	secret := []byte("shared-secret")
+	upgrader := websocket.Upgrader{}
+	authenticated := make(chan string, 1)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		id, err := protocol.VerifyToken(secret, token, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		authenticated <- id
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	wssURL := "wss" + strings.TrimPrefix(server.URL, "https")
+	loader := config.NewLoader("test-config.json", 5*time.Second)
+	client, err := NewClient(wssURL, loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.SetIdentity(Identity{ID: "router-1"})
+	client.SetTokenSecret(secret)
+	client.SetTLSConfig(&tls.Config{RootCAs: rootCAs})
+	client.Start()
+	defer client.Stop()
+
+	select {
+	case id := <-authenticated:
+		if id != "router-1" {
+			t.Errorf("Authenticated as %s, want router-1", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Client did not authenticate within timeout")
+	}
+}
+
+func TestClientVerifiesConfigSignatures(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	upgrader := websocket.Upgrader{}
+	replies := make(chan protocol.NackMessage, 2) // Acks decode with an empty reason
+
+	snapshot := func(version, signature string) protocol.ConfigSnapshotMessage {
+		return protocol.ConfigSnapshotMessage{
+			Type:             protocol.MessageTypeConfigSnapshot,
+			Version:          version,
+			RoutingTable:     map[string]string{"acme": "tier2"},
+			CellEndpoints:    map[string]string{"tier1": "http://localhost:9001", "tier2": "http://localhost:9002"},
+			DefaultPlacement: "tier1",
+			Signature:        signature,
+		}
+	}
+	signed := snapshot("1.0.2", "")
+	signature, err := config.SignConfig(priv, &config.Config{
+		Version:          signed.Version,
+		RoutingTable:     signed.RoutingTable,
+		CellEndpoints:    signed.CellEndpoints,
+		DefaultPlacement: signed.DefaultPlacement,
+	})
+	if err != nil {
+		t.Fatalf("SignConfig failed: %v", err)
+	}
+	signed.Signature = signature
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteJSON(snapshot("1.0.1", ""))
+		conn.WriteJSON(signed)
+		for {
+			var reply protocol.NackMessage
+			if err := conn.ReadJSON(&reply); err != nil {
+				return
+			}
+			if reply.Type == protocol.MessageTypeAck || reply.Type == protocol.MessageTypeNack {
+				replies <- reply
+			}
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	initialConfig := `{
+		"version": "1.0.0",
+		"routingTable": {"acme": "tier1"},
+		"cellEndpoints": {"tier1": "http://localhost:9001"},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(dir+"/config.json", []byte(initialConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(dir+"/config.json", 5*time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+
+	client, err := NewClient("ws"+strings.TrimPrefix(server.URL, "http"), loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.SetVerifyKey(pub)
+	client.SetCacheFile(dir + "/cache.json")
+	client.Start()
+	defer client.Stop()
+
+	want := []protocol.NackMessage{
+		{Type: protocol.MessageTypeNack, Version: "1.0.1", Reason: protocol.NackBadSignature},
+		{Type: protocol.MessageTypeAck, Version: "1.0.2"},
+	}
+	for _, w := range want {
+		select {
+		case reply := <-replies:
+			if reply.Type != w.Type || reply.Version != w.Version || reply.Reason != w.Reason {
+				t.Errorf("reply = %+v, want %s for %s (reason %q)", reply, w.Type, w.Version, w.Reason)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Did not receive %s for %s", w.Type, w.Version)
+		}
+	}
+
+	// The cache keeps its signature, so a verifying router boots from it (the unsigned initial file is refused)
+	restarted := config.NewLoader(dir+"/config.json", 5*time.Second)
+	restarted.SetVerifyKey(pub)
+	if err := restarted.LoadInitialWithCache(dir + "/cache.json"); err != nil {
+		t.Fatalf("LoadInitialWithCache failed: %v", err)
+	}
+	if version := restarted.GetConfigVersion(); version != "1.0.2" {
+		t.Errorf("booted version = %s, want cached 1.0.2", version)
+	}
+}
+
+func TestClientFailsOverBetweenControlPlanes(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	connected := make(chan string, 4)
+
+	newControlPlane := func(name string, quit chan struct{}) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			connected <- name
+			<-quit
+		}))
+	}
+
+	// The first replica is down from the start
+	quit := make(chan struct{})
+	down := newControlPlane("cp-down", quit)
+	down.Close()
+	primaryQuit := make(chan struct{})
+	primary := newControlPlane("cp-1", primaryQuit)
+	secondary := newControlPlane("cp-2", quit)
+	defer secondary.Close()
+	defer close(quit)
+
+	wsURL := func(server *httptest.Server) string { return "ws" + strings.TrimPrefix(server.URL, "http") }
+	loader := config.NewLoader("test-config.json", 5*time.Second)
+	client, err := NewClient(strings.Join([]string{wsURL(down), wsURL(primary), wsURL(secondary)}, ", "), loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.Start()
+	defer client.Stop()
+
+	waitFor := func(want string) {
+		t.Helper()
+		select {
+		case name := <-connected:
+			if name != want {
+				t.Fatalf("Client connected to %s, want %s", name, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Client did not connect to %s within timeout", want)
+		}
+	}
+
+	waitFor("cp-1")
+	if url := client.ControlPlaneURL(); url != wsURL(primary) {
+		t.Errorf("ControlPlaneURL() = %s, want %s", url, wsURL(primary))
+	}
+
+	// Losing the connected replica moves the client to the next one without backing off
+	close(primaryQuit)
+	primary.Close()
+	waitFor("cp-2")
+}
+
+func TestNewClientRejectsEmptyURLList(t *testing.T) {
+	for _, cpURL := range []string{"", ",", " , "} {
+		if _, err := NewClient(cpURL, nil); err == nil {
+			t.Errorf("NewClient(%q) succeeded, want an error", cpURL)
+		}
+	}
+}
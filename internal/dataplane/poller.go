@@ -0,0 +1,165 @@
+package dataplane
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// pollSession is an HTTP polling session with one control plane, the counterpart of a WebSocket connection.
+type pollSession struct {
+	url     string
+	client  *http.Client
+	ctx     context.Context
+	cancel  context.CancelFunc // Aborts the open poll on Stop
+	pending []byte             // Snapshot returned by the first poll, handled once the session starts
+	version string             // Last config version received; sent as If-None-Match
+}
+
+// openPoll starts a polling session with the first, non-blocking poll of url.
+func (c *Client) openPoll(url string) (*pollSession, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	poll := &pollSession{
+		url: url,
+		client: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: c.tlsConfig, Proxy: http.ProxyFromEnvironment},
+			Timeout:   c.pingInterval + c.pongTimeout,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	data, err := c.fetch(poll, 0)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	poll.pending = data
+	return poll, nil
+}
+
+// polling reports whether the client is polling rather than connected over WebSocket.
+func (c *Client) polling() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.poll != nil
+}
+
+// pollConfig long-polls the control plane and applies each new config until a poll fails.
+// Each poll waits up to the ping interval, so staleness behaves as on a WebSocket connection.
+func (c *Client) pollConfig() {
+	c.mu.Lock()
+	poll := c.poll
+	c.mu.Unlock()
+
+	if poll == nil {
+		return
+	}
+	defer poll.cancel()
+
+	data := poll.pending
+	for {
+		if data != nil {
+			c.handleConfigSnapshot(data)
+		}
+
+		var err error
+		if data, err = c.fetch(poll, c.pingInterval); err != nil {
+			if poll.ctx.Err() == nil {
+				log.Printf("[DP] Config poll failed: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// fetch polls for a config other than the last one received, waiting up to wait for one.
+// Returns the snapshot message, or nil if the control plane answered Not Modified.
+func (c *Client) fetch(poll *pollSession, wait time.Duration) ([]byte, error) {
+	url := poll.url
+	if wait > 0 {
+		url += "?wait=" + neturl.QueryEscape(wait.String())
+	}
+	req, err := http.NewRequestWithContext(poll.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if poll.version != "" {
+		req.Header.Set("If-None-Match", protocol.ETag(poll.version))
+	}
+	c.identify(req)
+
+	resp, err := poll.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		c.recordContact()
+		poll.version = protocol.ParseETag(resp.Header.Get("ETag"))
+		return data, nil
+	case http.StatusNotModified:
+		c.recordContact()
+		return nil, nil
+	default:
+		return nil, c.refusal(resp)
+	}
+}
+
+// post sends an ack or nack for a polled config.
+func (c *Client) post(poll *pollSession, msgBytes []byte) error {
+	req, err := http.NewRequestWithContext(poll.ctx, http.MethodPost, poll.url+protocol.PollAckPath, bytes.NewReader(msgBytes))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.identify(req)
+
+	resp, err := poll.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent {
+		return c.refusal(resp)
+	}
+	c.recordContact()
+	return nil
+}
+
+// identify adds the data plane's identity, labels and, if a token secret is set, a fresh token to a request.
+func (c *Client) identify(req *http.Request) {
+	req.Header.Set(protocol.HeaderDataPlaneID, c.identity.ID)
+	req.Header.Set(protocol.HeaderDataPlaneHostname, c.identity.Hostname)
+	req.Header.Set(protocol.HeaderDataPlaneBuild, c.identity.Build)
+	if len(c.identity.Labels) > 0 {
+		req.Header.Set(protocol.HeaderDataPlaneLabels, protocol.FormatLabels(c.identity.Labels))
+	}
+	if c.secret != nil {
+		token := protocol.SignToken(c.secret, c.identity.ID, time.Now().Add(protocol.DefaultTokenTTL))
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// refusal describes an unexpected control plane response.
+func (c *Client) refusal(resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("control plane refused data plane %s: %s", c.identity.ID, resp.Status)
+	}
+	return fmt.Errorf("unexpected control plane response: %s", resp.Status)
+}
@@ -0,0 +1,120 @@
+package dataplane
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// StalenessPolicy decides what a router does once its config is stale.
+type StalenessPolicy string
+
+const (
+	// StalenessWarn logs when the config becomes stale and keeps serving it.
+	StalenessWarn StalenessPolicy = "warn"
+	// StalenessUnready also fails readiness so load balancers can drain the router.
+	StalenessUnready StalenessPolicy = "unready"
+)
+
+// stalenessCheckInterval is how often the guard re-evaluates staleness for logging.
+const stalenessCheckInterval = time.Second
+
+// StalenessSource reports how long ago the control plane was last heard from.
+type StalenessSource interface {
+	Staleness() time.Duration
+	Connected() bool
+}
+
+// StalenessGuard applies a staleness policy once the control plane has been silent beyond a threshold.
+type StalenessGuard struct {
+	source    StalenessSource
+	threshold time.Duration
+	policy    StalenessPolicy
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+}
+
+// ParseStalenessPolicy parses a policy name.
+func ParseStalenessPolicy(policy string) (StalenessPolicy, error) {
+	switch StalenessPolicy(policy) {
+	case StalenessWarn, StalenessUnready:
+		return StalenessPolicy(policy), nil
+	}
+	return "", fmt.Errorf("invalid staleness policy '%s' (want warn or unready)", policy)
+}
+
+// NewStalenessGuard creates a guard treating the config as stale after threshold without control plane contact.
+func NewStalenessGuard(source StalenessSource, threshold time.Duration, policy StalenessPolicy) *StalenessGuard {
+	return &StalenessGuard{
+		source:    source,
+		threshold: threshold,
+		policy:    policy,
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Stale reports whether the config is stale and how long since the control plane was heard from.
+func (g *StalenessGuard) Stale() (bool, time.Duration) {
+	staleness := g.source.Staleness()
+	return staleness > g.threshold, staleness
+}
+
+// Start logs when the config becomes stale and when the control plane is heard from again.
+func (g *StalenessGuard) Start() {
+	go g.watch()
+}
+
+// Stop stops the guard's logging.
+func (g *StalenessGuard) Stop() {
+	g.stopOnce.Do(func() {
+		close(g.stopCh)
+	})
+}
+
+// watch logs staleness transitions.
+func (g *StalenessGuard) watch() {
+	ticker := time.NewTicker(stalenessCheckInterval)
+	defer ticker.Stop()
+
+	wasStale := false
+	for {
+		select {
+		case <-ticker.C:
+			stale, staleness := g.Stale()
+			switch {
+			case stale && !wasStale:
+				log.Printf("[DP] Config is stale: control plane not heard from for %v (threshold %v, policy %s)",
+					staleness.Round(time.Second), g.threshold, g.policy)
+			case !stale && wasStale:
+				log.Printf("[DP] Control plane heard from again, config no longer stale")
+			}
+			wasStale = stale
+		case <-g.stopCh:
+			return
+		}
+	}
+}
+
+// ServeHTTP handles /ready requests, returning 503 when stale under the unready policy.
+func (g *StalenessGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stale, staleness := g.Stale()
+
+	status, code := "ready", http.StatusOK
+	if stale {
+		status = "stale"
+		if g.policy == StalenessUnready {
+			code = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                  status,
+		"control_plane_connected": g.source.Connected(),
+		"staleness_seconds":       staleness.Seconds(),
+	})
+}
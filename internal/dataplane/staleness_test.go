@@ -0,0 +1,95 @@
+package dataplane
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+type fakeStalenessSource struct {
+	staleness time.Duration
+}
+
+func (s *fakeStalenessSource) Staleness() time.Duration { return s.staleness }
+func (s *fakeStalenessSource) Connected() bool          { return s.staleness < time.Second }
+
+func TestStalenessGuard_Ready(t *testing.T) {
+	tests := []struct {
+		name       string
+		staleness  time.Duration
+		policy     StalenessPolicy
+		wantStatus int
+		wantBody   string
+	}{
+		{"fresh", 100 * time.Millisecond, StalenessUnready, http.StatusOK, `"status":"ready"`},
+		{"stale with warn policy", 10 * time.Minute, StalenessWarn, http.StatusOK, `"status":"stale"`},
+		{"stale with unready policy", 10 * time.Minute, StalenessUnready, http.StatusServiceUnavailable, `"status":"stale"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := NewStalenessGuard(&fakeStalenessSource{staleness: tt.staleness}, 5*time.Minute, tt.policy)
+
+			rec := httptest.NewRecorder()
+			guard.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("/ready = %d %s, want %d with %s", rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+
+	if _, err := ParseStalenessPolicy("ignore"); err == nil {
+		t.Error("ParseStalenessPolicy(ignore) error = nil, want invalid policy")
+	}
+}
+
+func TestClientReconnectsWhenControlPlaneGoesSilent(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	connections := make(chan int32, 4)
+	var count atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		connections <- count.Add(1)
+
+		// Never read, so pings go unanswered as on a half-open connection
+		time.Sleep(time.Second)
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	loader := config.NewLoader("test-config.json", 5*time.Second)
+	client, err := NewClient(wsURL, loader)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	client.SetHeartbeat(20*time.Millisecond, 100*time.Millisecond)
+	client.Start()
+	defer client.Stop()
+
+	for want := int32(1); want <= 2; want++ {
+		select {
+		case got := <-connections:
+			if got != want {
+				t.Fatalf("connection %d, want %d", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for connection %d", want)
+		}
+	}
+
+	if staleness := client.Staleness(); staleness > time.Second {
+		t.Errorf("Staleness() = %v right after reconnecting, want under a second", staleness)
+	}
+}
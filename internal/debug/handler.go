@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/limits"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/proxy"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/routing"
 )
 
 // ConfigProvider provides access to config metadata
@@ -11,11 +15,19 @@ type ConfigProvider interface {
 	GetConfigVersion() string
 	GetConfigSource() interface{}
 	LastReloadTime() time.Time
+	PreviousVersion() string
+}
+
+// StalenessProvider reports control plane connectivity
+type StalenessProvider interface {
+	Staleness() time.Duration
+	Connected() bool
 }
 
 // Handler provides debug endpoints
 type Handler struct {
-	configProvider ConfigProvider
+	configProvider    ConfigProvider
+	stalenessProvider StalenessProvider
 }
 
 // NewHandler creates a new debug handler
@@ -25,6 +37,11 @@ func NewHandler(configProvider ConfigProvider) *Handler {
 	}
 }
 
+// SetStalenessProvider adds control plane connectivity and config staleness to the response
+func (h *Handler) SetStalenessProvider(stalenessProvider StalenessProvider) {
+	h.stalenessProvider = stalenessProvider
+}
+
 // ServeHTTP handles /debug/config requests
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	version := h.configProvider.GetConfigVersion()
@@ -36,6 +53,143 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"source":         source,
 		"last_reload_at": lastReload.Format(time.RFC3339),
 	}
+	if previousVersion := h.configProvider.PreviousVersion(); previousVersion != "" {
+		response["previous_version"] = previousVersion
+	}
+	if h.stalenessProvider != nil {
+		response["control_plane_connected"] = h.stalenessProvider.Connected()
+		response["staleness_seconds"] = h.stalenessProvider.Staleness().Seconds()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// LimitsProvider provides access to concurrency limit state
+type LimitsProvider interface {
+	Stats() []limits.PlacementStats
+}
+
+// LimitsHandler serves current concurrency limits per placement
+type LimitsHandler struct {
+	limitsProvider LimitsProvider
+}
+
+// NewLimitsHandler creates a new limits debug handler
+func NewLimitsHandler(limitsProvider LimitsProvider) *LimitsHandler {
+	return &LimitsHandler{
+		limitsProvider: limitsProvider,
+	}
+}
+
+// ServeHTTP handles /debug/limits requests
+func (h *LimitsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"placements": h.limitsProvider.Stats(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// PlacementsProvider provides access to per-placement resilience state
+type PlacementsProvider interface {
+	Placements() []proxy.PlacementStatus
+}
+
+// PlacementsHandler serves health, circuit breaker and limit state for every placement
+type PlacementsHandler struct {
+	placementsProvider PlacementsProvider
+}
+
+// NewPlacementsHandler creates a new placements debug handler
+func NewPlacementsHandler(placementsProvider PlacementsProvider) *PlacementsHandler {
+	return &PlacementsHandler{
+		placementsProvider: placementsProvider,
+	}
+}
+
+// ServeHTTP handles /debug/placements requests
+func (h *PlacementsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"placements": h.placementsProvider.Placements(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RoutingTableProvider provides access to the active routing table
+type RoutingTableProvider interface {
+	GetConfigVersion() string
+	GetRoutingTable() map[string]string
+	GetDefaultPlacement() string
+}
+
+// RoutesHandler serves the active routing table
+type RoutesHandler struct {
+	routingTableProvider RoutingTableProvider
+}
+
+// NewRoutesHandler creates a new routing table debug handler
+func NewRoutesHandler(routingTableProvider RoutingTableProvider) *RoutesHandler {
+	return &RoutesHandler{
+		routingTableProvider: routingTableProvider,
+	}
+}
+
+// ServeHTTP handles /debug/routes requests
+func (h *RoutesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"version":           h.routingTableProvider.GetConfigVersion(),
+		"default_placement": h.routingTableProvider.GetDefaultPlacement(),
+		"routing_table":     h.routingTableProvider.GetRoutingTable(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RouteExplainer dry-runs routing and failover for a routing key
+type RouteExplainer interface {
+	ExplainRoute(routingKey string) (*routing.RoutingDecision, proxy.FailoverPlan, error)
+}
+
+// RouteHandler serves routing dry-runs
+type RouteHandler struct {
+	routeExplainer RouteExplainer
+}
+
+// NewRouteHandler creates a new route dry-run debug handler
+func NewRouteHandler(routeExplainer RouteExplainer) *RouteHandler {
+	return &RouteHandler{
+		routeExplainer: routeExplainer,
+	}
+}
+
+// ServeHTTP handles /debug/route?key=<routing key> requests
+func (h *RouteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	routingKey := r.URL.Query().Get("key")
+	if routingKey == "" {
+		http.Error(w, "Bad Request: key query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	decision, plan, err := h.routeExplainer.ExplainRoute(routingKey)
+	if err != nil {
+		http.Error(w, "Routing error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"routing_key": routingKey,
+		"decision": map[string]interface{}{
+			"placement_key": decision.PlacementKey,
+			"route_reason":  decision.Reason,
+			"endpoint_url":  decision.EndpointURL,
+		},
+		"failover": plan,
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -16,6 +16,8 @@ type State string
 const (
 	StateHealthy   State = "healthy"
 	StateUnhealthy State = "unhealthy"
+	// StateDraining is set by operators to move new traffic off a placement until it expires
+	StateDraining State = "draining"
 )
 
 // CheckConfig configures health checking for an endpoint
@@ -27,14 +29,31 @@ type CheckConfig struct {
 
 // EndpointHealth tracks the health of a single endpoint
 type EndpointHealth struct {
-	URL       string
-	State     State
-	LastCheck time.Time
-	mu        sync.RWMutex
+	URL        string
+	State      State
+	LastCheck  time.Time
+	config     CheckConfig
+	drainUntil time.Time // When the operator drain expires, zero when not drained
+	mu         sync.RWMutex
 }
 
-// GetState returns the current health state thread-safely
+// GetState returns the current health state thread-safely, reporting draining while a drain is active
 func (e *EndpointHealth) GetState() State {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.draining(time.Now()) {
+		return StateDraining
+	}
+	return e.State
+}
+
+// draining reports whether an operator drain is active (must be called with lock held)
+func (e *EndpointHealth) draining(now time.Time) bool {
+	return now.Before(e.drainUntil)
+}
+
+// probeState returns the state observed by the last health probe, ignoring drains
+func (e *EndpointHealth) probeState() State {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	return e.State
@@ -66,7 +85,7 @@ func NewChecker(config CheckConfig, logger *logging.Logger) *Checker {
 		config:    config,
 		logger:    logger,
 		client: &http.Client{
-			Timeout: config.Timeout,
+			// Timeouts are applied per check, since endpoints may override the config
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				return http.ErrUseLastResponse
 			},
@@ -77,6 +96,11 @@ func NewChecker(config CheckConfig, logger *logging.Logger) *Checker {
 
 // RegisterEndpoint adds an endpoint to be health checked
 func (c *Checker) RegisterEndpoint(placementKey, url string) {
+	c.RegisterEndpointWithConfig(placementKey, url, c.config)
+}
+
+// RegisterEndpointWithConfig adds an endpoint checked with its own path, interval and timeout
+func (c *Checker) RegisterEndpointWithConfig(placementKey, url string, config CheckConfig) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -85,8 +109,9 @@ func (c *Checker) RegisterEndpoint(placementKey, url string) {
 	}
 
 	endpoint := &EndpointHealth{
-		URL:   url,
-		State: StateHealthy, // Start as healthy
+		URL:    url,
+		State:  StateHealthy, // Start as healthy
+		config: config,
 	}
 	c.endpoints[placementKey] = endpoint
 
@@ -129,6 +154,79 @@ func (c *Checker) GetState(placementKey string) State {
 	return endpoint.GetState()
 }
 
+// Drain marks an endpoint unhealthy until the given time so new traffic fails over
+// Returns false if the endpoint is not registered
+func (c *Checker) Drain(placementKey string, until time.Time) bool {
+	return c.setDrain(placementKey, until)
+}
+
+// Undrain clears an operator drain, restoring the probed health state
+// Returns false if the endpoint is not registered
+func (c *Checker) Undrain(placementKey string) bool {
+	return c.setDrain(placementKey, time.Time{})
+}
+
+// setDrain sets or clears an endpoint's drain expiry and logs the change
+func (c *Checker) setDrain(placementKey string, until time.Time) bool {
+	c.mu.RLock()
+	endpoint, exists := c.endpoints[placementKey]
+	c.mu.RUnlock()
+
+	if !exists {
+		return false
+	}
+
+	endpoint.mu.Lock()
+	endpoint.drainUntil = until
+	endpoint.mu.Unlock()
+
+	fields := map[string]interface{}{
+		"placement": placementKey,
+		"url":       endpoint.URL,
+		"timestamp": time.Now().Unix(),
+	}
+	if until.IsZero() {
+		c.logger.LogInfo("endpoint drain cleared", fields)
+	} else {
+		fields["drain_until"] = until.Format(time.RFC3339)
+		c.logger.LogInfo("endpoint draining", fields)
+	}
+	return true
+}
+
+// EndpointStatus is a point-in-time view of an endpoint's health
+type EndpointStatus struct {
+	URL        string     `json:"url"`
+	State      State      `json:"state"`
+	LastCheck  time.Time  `json:"last_check"`
+	DrainUntil *time.Time `json:"drain_until,omitempty"` // Set while an operator drain is active
+}
+
+// Snapshot returns the health of every registered endpoint keyed by placement
+func (c *Checker) Snapshot() map[string]EndpointStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := time.Now()
+	snapshot := make(map[string]EndpointStatus, len(c.endpoints))
+	for placementKey, endpoint := range c.endpoints {
+		endpoint.mu.RLock()
+		status := EndpointStatus{
+			URL:       endpoint.URL,
+			State:     endpoint.State,
+			LastCheck: endpoint.LastCheck,
+		}
+		if endpoint.draining(now) {
+			drainUntil := endpoint.drainUntil
+			status.State = StateDraining
+			status.DrainUntil = &drainUntil
+		}
+		endpoint.mu.RUnlock()
+		snapshot[placementKey] = status
+	}
+	return snapshot
+}
+
 // Stop stops all health checking goroutines
 func (c *Checker) Stop() {
 	close(c.stopCh)
@@ -139,7 +237,7 @@ func (c *Checker) Stop() {
 func (c *Checker) checkLoop(placementKey string, endpoint *EndpointHealth) {
 	defer c.wg.Done()
 
-	ticker := time.NewTicker(c.config.Interval)
+	ticker := time.NewTicker(endpoint.config.Interval)
 	defer ticker.Stop()
 
 	// Perform initial check immediately
@@ -157,10 +255,10 @@ func (c *Checker) checkLoop(placementKey string, endpoint *EndpointHealth) {
 
 // performCheck executes a single health check
 func (c *Checker) performCheck(placementKey string, endpoint *EndpointHealth) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), endpoint.config.Timeout)
 	defer cancel()
 
-	healthURL := endpoint.URL + c.config.Path
+	healthURL := endpoint.URL + endpoint.config.Path
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
 	if err != nil {
 		c.transitionState(placementKey, endpoint, StateUnhealthy, fmt.Sprintf("request_creation_failed: %v", err))
@@ -184,7 +282,7 @@ func (c *Checker) performCheck(placementKey string, endpoint *EndpointHealth) {
 
 // transitionState updates endpoint state and logs transitions
 func (c *Checker) transitionState(placementKey string, endpoint *EndpointHealth, newState State, reason string) {
-	oldState := endpoint.GetState()
+	oldState := endpoint.probeState()
 
 	if oldState != newState {
 		endpoint.setState(newState)
@@ -0,0 +1,103 @@
+package limits
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveConfig configures an AIMD concurrency limit for a placement
+type AdaptiveConfig struct {
+	MinLimit         int     // Lower bound on the limit
+	MaxLimit         int     // Upper bound on the limit
+	InitialLimit     int     // Starting limit (default MaxLimit)
+	LatencyTolerance float64 // Latency above this multiple of the baseline counts as congestion (default 2)
+	BackoffRatio     float64 // Multiplicative decrease on congestion (default 0.9)
+}
+
+// baselineDecay is how quickly the baseline latency drifts towards recent samples,
+// so the baseline tracks upstream changes instead of a single lucky fast response
+const baselineDecay = 0.01
+
+// adaptiveLimiter adjusts a concurrency limit from observed upstream latency and errors.
+// Successful requests that find the limit in use add one slot (additive increase);
+// errors and latency above LatencyTolerance x baseline shrink it by BackoffRatio
+// (multiplicative decrease). The limit stays within [MinLimit, MaxLimit].
+type adaptiveLimiter struct {
+	config   AdaptiveConfig
+	limit    float64
+	baseline time.Duration // Decaying minimum of observed latency
+	mu       sync.Mutex
+}
+
+// newAdaptiveLimiter creates an adaptive limiter, filling in defaults
+func newAdaptiveLimiter(config AdaptiveConfig) *adaptiveLimiter {
+	if config.MinLimit <= 0 {
+		config.MinLimit = 1
+	}
+	if config.MaxLimit < config.MinLimit {
+		config.MaxLimit = config.MinLimit
+	}
+	if config.InitialLimit <= 0 {
+		config.InitialLimit = config.MaxLimit
+	}
+	if config.LatencyTolerance <= 1 {
+		config.LatencyTolerance = 2
+	}
+	if config.BackoffRatio <= 0 || config.BackoffRatio >= 1 {
+		config.BackoffRatio = 0.9
+	}
+
+	return &adaptiveLimiter{
+		config: config,
+		limit:  clamp(float64(config.InitialLimit), config.MinLimit, config.MaxLimit),
+	}
+}
+
+// setConfig replaces the bounds and tuning, keeping the current limit within the new bounds
+func (a *adaptiveLimiter) setConfig(config AdaptiveConfig) {
+	updated := newAdaptiveLimiter(config)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.config = updated.config
+	a.limit = clamp(a.limit, a.config.MinLimit, a.config.MaxLimit)
+}
+
+// sample records one upstream outcome and returns the new limit
+func (a *adaptiveLimiter) sample(latency time.Duration, inFlight int, failed bool) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !failed {
+		if a.baseline == 0 || latency < a.baseline {
+			a.baseline = latency
+		} else {
+			a.baseline += time.Duration(float64(latency-a.baseline) * baselineDecay)
+		}
+	}
+
+	congested := failed || float64(latency) > float64(a.baseline)*a.config.LatencyTolerance
+	switch {
+	case congested:
+		a.limit *= a.config.BackoffRatio
+	case float64(inFlight) >= a.limit/2:
+		// Only grow when the limit is actually being used
+		a.limit++
+	}
+
+	a.limit = clamp(a.limit, a.config.MinLimit, a.config.MaxLimit)
+	return int(a.limit)
+}
+
+// current returns the current limit
+func (a *adaptiveLimiter) current() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+// clamp bounds v to [min, max]
+func clamp(v float64, min, max int) float64 {
+	return math.Max(float64(min), math.Min(float64(max), v))
+}
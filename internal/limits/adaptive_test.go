@@ -0,0 +1,60 @@
+package limits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+)
+
+func TestAdaptiveLimiter_AIMD(t *testing.T) {
+	a := newAdaptiveLimiter(AdaptiveConfig{MinLimit: 5, MaxLimit: 20, InitialLimit: 10})
+
+	// Saturated, fast responses grow the limit additively
+	for i := 0; i < 5; i++ {
+		a.sample(10*time.Millisecond, 10, false)
+	}
+	if limit := a.current(); limit != 15 {
+		t.Errorf("limit after growth = %d, want 15", limit)
+	}
+
+	// Idle responses don't grow the limit
+	a.sample(10*time.Millisecond, 1, false)
+	if limit := a.current(); limit != 15 {
+		t.Errorf("limit after idle sample = %d, want 15", limit)
+	}
+
+	// Latency well above baseline shrinks it multiplicatively
+	a.sample(100*time.Millisecond, 15, false)
+	if limit := a.current(); limit != 13 {
+		t.Errorf("limit after slow sample = %d, want 13", limit)
+	}
+
+	// Errors never push it below the minimum
+	for i := 0; i < 50; i++ {
+		a.sample(10*time.Millisecond, 1, true)
+	}
+	if limit := a.current(); limit != 5 {
+		t.Errorf("limit after errors = %d, want 5", limit)
+	}
+}
+
+func TestManager_RecordOutcome_AdjustsCapacity(t *testing.T) {
+	m := NewManager(logging.NewLogger())
+	m.SetConfig("tier1", Config{
+		MaxConcurrentRequests: 10,
+		Adaptive:              &AdaptiveConfig{MinLimit: 2, MaxLimit: 10},
+	})
+
+	for i := 0; i < 10; i++ {
+		m.RecordOutcome("tier1", 10*time.Millisecond, true)
+	}
+
+	stats := m.Stats()
+	if len(stats) != 1 || !stats[0].Adaptive {
+		t.Fatalf("Stats = %+v, want one adaptive placement", stats)
+	}
+	if stats[0].Limit >= 10 || stats[0].Limit < 2 {
+		t.Errorf("Limit = %d, want reduced within [2, 10)", stats[0].Limit)
+	}
+}
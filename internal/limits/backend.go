@@ -0,0 +1,35 @@
+package limits
+
+import "time"
+
+// RateLimitBackend stores token buckets and decides whether a request may consume a token
+type RateLimitBackend interface {
+	Take(key string, rule RateLimitRule) RateLimitResult
+}
+
+// resizableBackend is a backend whose bucket bound can change without losing bucket state
+type resizableBackend interface {
+	SetMaxKeys(maxKeys int)
+}
+
+// LocalBackend enforces rate limits from in-process token buckets only
+type LocalBackend struct {
+	buckets *bucketStore
+}
+
+// NewLocalBackend creates an in-process backend tracking at most maxKeys buckets
+func NewLocalBackend(maxKeys int) *LocalBackend {
+	return &LocalBackend{
+		buckets: newBucketStore(maxKeys),
+	}
+}
+
+// Take consumes one token from the bucket for key
+func (b *LocalBackend) Take(key string, rule RateLimitRule) RateLimitResult {
+	return b.buckets.take(key, rule, time.Now())
+}
+
+// SetMaxKeys changes the number of buckets tracked, keeping the most recently used ones
+func (b *LocalBackend) SetMaxKeys(maxKeys int) {
+	b.buckets.resize(maxKeys)
+}
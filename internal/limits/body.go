@@ -0,0 +1,66 @@
+package limits
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// ErrBodyTooLarge is returned by a BodyLimiter once the body exceeds its limit
+var ErrBodyTooLarge = errors.New("request body exceeds limit")
+
+// BodyLimiter wraps a request body and fails reads once more than limit bytes are read.
+// Unlike a Content-Length check, this also catches chunked bodies.
+type BodyLimiter struct {
+	body      io.ReadCloser
+	limit     int64
+	remaining int64
+	exceeded  atomic.Bool
+}
+
+// NewBodyLimiter wraps body so that reading more than limit bytes fails with ErrBodyTooLarge
+func NewBodyLimiter(body io.ReadCloser, limit int64) *BodyLimiter {
+	return &BodyLimiter{
+		body:      body,
+		limit:     limit,
+		remaining: limit,
+	}
+}
+
+// Read implements io.Reader
+func (b *BodyLimiter) Read(p []byte) (int, error) {
+	if b.exceeded.Load() {
+		return 0, ErrBodyTooLarge
+	}
+
+	// Read one byte past the limit so an exactly-sized body isn't rejected
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.body.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded.Store(true)
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, ErrBodyTooLarge
+	}
+
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *BodyLimiter) Close() error {
+	return b.body.Close()
+}
+
+// Exceeded reports whether the body crossed the limit
+func (b *BodyLimiter) Exceeded() bool {
+	return b.exceeded.Load()
+}
+
+// Limit returns the configured limit in bytes
+func (b *BodyLimiter) Limit() int64 {
+	return b.limit
+}
@@ -0,0 +1,42 @@
+package limits
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimiter(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		limit        int64
+		wantExceeded bool
+	}{
+		{name: "under limit", body: "hello", limit: 10},
+		{name: "exactly at limit", body: "hello", limit: 5},
+		{name: "over limit", body: "hello world", limit: 5, wantExceeded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewBodyLimiter(io.NopCloser(strings.NewReader(tt.body)), tt.limit)
+			data, err := io.ReadAll(limiter)
+
+			if limiter.Exceeded() != tt.wantExceeded {
+				t.Errorf("Exceeded() = %v, want %v", limiter.Exceeded(), tt.wantExceeded)
+			}
+			if tt.wantExceeded {
+				if !errors.Is(err, ErrBodyTooLarge) {
+					t.Errorf("err = %v, want ErrBodyTooLarge", err)
+				}
+				if int64(len(data)) != tt.limit {
+					t.Errorf("read %d bytes, want %d", len(data), tt.limit)
+				}
+			} else if err != nil || string(data) != tt.body {
+				t.Errorf("ReadAll = %q, %v; want %q, nil", data, err, tt.body)
+			}
+		})
+	}
+}
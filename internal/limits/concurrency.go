@@ -0,0 +1,261 @@
+package limits
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// fairShareThreshold is the placement utilization above which tenants are held to a fair share
+const fairShareThreshold = 0.8
+
+var (
+	// ErrPlacementLimit is returned when a placement's concurrency ceiling is reached
+	ErrPlacementLimit = errors.New("placement concurrency limit reached")
+	// ErrTenantLimit is returned when a routing key's concurrency quota is reached
+	ErrTenantLimit = errors.New("tenant concurrency limit reached")
+	// ErrFairShare is returned when a contended placement holds a routing key to its fair share
+	ErrFairShare = errors.New("tenant exceeds fair share of contended placement")
+	// ErrQueueFull is returned when a placement's wait queue is at its max length
+	ErrQueueFull = errors.New("concurrency wait queue full")
+	// ErrQueueTimeout is returned when a queued request waits longer than the max wait time
+	ErrQueueTimeout = errors.New("concurrency wait queue timeout")
+	// ErrDeadlineShed is returned when a request is shed because it would miss its deadline
+	ErrDeadlineShed = errors.New("request shed before deadline")
+)
+
+// QueueOrder selects which waiter gets the next free slot
+type QueueOrder string
+
+const (
+	// QueueFIFO serves the longest-waiting request first
+	QueueFIFO QueueOrder = "fifo"
+	// QueueLIFO serves the newest request first, favoring requests whose clients are still waiting
+	QueueLIFO QueueOrder = "lifo"
+)
+
+// QueueConfig configures a bounded wait queue in front of a placement's concurrency limit
+type QueueConfig struct {
+	MaxLength int           // Max queued requests (0 = no queue)
+	MaxWait   time.Duration // Max time a request waits for a slot
+	Order     QueueOrder
+}
+
+// waiter is a request queued for a concurrency slot
+type waiter struct {
+	routingKey string
+	ready      chan struct{} // Closed when a slot is granted
+	granted    bool
+}
+
+// placementLimiter enforces a placement-wide concurrency ceiling plus per-tenant quotas
+type placementLimiter struct {
+	capacity  int            // Placement ceiling (0 = unlimited)
+	tenantMax int            // Default per-tenant quota (0 = unlimited)
+	overrides map[string]int // Per routing key quotas
+	queue     QueueConfig
+	inFlight  int
+	tenants   map[string]int // In-flight requests per routing key
+	waiters   *list.List     // Queued *waiter, oldest at front
+	mu        sync.Mutex
+}
+
+// newPlacementLimiter creates a limiter from a placement's limit configuration
+func newPlacementLimiter(config Config) *placementLimiter {
+	return &placementLimiter{
+		capacity:  config.MaxConcurrentRequests,
+		tenantMax: config.MaxConcurrentPerTenant,
+		overrides: config.TenantOverrides,
+		queue:     config.Queue,
+		tenants:   make(map[string]int),
+		waiters:   list.New(),
+	}
+}
+
+// tryAcquire takes a slot for routingKey if the placement and tenant limits allow it
+func (l *placementLimiter) tryAcquire(routingKey string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.admit(routingKey); err != nil {
+		return err
+	}
+
+	l.take(routingKey)
+	return nil
+}
+
+// acquire takes a slot for routingKey, waiting in the queue if one is configured.
+// Returns how long the request was queued.
+func (l *placementLimiter) acquire(ctx context.Context, routingKey string) (time.Duration, error) {
+	l.mu.Lock()
+	err := l.admit(routingKey)
+	if err == nil {
+		l.take(routingKey)
+		l.mu.Unlock()
+		return 0, nil
+	}
+
+	if l.queue.MaxLength <= 0 {
+		l.mu.Unlock()
+		return 0, err
+	}
+	if l.waiters.Len() >= l.queue.MaxLength {
+		l.mu.Unlock()
+		return 0, ErrQueueFull
+	}
+
+	// Wait no longer than the request's own deadline allows
+	wait, timeoutErr := l.queue.MaxWait, ErrQueueTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining <= wait {
+			wait, timeoutErr = remaining, ErrDeadlineShed
+		}
+	}
+	if wait <= 0 {
+		l.mu.Unlock()
+		return 0, timeoutErr
+	}
+
+	w := &waiter{routingKey: routingKey, ready: make(chan struct{})}
+	elem := l.waiters.PushBack(w)
+	l.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return time.Since(start), nil
+	case <-timer.C:
+		err = timeoutErr
+	case <-ctx.Done():
+		err = ErrDeadlineShed
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// A slot was granted while timing out; keep it
+		return time.Since(start), nil
+	}
+	l.waiters.Remove(elem)
+	return time.Since(start), err
+}
+
+// take records a slot held by routingKey (must be called with lock held)
+func (l *placementLimiter) take(routingKey string) {
+	l.inFlight++
+	l.tenants[routingKey]++
+}
+
+// admit checks whether routingKey may take another slot (must be called with lock held)
+func (l *placementLimiter) admit(routingKey string) error {
+	if l.capacity > 0 && l.inFlight >= l.capacity {
+		return ErrPlacementLimit
+	}
+
+	held := l.tenants[routingKey]
+	if quota := l.tenantQuota(routingKey); quota > 0 && held >= quota {
+		return ErrTenantLimit
+	}
+
+	if l.capacity > 0 && float64(l.inFlight) >= float64(l.capacity)*fairShareThreshold {
+		active := len(l.tenants)
+		if held == 0 {
+			active++
+		}
+		// Round up so shares cover the whole capacity
+		if share := (l.capacity + active - 1) / active; held >= share {
+			return ErrFairShare
+		}
+	}
+	return nil
+}
+
+// tenantQuota returns the configured quota for a routing key (0 = unlimited)
+func (l *placementLimiter) tenantQuota(routingKey string) int {
+	if quota, exists := l.overrides[routingKey]; exists {
+		return quota
+	}
+	return l.tenantMax
+}
+
+// release returns a slot held by routingKey
+func (l *placementLimiter) release(routingKey string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.tenants[routingKey] == 0 {
+		return
+	}
+
+	l.inFlight--
+	l.tenants[routingKey]--
+	if l.tenants[routingKey] == 0 {
+		delete(l.tenants, routingKey)
+	}
+
+	l.grantWaiters()
+}
+
+// grantWaiters hands free slots to queued requests in queue order (must be called with lock held)
+func (l *placementLimiter) grantWaiters() {
+	elem := l.waiters.Front()
+	if l.queue.Order == QueueLIFO {
+		elem = l.waiters.Back()
+	}
+
+	for elem != nil {
+		next := elem.Next()
+		if l.queue.Order == QueueLIFO {
+			next = elem.Prev()
+		}
+
+		w := elem.Value.(*waiter)
+		if l.admit(w.routingKey) == nil {
+			l.take(w.routingKey)
+			l.waiters.Remove(elem)
+			w.granted = true
+			close(w.ready)
+		} else if l.capacity > 0 && l.inFlight >= l.capacity {
+			return
+		}
+		elem = next
+	}
+}
+
+// reconfigure applies new limits, keeping in-flight requests and queued waiters
+// Waiters the new limits admit are granted slots; waiters beyond a shorter queue keep waiting
+func (l *placementLimiter) reconfigure(config Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.capacity = config.MaxConcurrentRequests
+	l.tenantMax = config.MaxConcurrentPerTenant
+	l.overrides = config.TenantOverrides
+	l.queue = config.Queue
+	l.grantWaiters()
+}
+
+// setCapacity changes the placement ceiling and admits waiters if it grew
+func (l *placementLimiter) setCapacity(capacity int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	grew := capacity > l.capacity
+	l.capacity = capacity
+	if grew {
+		l.grantWaiters()
+	}
+}
+
+// stats returns the number of in-flight requests, the placement ceiling and the queue length
+func (l *placementLimiter) stats() (int, int, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight, l.capacity, l.waiters.Len()
+}
@@ -0,0 +1,163 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+)
+
+func TestManager_TenantQuota(t *testing.T) {
+	m := NewManager(logging.NewLogger())
+	m.SetConfig("tier1", Config{
+		MaxConcurrentRequests:  100,
+		MaxConcurrentPerTenant: 2,
+		TenantOverrides:        map[string]int{"acme": 3},
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := m.TryAcquire("tier1", "globex"); err != nil {
+			t.Fatalf("globex acquire %d failed: %v", i, err)
+		}
+	}
+	if err := m.TryAcquire("tier1", "globex"); !errors.Is(err, ErrTenantLimit) {
+		t.Errorf("globex third acquire: err = %v, want ErrTenantLimit", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := m.TryAcquire("tier1", "acme"); err != nil {
+			t.Fatalf("acme acquire %d failed: %v", i, err)
+		}
+	}
+
+	m.Release("tier1", "globex")
+	if err := m.TryAcquire("tier1", "globex"); err != nil {
+		t.Errorf("globex acquire after release failed: %v", err)
+	}
+
+	if inFlight, capacity, _ := m.InFlight("tier1"); inFlight != 5 || capacity != 100 {
+		t.Errorf("InFlight = %d/%d, want 5/100", inFlight, capacity)
+	}
+}
+
+func TestManager_FairShareWhenContended(t *testing.T) {
+	m := NewManager(logging.NewLogger())
+	m.SetConfig("tier1", Config{MaxConcurrentRequests: 10})
+
+	// A single tenant may use the whole placement
+	for i := 0; i < 9; i++ {
+		if err := m.TryAcquire("tier1", "noisy"); err != nil {
+			t.Fatalf("noisy acquire %d failed: %v", i, err)
+		}
+	}
+
+	// A second tenant still gets the last slot
+	if err := m.TryAcquire("tier1", "acme"); err != nil {
+		t.Fatalf("acme acquire failed: %v", err)
+	}
+	if err := m.TryAcquire("tier1", "noisy"); !errors.Is(err, ErrPlacementLimit) {
+		t.Errorf("noisy acquire at ceiling: err = %v, want ErrPlacementLimit", err)
+	}
+
+	// Once a slot frees up, the noisy tenant is held to half the placement
+	m.Release("tier1", "noisy")
+	if err := m.TryAcquire("tier1", "noisy"); !errors.Is(err, ErrFairShare) {
+		t.Errorf("noisy acquire above fair share: err = %v, want ErrFairShare", err)
+	}
+}
+
+func TestManager_Acquire_QueuesUntilRelease(t *testing.T) {
+	m := NewManager(logging.NewLogger())
+	m.SetConfig("tier1", Config{
+		MaxConcurrentRequests: 1,
+		Queue:                 QueueConfig{MaxLength: 1, MaxWait: time.Second, Order: QueueFIFO},
+	})
+
+	if _, err := m.Acquire(context.Background(), "tier1", "acme"); err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+
+	acquired := make(chan time.Duration, 1)
+	go func() {
+		queueTime, err := m.Acquire(context.Background(), "tier1", "globex")
+		if err != nil {
+			t.Errorf("Queued acquire failed: %v", err)
+		}
+		acquired <- queueTime
+	}()
+
+	// Wait for the request to be queued, then a second waiter finds the queue full
+	for m.QueueLength("tier1") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := m.Acquire(context.Background(), "tier1", "initech"); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Acquire with full queue: err = %v, want ErrQueueFull", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	m.Release("tier1", "acme")
+
+	select {
+	case queueTime := <-acquired:
+		if queueTime < 20*time.Millisecond {
+			t.Errorf("queueTime = %v, want at least 20ms", queueTime)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Queued request not granted after release")
+	}
+}
+
+func TestManager_Acquire_ShedsBeforeDeadline(t *testing.T) {
+	m := NewManager(logging.NewLogger())
+	m.SetConfig("tier1", Config{
+		MaxConcurrentRequests: 1,
+		Queue:                 QueueConfig{MaxLength: 10, MaxWait: 50 * time.Millisecond},
+	})
+	m.Acquire(context.Background(), "tier1", "acme")
+
+	if _, err := m.Acquire(context.Background(), "tier1", "acme"); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Acquire past max wait: err = %v, want ErrQueueTimeout", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := m.Acquire(ctx, "tier1", "acme"); !errors.Is(err, ErrDeadlineShed) {
+		t.Errorf("Acquire past client deadline: err = %v, want ErrDeadlineShed", err)
+	}
+	if n := m.QueueLength("tier1"); n != 0 {
+		t.Errorf("QueueLength = %d, want 0 after shedding", n)
+	}
+}
+
+func TestPlacementLimiter_LIFOServesNewestFirst(t *testing.T) {
+	l := newPlacementLimiter(Config{
+		MaxConcurrentRequests: 1,
+		Queue:                 QueueConfig{MaxLength: 2, MaxWait: time.Second, Order: QueueLIFO},
+	})
+	l.tryAcquire("holder")
+
+	granted := make(chan string, 2)
+	for _, key := range []string{"older", "newer"} {
+		go func() {
+			if _, err := l.acquire(context.Background(), key); err == nil {
+				granted <- key
+			}
+		}()
+		for {
+			_, _, queued := l.stats()
+			if key == "older" && queued == 1 || key == "newer" && queued == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	l.release("holder")
+	if first := <-granted; first != "newer" {
+		t.Errorf("First granted = %s, want newer", first)
+	}
+	l.release("newer")
+	<-granted
+}
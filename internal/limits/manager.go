@@ -3,15 +3,24 @@ package limits
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
 )
 
 // Config defines resource limits for a placement
 type Config struct {
-	MaxConcurrentRequests int   // Max concurrent requests per placement
-	MaxRequestBodyBytes   int64 // Max request body size in bytes
+	MaxConcurrentRequests  int             // Max concurrent requests per placement
+	MaxConcurrentPerTenant int             // Default max concurrent requests per routing key
+	TenantOverrides        map[string]int  // Per routing key max concurrent requests
+	MaxRequestBodyBytes    int64           // Max request body size in bytes
+	Queue                  QueueConfig     // Wait queue in front of the concurrency limit
+	Adaptive               *AdaptiveConfig // Adjust MaxConcurrentRequests from upstream latency
 }
 
 // Semaphore implements a counting semaphore for concurrency control
@@ -53,37 +62,146 @@ func (s *Semaphore) Release() {
 	<-s.ch
 }
 
-// Manager manages concurrency limits for multiple placements
+// PlacementStats describes the concurrency state of a placement
+type PlacementStats struct {
+	Placement string `json:"placement"`
+	InFlight  int    `json:"in_flight"`
+	Limit     int    `json:"limit"`
+	Queued    int    `json:"queued"`
+	Adaptive  bool   `json:"adaptive"`
+	MinLimit  int    `json:"min_limit,omitempty"`
+	MaxLimit  int    `json:"max_limit,omitempty"`
+}
+
+// Manager manages concurrency and rate limits for multiple placements
 type Manager struct {
-	semaphores map[string]*Semaphore
+	limiters   map[string]*placementLimiter
+	adaptive   map[string]*adaptiveLimiter
 	config     map[string]Config
+	rateLimits map[string]RateLimitConfig
+	backend    RateLimitBackend
 	logger     *logging.Logger
 	mu         sync.RWMutex
+
+	bodyLimitAborts atomic.Int64 // Requests aborted mid-stream for exceeding the body limit
 }
 
 // NewManager creates a new limits manager
 func NewManager(logger *logging.Logger) *Manager {
 	return &Manager{
-		semaphores: make(map[string]*Semaphore),
+		limiters:   make(map[string]*placementLimiter),
+		adaptive:   make(map[string]*adaptiveLimiter),
 		config:     make(map[string]Config),
+		rateLimits: make(map[string]RateLimitConfig),
+		backend:    NewLocalBackend(DefaultMaxRateLimitKeys),
 		logger:     logger,
 	}
 }
 
 // SetConfig sets the limit configuration for a placement
+// An existing limiter is updated in place, keeping its in-flight requests, queued waiters and adaptive limit
 func (m *Manager) SetConfig(placementKey string, config Config) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.config[placementKey] = config
 
-	// Create or update semaphore
-	if config.MaxConcurrentRequests > 0 {
-		m.semaphores[placementKey] = NewSemaphore(config.MaxConcurrentRequests)
+	// Adaptive limits start from the static limit, if any, and own the ceiling from then on
+	if config.Adaptive == nil {
+		delete(m.adaptive, placementKey)
 	} else {
-		// Remove semaphore if no limit set
-		delete(m.semaphores, placementKey)
+		adaptiveCfg := *config.Adaptive
+		if adaptiveCfg.InitialLimit == 0 {
+			adaptiveCfg.InitialLimit = config.MaxConcurrentRequests
+		}
+		adaptive, exists := m.adaptive[placementKey]
+		if exists {
+			adaptive.setConfig(adaptiveCfg)
+		} else {
+			adaptive = newAdaptiveLimiter(adaptiveCfg)
+			m.adaptive[placementKey] = adaptive
+		}
+		config.MaxConcurrentRequests = adaptive.current()
+	}
+
+	// Create or update concurrency limiter
+	limiter, exists := m.limiters[placementKey]
+	switch {
+	case config.MaxConcurrentRequests > 0 || config.MaxConcurrentPerTenant > 0 || len(config.TenantOverrides) > 0:
+		if exists {
+			limiter.reconfigure(config)
+		} else {
+			m.limiters[placementKey] = newPlacementLimiter(config)
+		}
+	case exists:
+		// Remove limiter if no limit set, admitting anything still queued on it
+		limiter.reconfigure(Config{})
+		delete(m.limiters, placementKey)
+	}
+}
+
+// Placements returns the placements with a limit or rate limit configuration, sorted
+func (m *Manager) Placements() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	placements := make([]string, 0, len(m.config)+len(m.rateLimits))
+	for placementKey := range m.config {
+		placements = append(placements, placementKey)
+	}
+	for placementKey := range m.rateLimits {
+		if _, exists := m.config[placementKey]; !exists {
+			placements = append(placements, placementKey)
+		}
 	}
+	sort.Strings(placements)
+	return placements
+}
+
+// RecordOutcome feeds an upstream response into a placement's adaptive limit
+func (m *Manager) RecordOutcome(placementKey string, latency time.Duration, failed bool) {
+	m.mu.RLock()
+	adaptive, isAdaptive := m.adaptive[placementKey]
+	limiter, exists := m.limiters[placementKey]
+	m.mu.RUnlock()
+
+	if !isAdaptive || !exists {
+		return
+	}
+
+	inFlight, oldLimit, _ := limiter.stats()
+	newLimit := adaptive.sample(latency, inFlight, failed)
+	if newLimit != oldLimit {
+		limiter.setCapacity(newLimit)
+	}
+}
+
+// Stats returns the concurrency state of every placement with a limit, sorted by placement
+func (m *Manager) Stats() []PlacementStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make([]PlacementStats, 0, len(m.limiters))
+	for placementKey, limiter := range m.limiters {
+		inFlight, capacity, queued := limiter.stats()
+		s := PlacementStats{
+			Placement: placementKey,
+			InFlight:  inFlight,
+			Limit:     capacity,
+			Queued:    queued,
+		}
+		if adaptive, exists := m.adaptive[placementKey]; exists {
+			s.Adaptive = true
+			s.MinLimit = adaptive.config.MinLimit
+			s.MaxLimit = adaptive.config.MaxLimit
+		}
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Placement < stats[j].Placement
+	})
+	return stats
 }
 
 // GetConfig returns the limit configuration for a placement
@@ -94,39 +212,95 @@ func (m *Manager) GetConfig(placementKey string) (Config, bool) {
 	return config, exists
 }
 
-// TryAcquire attempts to acquire a concurrency slot for a placement
-// Returns true if acquired, false if at limit
-func (m *Manager) TryAcquire(placementKey string) bool {
+// TryAcquire attempts to acquire a concurrency slot for a routing key on a placement
+// Returns nil if acquired, or the limit that rejected the request
+func (m *Manager) TryAcquire(placementKey, routingKey string) error {
 	m.mu.RLock()
-	sem, exists := m.semaphores[placementKey]
+	limiter, exists := m.limiters[placementKey]
 	m.mu.RUnlock()
 
 	if !exists {
 		// No limit configured, allow request
-		return true
+		return nil
 	}
 
-	acquired := sem.TryAcquire()
-	if !acquired {
-		m.logger.LogInfo("concurrency limit reached", map[string]interface{}{
-			"placement": placementKey,
-			"action":    "rejected",
-		})
+	err := limiter.tryAcquire(routingKey)
+	if err != nil {
+		m.logRejection(placementKey, routingKey, err)
 	}
-	return acquired
+	return err
 }
 
-// Release releases a concurrency slot for a placement
-func (m *Manager) Release(placementKey string) {
+// Acquire acquires a concurrency slot for a routing key on a placement, waiting in the
+// placement's queue if one is configured. The wait is bounded by the queue's max wait
+// and the context deadline. Returns how long the request was queued.
+func (m *Manager) Acquire(ctx context.Context, placementKey, routingKey string) (time.Duration, error) {
 	m.mu.RLock()
-	sem, exists := m.semaphores[placementKey]
+	limiter, exists := m.limiters[placementKey]
+	m.mu.RUnlock()
+
+	if !exists {
+		// No limit configured, allow request
+		return 0, nil
+	}
+
+	queueTime, err := limiter.acquire(ctx, routingKey)
+	if err != nil {
+		m.logRejection(placementKey, routingKey, err)
+	}
+	return queueTime, err
+}
+
+// logRejection logs a concurrency limit rejection identifying the tenant
+func (m *Manager) logRejection(placementKey, routingKey string, err error) {
+	m.logger.LogInfo("concurrency limit reached", map[string]interface{}{
+		"placement":   placementKey,
+		"routing_key": routingKey,
+		"reason":      err.Error(),
+		"action":      "rejected",
+	})
+}
+
+// Release releases a concurrency slot held by a routing key on a placement
+func (m *Manager) Release(placementKey, routingKey string) {
+	m.mu.RLock()
+	limiter, exists := m.limiters[placementKey]
 	m.mu.RUnlock()
 
 	if exists {
-		sem.Release()
+		limiter.release(routingKey)
 	}
 }
 
+// InFlight returns in-flight requests and the concurrency ceiling for a placement
+// Returns false if no concurrency limit is configured
+func (m *Manager) InFlight(placementKey string) (int, int, bool) {
+	m.mu.RLock()
+	limiter, exists := m.limiters[placementKey]
+	m.mu.RUnlock()
+
+	if !exists {
+		return 0, 0, false
+	}
+
+	inFlight, capacity, _ := limiter.stats()
+	return inFlight, capacity, true
+}
+
+// QueueLength returns the number of requests waiting for a slot on a placement
+func (m *Manager) QueueLength(placementKey string) int {
+	m.mu.RLock()
+	limiter, exists := m.limiters[placementKey]
+	m.mu.RUnlock()
+
+	if !exists {
+		return 0
+	}
+
+	_, _, queued := limiter.stats()
+	return queued
+}
+
 // ValidateRequestBodySize checks if request body size is within limits
 func (m *Manager) ValidateRequestBodySize(placementKey string, size int64) error {
 	config, exists := m.GetConfig(placementKey)
@@ -141,10 +315,86 @@ func (m *Manager) ValidateRequestBodySize(placementKey string, size int64) error
 	return nil
 }
 
+// SetRateLimit sets the token-bucket rate limit for a placement
+func (m *Manager) SetRateLimit(placementKey string, config RateLimitConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rateLimits[placementKey] = config
+}
+
+// RemoveRateLimit removes the rate limit for a placement
+func (m *Manager) RemoveRateLimit(placementKey string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rateLimits, placementKey)
+}
+
+// SetMaxRateLimitKeys bounds the current backend to maxKeys buckets, keeping existing bucket state
+// A backend that cannot be resized is replaced with a local one
+func (m *Manager) SetMaxRateLimitKeys(maxKeys int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if backend, ok := m.backend.(resizableBackend); ok {
+		backend.SetMaxKeys(maxKeys)
+		return
+	}
+	m.backend = NewLocalBackend(maxKeys)
+}
+
+// SetRateLimitBackend sets the backend that stores token buckets
+func (m *Manager) SetRateLimitBackend(backend RateLimitBackend) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.backend = backend
+}
+
+// AllowRate consumes a token for a request to a placement
+// Returns a result with Limited=false if no rate limit is configured
+func (m *Manager) AllowRate(placementKey, routingKey string) RateLimitResult {
+	m.mu.RLock()
+	config, exists := m.rateLimits[placementKey]
+	backend := m.backend
+	m.mu.RUnlock()
+
+	if !exists {
+		// No rate limit configured, allow request
+		return RateLimitResult{Allowed: true}
+	}
+
+	return backend.Take(config.bucketKey(placementKey, routingKey), config.ruleFor(routingKey))
+}
+
+// LimitRequestBody wraps a request body in the placement's body size limit
+// Returns nil if no body limit is configured
+func (m *Manager) LimitRequestBody(placementKey string, body io.ReadCloser) *BodyLimiter {
+	config, exists := m.GetConfig(placementKey)
+	if !exists || config.MaxRequestBodyBytes == 0 || body == nil || body == http.NoBody {
+		return nil
+	}
+	return NewBodyLimiter(body, config.MaxRequestBodyBytes)
+}
+
+// RecordBodyLimitAbort counts a request aborted mid-stream for exceeding its body limit
+// Returns the total number of such aborts
+func (m *Manager) RecordBodyLimitAbort() int64 {
+	return m.bodyLimitAborts.Add(1)
+}
+
+// BodyLimitAborts returns the number of requests aborted mid-stream for exceeding their body limit
+func (m *Manager) BodyLimitAborts() int64 {
+	return m.bodyLimitAborts.Load()
+}
+
 // RemoveConfig removes limit configuration for a placement
 func (m *Manager) RemoveConfig(placementKey string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if limiter, exists := m.limiters[placementKey]; exists {
+		limiter.reconfigure(Config{})
+	}
 	delete(m.config, placementKey)
-	delete(m.semaphores, placementKey)
+	delete(m.limiters, placementKey)
+	delete(m.adaptive, placementKey)
+	delete(m.rateLimits, placementKey)
 }
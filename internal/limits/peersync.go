@@ -0,0 +1,436 @@
+package limits
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+)
+
+// PeerSyncPath is the HTTP path on which routers exchange rate limit consumption
+const PeerSyncPath = "/internal/ratelimit/sync"
+
+// PeerSignatureHeader carries a sync request's timestamp and HMAC, signed with the shared peer secret
+// Format: unix time "." base64url(HMAC-SHA256(secret, unix time "." body))
+const PeerSignatureHeader = "X-Peer-Signature"
+
+// maxPeerClockSkew bounds how old (or far in the future) a signed sync request may be
+const maxPeerClockSkew = 30 * time.Second
+
+// maxPeerSyncBody bounds the size of a sync request body
+const maxPeerSyncBody = 4 << 20
+
+// FailurePolicy decides how rate limits are enforced when peers are unreachable
+type FailurePolicy string
+
+const (
+	// FailOpen falls back to local-only enforcement when peers are unreachable
+	FailOpen FailurePolicy = "open"
+	// FailClosed rejects rate-limited requests while any peer is unreachable
+	FailClosed FailurePolicy = "closed"
+)
+
+// PeerConfig configures a PeerBackend
+type PeerConfig struct {
+	ID            string        // Identifies this router in sync messages
+	Peers         []string      // Base URLs of the other routers
+	SyncInterval  time.Duration // How often local consumption is pushed to peers
+	PeerTimeout   time.Duration // How long a peer may go without a successful sync before it is unreachable
+	FailurePolicy FailurePolicy
+	MaxKeys       int    // Bound on tracked token buckets
+	Secret        []byte // Shared by all peers; sync requests without a valid signature are refused
+}
+
+// peerDelta reports tokens consumed on one router since its last sync
+type peerDelta struct {
+	Key               string  `json:"key"`
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+	Count             int     `json:"count"`
+}
+
+// peerSyncMessage is the body of a peer sync request
+// Seq increases with every message a router sends, so a captured request cannot be applied twice
+type peerSyncMessage struct {
+	From   string      `json:"from"`
+	Seq    uint64      `json:"seq"`
+	Deltas []peerDelta `json:"deltas"`
+}
+
+// pendingDelta accumulates local consumption for one key between syncs
+type pendingDelta struct {
+	rule  RateLimitRule
+	count int
+	last  time.Time // Latest consumption counted
+}
+
+// refilled reports whether the bucket would have refilled completely since the latest consumption,
+// in which case draining it on a peer no longer reflects current traffic
+func (d *pendingDelta) refilled(now time.Time) bool {
+	if d.rule.RequestsPerSecond <= 0 {
+		return false
+	}
+	return now.Sub(d.last).Seconds() >= float64(d.rule.Burst)/d.rule.RequestsPerSecond
+}
+
+// PeerBackend shares token bucket consumption between router replicas over HTTP.
+// Each router enforces limits from its own buckets and periodically pushes the
+// tokens it consumed to every peer, which drain them from their copies. Buckets
+// converge on fleet-wide consumption within one sync interval.
+type PeerBackend struct {
+	config   PeerConfig
+	buckets  *bucketStore
+	client   *http.Client
+	logger   *logging.Logger
+	pending  map[string]map[string]*pendingDelta // Unsent consumption per peer, by bucket key
+	lastSync map[string]time.Time                // Last successful sync per peer
+	maxKeys  int                                 // Bound on pending keys per peer
+	peerSeq  map[string]uint64                   // Highest sequence number applied per sending router
+	seq      atomic.Uint64                       // Sequence number of the last message sent
+	mu       sync.Mutex
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewPeerBackend creates a backend that syncs consumption with the configured peers
+func NewPeerBackend(config PeerConfig, logger *logging.Logger) *PeerBackend {
+	if config.SyncInterval <= 0 {
+		config.SyncInterval = 200 * time.Millisecond
+	}
+	if config.PeerTimeout <= 0 {
+		config.PeerTimeout = 5 * config.SyncInterval
+	}
+	if config.FailurePolicy == "" {
+		config.FailurePolicy = FailOpen
+	}
+	if config.MaxKeys <= 0 {
+		config.MaxKeys = DefaultMaxRateLimitKeys
+	}
+
+	// Peers start out reachable so a fresh fleet doesn't fail closed
+	now := time.Now()
+	lastSync := make(map[string]time.Time, len(config.Peers))
+	pending := make(map[string]map[string]*pendingDelta, len(config.Peers))
+	for _, peer := range config.Peers {
+		lastSync[peer] = now
+		pending[peer] = make(map[string]*pendingDelta)
+	}
+
+	b := &PeerBackend{
+		config:   config,
+		buckets:  newBucketStore(config.MaxKeys),
+		client:   &http.Client{Timeout: config.SyncInterval},
+		logger:   logger,
+		pending:  pending,
+		lastSync: lastSync,
+		maxKeys:  config.MaxKeys,
+		peerSeq:  make(map[string]uint64),
+		stopCh:   make(chan struct{}),
+	}
+	// Start from the clock so sequence numbers keep increasing across restarts
+	b.seq.Store(uint64(now.UnixNano()))
+	return b
+}
+
+// Start begins pushing local consumption to peers
+func (b *PeerBackend) Start() {
+	b.wg.Add(1)
+	go b.syncLoop()
+}
+
+// Stop stops the sync loop
+func (b *PeerBackend) Stop() {
+	close(b.stopCh)
+	b.wg.Wait()
+}
+
+// Take consumes one token from the bucket for key
+func (b *PeerBackend) Take(key string, rule RateLimitRule) RateLimitResult {
+	if b.config.FailurePolicy == FailClosed && b.unreachablePeers() > 0 {
+		return RateLimitResult{
+			Limited:    true,
+			Limit:      rule.Burst,
+			RetryAfter: b.config.SyncInterval,
+		}
+	}
+
+	now := time.Now()
+	result := b.buckets.take(key, rule, now)
+	if result.Allowed {
+		b.mu.Lock()
+		for _, deltas := range b.pending {
+			addDelta(deltas, key, rule, 1, now, b.maxKeys)
+		}
+		b.mu.Unlock()
+	}
+	return result
+}
+
+// addDelta adds count tokens consumed for key at last to a pending set holding at most maxKeys keys
+// Returns false if the set is full and the consumption was dropped
+func addDelta(deltas map[string]*pendingDelta, key string, rule RateLimitRule, count int, last time.Time, maxKeys int) bool {
+	delta, exists := deltas[key]
+	if !exists {
+		if len(deltas) >= maxKeys {
+			return false
+		}
+		delta = &pendingDelta{}
+		deltas[key] = delta
+	}
+	delta.rule = rule
+	delta.count += count
+	if last.After(delta.last) {
+		delta.last = last
+	}
+	return true
+}
+
+// SetMaxKeys changes the number of buckets tracked, keeping the most recently used ones
+// It also bounds the consumption kept pending for each peer
+func (b *PeerBackend) SetMaxKeys(maxKeys int) {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxRateLimitKeys
+	}
+	b.buckets.resize(maxKeys)
+
+	b.mu.Lock()
+	b.maxKeys = maxKeys
+	b.mu.Unlock()
+}
+
+// ServeHTTP applies consumption reported by a peer
+func (b *PeerBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPeerSyncBody))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if err := b.verify(r.Header.Get(PeerSignatureHeader), body, time.Now()); err != nil {
+		b.logger.LogError("rejected rate limit peer sync", err, map[string]interface{}{
+			"remote_addr": r.RemoteAddr,
+		})
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var msg peerSyncMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !b.acceptSeq(msg.From, msg.Seq) {
+		b.logger.LogError("rejected rate limit peer sync", fmt.Errorf("replayed sequence number %d", msg.Seq), map[string]interface{}{
+			"remote_addr": r.RemoteAddr,
+			"from":        msg.From,
+		})
+		http.Error(w, "Conflict", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	for _, delta := range msg.Deltas {
+		rule := RateLimitRule{RequestsPerSecond: delta.RequestsPerSecond, Burst: delta.Burst}
+		b.buckets.drain(delta.Key, rule, delta.Count, now)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// acceptSeq records a sync message's sequence number, returning false if one as high was already applied from its sender
+func (b *PeerBackend) acceptSeq(from string, seq uint64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if seq <= b.peerSeq[from] {
+		return false
+	}
+	b.peerSeq[from] = seq
+	return true
+}
+
+// unreachablePeers returns how many peers have not synced within PeerTimeout
+func (b *PeerBackend) unreachablePeers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	unreachable := 0
+	for _, last := range b.lastSync {
+		if time.Since(last) > b.config.PeerTimeout {
+			unreachable++
+		}
+	}
+	return unreachable
+}
+
+// syncLoop periodically pushes local consumption to peers
+func (b *PeerBackend) syncLoop() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.syncOnce()
+		case <-b.stopCh:
+			return
+		}
+	}
+}
+
+// syncOnce sends consumption accumulated since the last sync to every peer
+// Consumption a peer did not receive stays pending for it and is retried on the next sync
+func (b *PeerBackend) syncOnce() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = make(map[string]map[string]*pendingDelta, len(pending))
+	for peer := range pending {
+		b.pending[peer] = make(map[string]*pendingDelta)
+	}
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for peer, deltas := range pending {
+		wg.Add(1)
+		go func(peer string, deltas map[string]*pendingDelta) {
+			defer wg.Done()
+			if err := b.push(peer, deltas); err != nil {
+				b.logger.LogError("rate limit peer sync failed", err, map[string]interface{}{
+					"peer":           peer,
+					"failure_policy": b.config.FailurePolicy,
+				})
+				b.requeue(peer, deltas)
+				return
+			}
+
+			b.mu.Lock()
+			b.lastSync[peer] = time.Now()
+			b.mu.Unlock()
+		}(peer, deltas)
+	}
+	wg.Wait()
+}
+
+// requeue puts deltas that could not be sent back into a peer's pending set
+// Deltas whose buckets have refilled since are dropped, as is whatever exceeds the max keys bound,
+// so a long outage neither grows the backlog without bound nor drains buckets for old traffic
+func (b *PeerBackend) requeue(peer string, deltas map[string]*pendingDelta) {
+	now := time.Now()
+	stale, dropped := 0, 0
+
+	b.mu.Lock()
+	for key, delta := range deltas {
+		if delta.refilled(now) {
+			stale++
+			continue
+		}
+		if !addDelta(b.pending[peer], key, delta.rule, delta.count, delta.last, b.maxKeys) {
+			dropped++
+		}
+	}
+	b.mu.Unlock()
+
+	if stale > 0 || dropped > 0 {
+		b.logger.LogInfo("dropped pending rate limit consumption", map[string]interface{}{
+			"peer":          peer,
+			"refilled":      stale,
+			"over_max_keys": dropped,
+		})
+	}
+}
+
+// push sends pending consumption to one peer
+func (b *PeerBackend) push(peer string, deltas map[string]*pendingDelta) error {
+	msg := peerSyncMessage{
+		From:   b.config.ID,
+		Seq:    b.seq.Add(1),
+		Deltas: make([]peerDelta, 0, len(deltas)),
+	}
+	for key, delta := range deltas {
+		msg.Deltas = append(msg.Deltas, peerDelta{
+			Key:               key,
+			RequestsPerSecond: delta.rule.RequestsPerSecond,
+			Burst:             delta.rule.Burst,
+			Count:             delta.count,
+		})
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync message: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(peer, "/")+PeerSyncPath, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(PeerSignatureHeader, b.sign(data, time.Now()))
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sign returns the PeerSignatureHeader value for a sync request body
+func (b *PeerBackend) sign(body []byte, now time.Time) string {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	return timestamp + "." + base64.RawURLEncoding.EncodeToString(peerMAC(b.config.Secret, timestamp, body))
+}
+
+// verify checks a PeerSignatureHeader value against the request body and the current time
+func (b *PeerBackend) verify(signature string, body []byte, now time.Time) error {
+	if len(b.config.Secret) == 0 {
+		return fmt.Errorf("no peer secret configured")
+	}
+
+	timestamp, encoded, found := strings.Cut(signature, ".")
+	if !found {
+		return fmt.Errorf("missing or malformed signature")
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal(mac, peerMAC(b.config.Secret, timestamp, body)) {
+		return fmt.Errorf("bad signature")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed timestamp")
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > maxPeerClockSkew || skew < -maxPeerClockSkew {
+		return fmt.Errorf("signature timestamp outside the allowed clock skew")
+	}
+	return nil
+}
+
+// peerMAC computes the signature of a sync request body
+func peerMAC(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
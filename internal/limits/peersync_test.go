@@ -0,0 +1,200 @@
+package limits
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+)
+
+// startPeers creates n peer backends, each served by its own HTTP server
+func startPeers(t *testing.T, n int, policy FailurePolicy) []*PeerBackend {
+	t.Helper()
+
+	handlers := make([]http.Handler, n)
+	urls := make([]string, n)
+	for i := 0; i < n; i++ {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+
+	backends := make([]*PeerBackend, n)
+	for i := 0; i < n; i++ {
+		var peers []string
+		for j, url := range urls {
+			if j != i {
+				peers = append(peers, url)
+			}
+		}
+		backends[i] = NewPeerBackend(PeerConfig{
+			ID:            urls[i],
+			Peers:         peers,
+			SyncInterval:  time.Hour, // Synced manually via syncOnce
+			PeerTimeout:   time.Hour,
+			FailurePolicy: policy,
+			Secret:        []byte("peer-secret"),
+		}, logging.NewLogger())
+		handlers[i] = backends[i]
+	}
+	return backends
+}
+
+func TestPeerBackend_SharesConsumption(t *testing.T) {
+	routers := startPeers(t, 3, FailOpen)
+	rule := RateLimitRule{RequestsPerSecond: 0.001, Burst: 4}
+
+	// Router 0 consumes the whole burst locally
+	for i := 0; i < 4; i++ {
+		if result := routers[0].Take("acme", rule); !result.Allowed {
+			t.Fatalf("Request %d on router 0 rejected, want allowed", i)
+		}
+	}
+
+	routers[0].syncOnce()
+
+	for i, router := range routers[1:] {
+		if result := router.Take("acme", rule); result.Allowed {
+			t.Errorf("Router %d allowed request after peer exhausted the bucket", i+1)
+		}
+	}
+}
+
+func TestPeerBackend_FailurePolicy(t *testing.T) {
+	rule := RateLimitRule{RequestsPerSecond: 1, Burst: 10}
+
+	for _, tt := range []struct {
+		policy      FailurePolicy
+		wantAllowed bool
+	}{
+		{FailOpen, true},
+		{FailClosed, false},
+	} {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			unreachable := httptest.NewServer(http.NotFoundHandler())
+			unreachable.Close()
+
+			backend := NewPeerBackend(PeerConfig{
+				Peers:         []string{unreachable.URL},
+				SyncInterval:  10 * time.Millisecond,
+				PeerTimeout:   20 * time.Millisecond,
+				FailurePolicy: tt.policy,
+			}, logging.NewLogger())
+
+			time.Sleep(30 * time.Millisecond)
+			backend.syncOnce()
+
+			if result := backend.Take("acme", rule); result.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v", result.Allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestPeerBackend_RetriesFailedSync(t *testing.T) {
+	var receiver *PeerBackend
+	up := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		receiver.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	receiver = NewPeerBackend(PeerConfig{SyncInterval: time.Hour, Secret: []byte("peer-secret")}, logging.NewLogger())
+	sender := NewPeerBackend(PeerConfig{
+		Peers:        []string{server.URL},
+		SyncInterval: time.Hour,
+		PeerTimeout:  time.Hour,
+		Secret:       []byte("peer-secret"),
+	}, logging.NewLogger())
+	rule := RateLimitRule{RequestsPerSecond: 0.001, Burst: 2}
+
+	sender.Take("acme", rule)
+	sender.syncOnce() // Fails; the delta stays pending
+	sender.Take("acme", rule)
+	up = true
+	sender.syncOnce()
+
+	if result := receiver.Take("acme", rule); result.Allowed {
+		t.Error("Receiver allowed request, want both tokens drained after the retried sync")
+	}
+}
+
+func TestPeerBackend_RequiresSignature(t *testing.T) {
+	backend := NewPeerBackend(PeerConfig{SyncInterval: time.Hour, Secret: []byte("peer-secret")}, logging.NewLogger())
+	forger := NewPeerBackend(PeerConfig{SyncInterval: time.Hour, Secret: []byte("wrong-secret")}, logging.NewLogger())
+	body := `{"from":"router-2","seq":1,"deltas":[{"key":"acme","requests_per_second":0.001,"burst":1,"count":1}]}`
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		signature  string
+		wantStatus int
+	}{
+		{name: "missing", signature: "", wantStatus: http.StatusUnauthorized},
+		{name: "wrong secret", signature: forger.sign([]byte(body), now), wantStatus: http.StatusUnauthorized},
+		{name: "stale", signature: backend.sign([]byte(body), now.Add(-time.Minute)), wantStatus: http.StatusUnauthorized},
+		{name: "valid", signature: backend.sign([]byte(body), now), wantStatus: http.StatusNoContent},
+		{name: "replayed", signature: backend.sign([]byte(body), now), wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, PeerSyncPath, strings.NewReader(body))
+			req.Header.Set(PeerSignatureHeader, tt.signature)
+			rec := httptest.NewRecorder()
+			backend.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestPeerBackend_BoundsPendingDeltas(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	backend := NewPeerBackend(PeerConfig{
+		Peers:        []string{unreachable.URL},
+		SyncInterval: time.Hour,
+		PeerTimeout:  time.Hour,
+		MaxKeys:      2,
+		Secret:       []byte("peer-secret"),
+	}, logging.NewLogger())
+	pendingKeys := func() int {
+		backend.mu.Lock()
+		defer backend.mu.Unlock()
+		return len(backend.pending[unreachable.URL])
+	}
+
+	// Keys beyond the bound are not kept pending
+	slow := RateLimitRule{RequestsPerSecond: 0.001, Burst: 10}
+	for _, key := range []string{"acme", "globex", "initech"} {
+		backend.Take(key, slow)
+	}
+	backend.syncOnce()
+	if keys := pendingKeys(); keys != 2 {
+		t.Errorf("pending keys = %d, want 2", keys)
+	}
+
+	// Consumption whose bucket has refilled since is dropped instead of replayed
+	backend.SetMaxKeys(10)
+	fast := RateLimitRule{RequestsPerSecond: 1000, Burst: 1}
+	backend.Take("umbrella", fast)
+	time.Sleep(5 * time.Millisecond)
+	backend.syncOnce()
+	backend.mu.Lock()
+	_, kept := backend.pending[unreachable.URL]["umbrella"]
+	backend.mu.Unlock()
+	if kept || pendingKeys() != 2 {
+		t.Errorf("umbrella still pending (%v) with %d keys, want only the 2 slow keys", kept, pendingKeys())
+	}
+}
@@ -0,0 +1,197 @@
+package limits
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultMaxRateLimitKeys bounds the number of token buckets kept in memory
+const DefaultMaxRateLimitKeys = 10000
+
+// RateLimitKey selects which request attributes a token bucket is keyed by
+type RateLimitKey string
+
+const (
+	// RateLimitByRoutingKey shares one bucket per tenant across placements
+	RateLimitByRoutingKey RateLimitKey = "routing_key"
+	// RateLimitByPlacement shares one bucket across all tenants of a placement
+	RateLimitByPlacement RateLimitKey = "placement"
+	// RateLimitByRoutingKeyAndPlacement keeps one bucket per tenant per placement
+	RateLimitByRoutingKeyAndPlacement RateLimitKey = "routing_key_placement"
+)
+
+// RateLimitRule defines a token bucket refill rate and size
+type RateLimitRule struct {
+	RequestsPerSecond float64 // Refill rate
+	Burst             int     // Bucket size
+}
+
+// RateLimitConfig defines token-bucket rate limiting for a placement
+type RateLimitConfig struct {
+	Key       RateLimitKey             // Bucket keying
+	Rule      RateLimitRule            // Default rule
+	Overrides map[string]RateLimitRule // Per routing key overrides
+}
+
+// ruleFor returns the rule that applies to a routing key
+func (c RateLimitConfig) ruleFor(routingKey string) RateLimitRule {
+	if rule, exists := c.Overrides[routingKey]; exists {
+		return rule
+	}
+	return c.Rule
+}
+
+// bucketKey returns the bucket identity for a request
+func (c RateLimitConfig) bucketKey(placementKey, routingKey string) string {
+	switch c.Key {
+	case RateLimitByPlacement:
+		return "placement:" + placementKey
+	case RateLimitByRoutingKeyAndPlacement:
+		return "routing_key:" + routingKey + "|placement:" + placementKey
+	default:
+		return "routing_key:" + routingKey
+	}
+}
+
+// RateLimitResult describes the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool          // Whether the request may proceed
+	Limited    bool          // Whether a rate limit applies at all
+	Limit      int           // Bucket size
+	Remaining  int           // Whole tokens left after this request
+	Reset      time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // Time until the next token is available (when rejected)
+}
+
+// tokenBucket tracks available tokens for one key
+type tokenBucket struct {
+	key        string
+	tokens     float64
+	lastRefill time.Time
+}
+
+// refill adds tokens accrued since the last refill
+func (b *tokenBucket) refill(rule RateLimitRule, now time.Time) {
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.RequestsPerSecond)
+		b.lastRefill = now
+	}
+}
+
+// take refills the bucket and tries to consume one token
+func (b *tokenBucket) take(rule RateLimitRule, now time.Time) RateLimitResult {
+	b.refill(rule, now)
+
+	result := RateLimitResult{
+		Limited: true,
+		Limit:   rule.Burst,
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rule.RequestsPerSecond)
+	}
+
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = secondsToDuration((float64(rule.Burst) - b.tokens) / rule.RequestsPerSecond)
+	return result
+}
+
+// bucketStore is a memory-bounded set of token buckets with LRU eviction
+type bucketStore struct {
+	maxKeys int
+	buckets map[string]*list.Element
+	lru     *list.List // front = most recently used
+	mu      sync.Mutex
+}
+
+// newBucketStore creates a bucket store holding at most maxKeys buckets
+func newBucketStore(maxKeys int) *bucketStore {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxRateLimitKeys
+	}
+	return &bucketStore{
+		maxKeys: maxKeys,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// take consumes one token from the bucket for key, creating it full if absent
+func (s *bucketStore) take(key string, rule RateLimitRule, now time.Time) RateLimitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bucket(key, rule, now).take(rule, now)
+}
+
+// drain removes count tokens from the bucket for key without going below zero
+func (s *bucketStore) drain(key string, rule RateLimitRule, count int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.bucket(key, rule, now)
+	b.refill(rule, now)
+	b.tokens = math.Max(0, b.tokens-float64(count))
+}
+
+// bucket returns the bucket for key and marks it as recently used (must be called with lock held)
+func (s *bucketStore) bucket(key string, rule RateLimitRule, now time.Time) *tokenBucket {
+	if elem, exists := s.buckets[key]; exists {
+		s.lru.MoveToFront(elem)
+		return elem.Value.(*tokenBucket)
+	}
+
+	// Evict least recently used buckets; idle buckets have usually refilled,
+	// so replacing one with a fresh full bucket loses little state
+	for len(s.buckets) >= s.maxKeys {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.buckets, oldest.Value.(*tokenBucket).key)
+	}
+
+	b := &tokenBucket{
+		key:        key,
+		tokens:     float64(rule.Burst),
+		lastRefill: now,
+	}
+	s.buckets[key] = s.lru.PushFront(b)
+	return b
+}
+
+// resize changes the bucket bound, evicting least recently used buckets that no longer fit
+func (s *bucketStore) resize(maxKeys int) {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxRateLimitKeys
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxKeys = maxKeys
+	for len(s.buckets) > s.maxKeys {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.buckets, oldest.Value.(*tokenBucket).key)
+	}
+}
+
+// len returns the number of tracked buckets
+func (s *bucketStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// secondsToDuration converts fractional seconds to a time.Duration
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,112 @@
+package limits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+)
+
+func TestTokenBucket_RefillsOverTime(t *testing.T) {
+	store := newBucketStore(10)
+	rule := RateLimitRule{RequestsPerSecond: 10, Burst: 2}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if result := store.take("acme", rule, now); !result.Allowed {
+			t.Fatalf("Request %d rejected, want allowed within burst", i)
+		}
+	}
+
+	result := store.take("acme", rule, now)
+	if result.Allowed {
+		t.Fatal("Request beyond burst allowed, want rejected")
+	}
+	if result.RetryAfter != 100*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want 100ms", result.RetryAfter)
+	}
+
+	if result := store.take("acme", rule, now.Add(100*time.Millisecond)); !result.Allowed {
+		t.Error("Request after refill rejected, want allowed")
+	}
+}
+
+func TestBucketStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := newBucketStore(2)
+	rule := RateLimitRule{RequestsPerSecond: 1, Burst: 1}
+	now := time.Now()
+
+	store.take("a", rule, now)
+	store.take("b", rule, now)
+	store.take("a", rule, now) // a is now most recently used
+	store.take("c", rule, now) // evicts b
+
+	if n := store.len(); n != 2 {
+		t.Fatalf("len = %d, want 2", n)
+	}
+	if _, exists := store.buckets["b"]; exists {
+		t.Error("Bucket b still tracked, want evicted")
+	}
+	if _, exists := store.buckets["a"]; !exists {
+		t.Error("Bucket a evicted, want kept")
+	}
+}
+
+func TestManager_AllowRate(t *testing.T) {
+	m := NewManager(logging.NewLogger())
+	m.SetRateLimit("tier1", RateLimitConfig{
+		Key:  RateLimitByRoutingKey,
+		Rule: RateLimitRule{RequestsPerSecond: 1, Burst: 1},
+		Overrides: map[string]RateLimitRule{
+			"acme": {RequestsPerSecond: 1, Burst: 3},
+		},
+	})
+
+	if result := m.AllowRate("tier2", "globex"); !result.Allowed || result.Limited {
+		t.Errorf("Unlimited placement: got %+v, want allowed and not limited", result)
+	}
+
+	m.AllowRate("tier1", "globex")
+	if result := m.AllowRate("tier1", "globex"); result.Allowed {
+		t.Error("globex second request allowed, want rejected")
+	}
+
+	for i := 0; i < 3; i++ {
+		if result := m.AllowRate("tier1", "acme"); !result.Allowed {
+			t.Fatalf("acme request %d rejected, want allowed by override", i)
+		}
+	}
+}
+
+func TestManager_AllowRate_ByPlacement(t *testing.T) {
+	m := NewManager(logging.NewLogger())
+	m.SetRateLimit("tier1", RateLimitConfig{
+		Key:  RateLimitByPlacement,
+		Rule: RateLimitRule{RequestsPerSecond: 1, Burst: 1},
+	})
+
+	m.AllowRate("tier1", "acme")
+	if result := m.AllowRate("tier1", "globex"); result.Allowed {
+		t.Error("Second tenant allowed, want shared placement bucket exhausted")
+	}
+}
+
+func TestManager_SetMaxRateLimitKeysKeepsBuckets(t *testing.T) {
+	m := NewManager(logging.NewLogger())
+	m.SetRateLimit("tier1", RateLimitConfig{
+		Key:  RateLimitByRoutingKey,
+		Rule: RateLimitRule{RequestsPerSecond: 1, Burst: 1},
+	})
+
+	m.AllowRate("tier1", "acme")
+	m.AllowRate("tier1", "globex")
+	m.SetMaxRateLimitKeys(1)
+
+	// globex was used last, so it survives the shrink with its bucket still drained
+	if result := m.AllowRate("tier1", "globex"); result.Allowed {
+		t.Error("globex allowed after resize, want its drained bucket kept")
+	}
+	if n := m.backend.(*LocalBackend).buckets.len(); n != 1 {
+		t.Errorf("len = %d, want 1", n)
+	}
+}
@@ -31,6 +31,8 @@ type RequestLog struct {
 	UpstreamURL  string  `json:"upstream_url"`
 	StatusCode   int     `json:"status_code"`
 	DurationMs   float64 `json:"duration_ms"`
+	QueueTimeMs  float64 `json:"queue_time_ms,omitempty"`
+	TraceID      string  `json:"trace_id,omitempty"`
 }
 
 // LogRequest logs a completed request
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	return string(body)
+}
+
+func TestRegistry_TextFormat(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("requests_total", "Requests.", "placement", "code")
+	latency := reg.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "placement")
+	inFlight := reg.NewGaugeVec("in_flight", "In flight.", "placement")
+
+	requests.Inc("tier1", "200")
+	requests.Inc("tier1", "200")
+	requests.Inc("visa", "503")
+	latency.Observe(0.05, "tier1")
+	latency.Observe(0.5, "tier1")
+	latency.Observe(5, "tier1")
+	reg.OnScrape(func() { inFlight.Set(3, `quote"d`) })
+
+	out := scrape(t, reg)
+	for _, want := range []string{
+		"# TYPE requests_total counter",
+		`requests_total{placement="tier1",code="200"} 2`,
+		`requests_total{placement="visa",code="503"} 1`,
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{placement="tier1",le="0.1"} 1`,
+		`latency_seconds_bucket{placement="tier1",le="1"} 2`,
+		`latency_seconds_bucket{placement="tier1",le="+Inf"} 3`,
+		`latency_seconds_sum{placement="tier1"} 5.55`,
+		`latency_seconds_count{placement="tier1"} 3`,
+		`in_flight{placement="quote\"d"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q\n%s", want, out)
+		}
+	}
+
+	// Families are written in name order
+	if strings.Index(out, "in_flight") > strings.Index(out, "latency_seconds") {
+		t.Errorf("Families not sorted by name:\n%s", out)
+	}
+}
+
+func TestGaugeVec_Reset(t *testing.T) {
+	reg := NewRegistry()
+	gauge := reg.NewGaugeVec("healthy", "Healthy.", "placement")
+	gauge.Set(1, "tier1")
+	gauge.Reset()
+	gauge.Set(0, "tier2")
+
+	out := scrape(t, reg)
+	if strings.Contains(out, "tier1") {
+		t.Errorf("Reset series still present:\n%s", out)
+	}
+	if !strings.Contains(out, `healthy{placement="tier2"} 0`) {
+		t.Errorf("Output missing tier2 series:\n%s", out)
+	}
+}
+
+func TestRegistry_ConcurrentScrapes(t *testing.T) {
+	reg := NewRegistry()
+	gauge := reg.NewGaugeVec("healthy", "Healthy.", "placement")
+	reg.OnScrape(func() {
+		// Widen the windows in which another scrape could write a reset gauge
+		gauge.Reset()
+		time.Sleep(time.Millisecond)
+		gauge.Set(1, "tier1")
+		time.Sleep(time.Millisecond)
+	})
+
+	var wg sync.WaitGroup
+	outputs := make([]string, 20)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(i) * 250 * time.Microsecond) // Stagger so refreshes overlap writes
+			outputs[i] = scrape(t, reg)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, out := range outputs {
+		if !strings.Contains(out, `healthy{placement="tier1"} 1`) {
+			t.Errorf("Scrape missing the refreshed gauge:\n%s", out)
+		}
+	}
+}
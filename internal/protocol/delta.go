@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+// ErrBaseVersionMismatch is returned by ApplyConfigDelta when the delta was computed against another version
+var ErrBaseVersionMismatch = errors.New("delta base version mismatch")
+
+// NewConfigDelta computes the delta turning base into next (unsigned)
+// Returns false if the configs use different placement formats, which a delta cannot express
+func NewConfigDelta(base, next *config.Config) (ConfigDeltaMessage, bool) {
+	if (len(base.CellEndpoints) > 0) != (len(next.CellEndpoints) > 0) {
+		return ConfigDeltaMessage{}, false
+	}
+
+	delta := ConfigDeltaMessage{
+		Type:        MessageTypeConfigDelta,
+		BaseVersion: base.Version,
+		Version:     next.Version,
+	}
+
+	delta.RoutingTable, delta.RemovedRoutingKeys = diffMap(base.RoutingTable, next.RoutingTable, func(a, b string) bool { return a == b })
+	cellEndpoints, removedEndpoints := diffMap(base.CellEndpoints, next.CellEndpoints, func(a, b string) bool { return a == b })
+	placements, removedPlacements := diffMap(base.Placements, next.Placements, func(a, b *config.PlacementConfig) bool { return reflect.DeepEqual(a, b) })
+	delta.CellEndpoints = cellEndpoints
+	delta.Placements = placements
+	delta.RemovedPlacements = append(removedEndpoints, removedPlacements...)
+
+	if next.DefaultPlacement != base.DefaultPlacement {
+		delta.DefaultPlacement = next.DefaultPlacement
+	}
+	if next.RateLimitMaxKeys != base.RateLimitMaxKeys {
+		maxKeys := next.RateLimitMaxKeys
+		delta.RateLimitMaxKeys = &maxKeys
+	}
+	return delta, true
+}
+
+// ApplyConfigDelta returns a copy of base with the delta applied; base is not modified
+// Returns ErrBaseVersionMismatch if base is not the version the delta was computed against
+func ApplyConfigDelta(base *config.Config, delta ConfigDeltaMessage) (*config.Config, error) {
+	if base == nil || base.Version != delta.BaseVersion {
+		current := ""
+		if base != nil {
+			current = base.Version
+		}
+		return nil, fmt.Errorf("%w: delta is from %s, applied version is %s", ErrBaseVersionMismatch, delta.BaseVersion, current)
+	}
+
+	cfg, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Version = delta.Version
+
+	if cfg.RoutingTable == nil {
+		cfg.RoutingTable = make(map[string]string)
+	}
+	for key, placement := range delta.RoutingTable {
+		cfg.RoutingTable[key] = placement
+	}
+	for _, key := range delta.RemovedRoutingKeys {
+		delete(cfg.RoutingTable, key)
+	}
+
+	if len(delta.CellEndpoints) > 0 && cfg.CellEndpoints == nil {
+		cfg.CellEndpoints = make(map[string]string)
+	}
+	for key, url := range delta.CellEndpoints {
+		cfg.CellEndpoints[key] = url
+	}
+	if len(delta.Placements) > 0 && cfg.Placements == nil {
+		cfg.Placements = make(map[string]*config.PlacementConfig)
+	}
+	for key, placement := range delta.Placements {
+		cfg.Placements[key] = placement
+	}
+	for _, key := range delta.RemovedPlacements {
+		delete(cfg.CellEndpoints, key)
+		delete(cfg.Placements, key)
+	}
+
+	if delta.DefaultPlacement != "" {
+		cfg.DefaultPlacement = delta.DefaultPlacement
+	}
+	if delta.RateLimitMaxKeys != nil {
+		cfg.RateLimitMaxKeys = *delta.RateLimitMaxKeys
+	}
+	return cfg, nil
+}
+
+// diffMap returns entries of next that are new or differ from base, and sorted keys missing from next
+func diffMap[V any](base, next map[string]V, equal func(a, b V) bool) (map[string]V, []string) {
+	var changed map[string]V
+	for key, value := range next {
+		if old, exists := base[key]; !exists || !equal(old, value) {
+			if changed == nil {
+				changed = make(map[string]V)
+			}
+			changed[key] = value
+		}
+	}
+
+	var removed []string
+	for key := range base {
+		if _, exists := next[key]; !exists {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
@@ -0,0 +1,76 @@
+package protocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
+func TestConfigDelta_RoundTrip(t *testing.T) {
+	base := &config.Config{
+		Version:      "v1",
+		RoutingTable: map[string]string{"acme": "tier1", "globex": "tier1", "initech": "tier2"},
+		Placements: map[string]*config.PlacementConfig{
+			"tier1": {URL: "http://cell-tier1:9001"},
+			"tier2": {URL: "http://cell-tier2:9002"},
+			"tier3": {URL: "http://cell-tier3:9003"},
+		},
+		DefaultPlacement: "tier1",
+		RateLimitMaxKeys: 1000,
+	}
+	next := &config.Config{
+		Version:      "v2",
+		RoutingTable: map[string]string{"acme": "tier2", "initech": "tier2", "umbrella": "tier4"},
+		Placements: map[string]*config.PlacementConfig{
+			"tier1": {URL: "http://cell-tier1:9001"},
+			"tier2": {URL: "http://cell-tier2:9002", Fallback: "tier1"},
+			"tier4": {URL: "http://cell-tier4:9004"},
+		},
+		DefaultPlacement: "tier2",
+	}
+
+	delta, ok := NewConfigDelta(base, next)
+	if !ok {
+		t.Fatal("NewConfigDelta() not ok for configs in the same format")
+	}
+	if len(delta.RoutingTable) != 2 || !reflect.DeepEqual(delta.RemovedRoutingKeys, []string{"globex"}) {
+		t.Errorf("routing changes = %v, removed %v, want acme and umbrella set, globex removed", delta.RoutingTable, delta.RemovedRoutingKeys)
+	}
+	if len(delta.Placements) != 2 || !reflect.DeepEqual(delta.RemovedPlacements, []string{"tier3"}) {
+		t.Errorf("placement changes = %v, removed %v, want tier2 and tier4 set, tier3 removed", delta.Placements, delta.RemovedPlacements)
+	}
+
+	applied, err := ApplyConfigDelta(base, delta)
+	if err != nil {
+		t.Fatalf("ApplyConfigDelta failed: %v", err)
+	}
+	if !reflect.DeepEqual(applied, next) {
+		t.Errorf("ApplyConfigDelta() = %+v, want %+v", applied, next)
+	}
+	if base.Version != "v1" || len(base.RoutingTable) != 3 {
+		t.Errorf("ApplyConfigDelta modified its base: %+v", base)
+	}
+
+	if _, err := ApplyConfigDelta(next, delta); !errors.Is(err, ErrBaseVersionMismatch) {
+		t.Errorf("ApplyConfigDelta(wrong base) error = %v, want ErrBaseVersionMismatch", err)
+	}
+}
+
+func TestConfigDelta_FormatChange(t *testing.T) {
+	legacy := &config.Config{
+		Version:          "v1",
+		CellEndpoints:    map[string]string{"tier1": "http://cell-tier1:9001"},
+		DefaultPlacement: "tier1",
+	}
+	placements := &config.Config{
+		Version:          "v2",
+		Placements:       map[string]*config.PlacementConfig{"tier1": {URL: "http://cell-tier1:9001"}},
+		DefaultPlacement: "tier1",
+	}
+
+	if _, ok := NewConfigDelta(legacy, placements); ok {
+		t.Error("NewConfigDelta() ok across placement formats, want a full snapshot")
+	}
+}
@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultPingInterval is how often each side pings the other
+	DefaultPingInterval = 10 * time.Second
+	// DefaultPongTimeout is how long a side waits without hearing from its peer before dropping the connection
+	DefaultPongTimeout = 30 * time.Second
+)
+
+// HeartbeatFromEnv returns the ping interval and silence timeout from HEARTBEAT_INTERVAL and HEARTBEAT_TIMEOUT
+// Both sides read the same variables, defaulting to DefaultPingInterval and DefaultPongTimeout
+func HeartbeatFromEnv() (interval, timeout time.Duration, err error) {
+	interval, timeout = DefaultPingInterval, DefaultPongTimeout
+	if value := os.Getenv("HEARTBEAT_INTERVAL"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil {
+			return 0, 0, fmt.Errorf("HEARTBEAT_INTERVAL: %w", err)
+		}
+	}
+	if value := os.Getenv("HEARTBEAT_TIMEOUT"); value != "" {
+		if timeout, err = time.ParseDuration(value); err != nil {
+			return 0, 0, fmt.Errorf("HEARTBEAT_TIMEOUT: %w", err)
+		}
+	}
+	return interval, timeout, nil
+}
+
+// Heartbeat pings a WebSocket peer and enforces a read deadline that any message, ping or pong extends
+// A half-open connection fails the next read once the deadline passes
+type Heartbeat struct {
+	conn      *websocket.Conn
+	timeout   time.Duration
+	onContact func()
+	stopCh    chan struct{}
+	stopOnce  sync.Once
+}
+
+// StartHeartbeat installs ping/pong handlers on conn and starts pinging every interval
+// onContact (optional) is called whenever the peer is heard from
+func StartHeartbeat(conn *websocket.Conn, interval, timeout time.Duration, onContact func()) *Heartbeat {
+	h := &Heartbeat{
+		conn:      conn,
+		timeout:   timeout,
+		onContact: onContact,
+		stopCh:    make(chan struct{}),
+	}
+
+	conn.SetPongHandler(func(string) error {
+		h.Touch()
+		return nil
+	})
+	conn.SetPingHandler(func(data string) error {
+		h.Touch()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(timeout))
+		if err != nil && err != websocket.ErrCloseSent {
+			return err
+		}
+		return nil
+	})
+	h.Touch()
+
+	go h.pingLoop(interval)
+	return h
+}
+
+// Touch records contact with the peer and extends the read deadline
+func (h *Heartbeat) Touch() {
+	h.conn.SetReadDeadline(time.Now().Add(h.timeout))
+	if h.onContact != nil {
+		h.onContact()
+	}
+}
+
+// Stop stops pinging; the connection is left open
+func (h *Heartbeat) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stopCh)
+	})
+}
+
+// pingLoop sends pings until stopped or a ping cannot be written
+func (h *Heartbeat) pingLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// WriteControl may be called concurrently with other writes
+			if err := h.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.timeout)); err != nil {
+				return
+			}
+		case <-h.stopCh:
+			return
+		}
+	}
+}
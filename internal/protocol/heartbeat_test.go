@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHeartbeat(t *testing.T) {
+	tests := []struct {
+		name        string
+		peerReads   bool // A reading peer answers pings; a silent one behaves like a half-open connection
+		wantTimeout bool
+	}{
+		{name: "responsive peer keeps the connection", peerReads: true, wantTimeout: false},
+		{name: "silent peer times out", peerReads: false, wantTimeout: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upgrader := websocket.Upgrader{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				if tt.peerReads {
+					for {
+						if _, _, err := conn.ReadMessage(); err != nil {
+							return
+						}
+					}
+				}
+				time.Sleep(time.Second)
+			}))
+			defer server.Close()
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			if err != nil {
+				t.Fatalf("Dial failed: %v", err)
+			}
+			defer conn.Close()
+
+			contacts := make(chan struct{}, 100)
+			heartbeat := StartHeartbeat(conn, 20*time.Millisecond, 100*time.Millisecond, func() {
+				select {
+				case contacts <- struct{}{}:
+				default:
+				}
+			})
+			defer heartbeat.Stop()
+
+			readErr := make(chan error, 1)
+			go func() {
+				_, _, err := conn.ReadMessage()
+				readErr <- err
+			}()
+
+			select {
+			case err := <-readErr:
+				netErr, ok := err.(net.Error)
+				if !tt.wantTimeout || !ok || !netErr.Timeout() {
+					t.Fatalf("ReadMessage error = %v, want timeout: %v", err, tt.wantTimeout)
+				}
+			case <-time.After(400 * time.Millisecond):
+				if tt.wantTimeout {
+					t.Fatal("ReadMessage did not time out on a silent peer")
+				}
+				if len(contacts) < 2 {
+					t.Errorf("contact recorded %d times, want pongs to count as contact", len(contacts))
+				}
+			}
+		})
+	}
+}
+
+func TestHeartbeatFromEnv(t *testing.T) {
+	interval, timeout, err := HeartbeatFromEnv()
+	if err != nil || interval != DefaultPingInterval || timeout != DefaultPongTimeout {
+		t.Errorf("HeartbeatFromEnv() = %v, %v, %v; want the defaults", interval, timeout, err)
+	}
+
+	t.Setenv("HEARTBEAT_INTERVAL", "2s")
+	t.Setenv("HEARTBEAT_TIMEOUT", "6s")
+	interval, timeout, err = HeartbeatFromEnv()
+	if err != nil || interval != 2*time.Second || timeout != 6*time.Second {
+		t.Errorf("HeartbeatFromEnv() = %v, %v, %v; want 2s, 6s", interval, timeout, err)
+	}
+
+	t.Setenv("HEARTBEAT_TIMEOUT", "soon")
+	if _, _, err := HeartbeatFromEnv(); err == nil || !strings.Contains(err.Error(), "HEARTBEAT_TIMEOUT") {
+		t.Errorf("HeartbeatFromEnv() error = %v, want HEARTBEAT_TIMEOUT error", err)
+	}
+}
@@ -0,0 +1,41 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HeaderDataPlaneLabels carries the labels a data plane declares on connect and on every poll
+const HeaderDataPlaneLabels = "X-Data-Plane-Labels"
+
+// FormatLabels encodes labels as comma-separated key=value pairs in key order
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseLabels decodes comma-separated key=value pairs, e.g. "region=eu,environment=prod"
+// Returns nil for an empty string
+func ParseLabels(s string) (map[string]string, error) {
+	var labels map[string]string
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q: want key=value", pair)
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
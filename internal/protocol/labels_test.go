@@ -0,0 +1,37 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{input: "", want: nil},
+		{input: "region=eu", want: map[string]string{"region": "eu"}},
+		{input: " region = eu , environment=prod,", want: map[string]string{"region": "eu", "environment": "prod"}},
+		{input: "region", wantErr: true},
+		{input: "=eu", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseLabels(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLabels(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLabels(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	labels := map[string]string{"region": "eu", "environment": "prod"}
+	if formatted := FormatLabels(labels); formatted != "environment=prod,region=eu" {
+		t.Errorf("FormatLabels = %q, want sorted pairs", formatted)
+	}
+}
@@ -1,15 +1,61 @@
 package protocol
 
+import (
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+)
+
 // MessageType identifies the type of WebSocket message
 type MessageType string
 
 const (
 	// MessageTypeConfigSnapshot is sent from CP to DP with full config
 	MessageTypeConfigSnapshot MessageType = "config_snapshot"
+	// MessageTypeConfigDelta is sent from CP to DP with the changes since the DP's applied version
+	MessageTypeConfigDelta MessageType = "config_delta"
+	// MessageTypeResyncRequest is sent from DP to CP when a delta does not apply, asking for a full snapshot
+	MessageTypeResyncRequest MessageType = "resync_request"
 	// MessageTypeAck is sent from DP to CP when config is applied
 	MessageTypeAck MessageType = "ack"
 	// MessageTypeNack is sent from DP to CP when config is rejected
 	MessageTypeNack MessageType = "nack"
+	// MessageTypeHello is sent from DP to CP on connect to identify the data plane
+	MessageTypeHello MessageType = "hello"
+	// MessageTypeOverride is sent from CP to DP to force a breaker or drain a placement
+	MessageTypeOverride MessageType = "override"
+)
+
+// OverrideAction identifies an operator override
+type OverrideAction string
+
+const (
+	// OverrideForceOpen rejects traffic to a placement regardless of failures
+	OverrideForceOpen OverrideAction = "force_open"
+	// OverrideForceClosed allows traffic to a placement regardless of failures
+	OverrideForceClosed OverrideAction = "force_closed"
+	// OverrideClearCircuit removes a forced breaker state
+	OverrideClearCircuit OverrideAction = "clear_circuit"
+	// OverrideDrain moves new traffic off a placement to its fallback
+	OverrideDrain OverrideAction = "drain"
+	// OverrideUndrain removes a drain
+	OverrideUndrain OverrideAction = "undrain"
+)
+
+// NackReason classifies why a data plane rejected a config
+type NackReason string
+
+const (
+	// NackMalformed means the message could not be decoded
+	NackMalformed NackReason = "malformed"
+	// NackInvalidConfig means the config failed validation
+	NackInvalidConfig NackReason = "invalid_config"
+	// NackPreApplyCheckFailed means a pre-apply check (e.g. endpoint resolution) rejected the config
+	NackPreApplyCheckFailed NackReason = "pre_apply_check_failed"
+	// NackBadSignature means the config was unsigned or its signature did not verify
+	NackBadSignature NackReason = "bad_signature"
+	// NackApplyFailed means the config could not be applied for another reason
+	NackApplyFailed NackReason = "apply_failed"
 )
 
 // Message is the base WebSocket message structure
@@ -20,11 +66,45 @@ type Message struct {
 
 // ConfigSnapshotMessage contains a full routing configuration
 type ConfigSnapshotMessage struct {
-	Type             MessageType       `json:"type"`
-	Version          string            `json:"version"`
-	RoutingTable     map[string]string `json:"routingTable"`
-	CellEndpoints    map[string]string `json:"cellEndpoints"`
-	DefaultPlacement string            `json:"defaultPlacement"`
+	Type             MessageType                        `json:"type"`
+	Version          string                             `json:"version"`
+	RoutingTable     map[string]string                  `json:"routingTable"`
+	CellEndpoints    map[string]string                  `json:"cellEndpoints"`
+	Placements       map[string]*config.PlacementConfig `json:"placements,omitempty"`
+	DefaultPlacement string                             `json:"defaultPlacement"`
+	RateLimitMaxKeys int                                `json:"rateLimitMaxKeys,omitempty"`
+	Signature        string                             `json:"signature,omitempty"` // Ed25519 over the config's canonical JSON
+}
+
+// ConfigDeltaMessage contains the changes turning config BaseVersion into Version
+// Maps carry added or changed entries; removals are listed by key
+type ConfigDeltaMessage struct {
+	Type               MessageType                        `json:"type"`
+	BaseVersion        string                             `json:"baseVersion"`
+	Version            string                             `json:"version"`
+	RoutingTable       map[string]string                  `json:"routingTable,omitempty"`
+	RemovedRoutingKeys []string                           `json:"removedRoutingKeys,omitempty"`
+	CellEndpoints      map[string]string                  `json:"cellEndpoints,omitempty"`
+	Placements         map[string]*config.PlacementConfig `json:"placements,omitempty"`
+	RemovedPlacements  []string                           `json:"removedPlacements,omitempty"`
+	DefaultPlacement   string                             `json:"defaultPlacement,omitempty"` // Empty if unchanged
+	RateLimitMaxKeys   *int                               `json:"rateLimitMaxKeys,omitempty"` // Nil if unchanged
+	Signature          string                             `json:"signature,omitempty"`        // Over the resulting config
+}
+
+// ResyncRequestMessage asks the control plane for a full snapshot
+type ResyncRequestMessage struct {
+	Type    MessageType `json:"type"`
+	Version string      `json:"version"` // Version the data plane has applied
+	Reason  string      `json:"reason"`
+}
+
+// HelloMessage identifies a data plane to the control plane
+type HelloMessage struct {
+	Type     MessageType `json:"type"`
+	ID       string      `json:"id"`
+	Hostname string      `json:"hostname"`
+	Build    string      `json:"build"`
 }
 
 // AckMessage acknowledges successful config application
@@ -37,5 +117,14 @@ type AckMessage struct {
 type NackMessage struct {
 	Type    MessageType `json:"type"`
 	Version string      `json:"version"`
+	Reason  NackReason  `json:"reason,omitempty"`
 	Error   string      `json:"error"`
 }
+
+// OverrideMessage carries an operator override for a placement
+type OverrideMessage struct {
+	Type      MessageType    `json:"type"`
+	Placement string         `json:"placement"`
+	Action    OverrideAction `json:"action"`
+	ExpiresAt time.Time      `json:"expiresAt"` // Zero for clear_circuit and undrain
+}
@@ -0,0 +1,39 @@
+package protocol
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Headers identifying a polling data plane, the HTTP counterpart of the hello message
+const (
+	HeaderDataPlaneID       = "X-Data-Plane-ID"
+	HeaderDataPlaneHostname = "X-Data-Plane-Hostname"
+	HeaderDataPlaneBuild    = "X-Data-Plane-Build"
+)
+
+// PollAckPath is appended to the config polling URL to post acks and nacks
+const PollAckPath = "/ack"
+
+// IsPollingURL reports whether a control plane URL selects HTTP polling (http, https)
+// rather than a WebSocket connection (ws, wss)
+func IsPollingURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// ETag returns the entity tag of a config version
+func ETag(version string) string {
+	return `"` + version + `"`
+}
+
+// ParseETag returns the config version of an entity tag, or an empty string if there is none
+// Weak tags match like strong ones since a version identifies the config
+func ParseETag(etag string) string {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	return strings.Trim(etag, `"`)
+}
+
+// IfNoneMatch returns the config version a conditional request already holds
+func IfNoneMatch(r *http.Request) string {
+	return ParseETag(r.Header.Get("If-None-Match"))
+}
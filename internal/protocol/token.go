@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTokenTTL is how long a data plane token signed at connect time stays valid
+const DefaultTokenTTL = 5 * time.Minute
+
+// MaxTokenClockSkew is how far past DefaultTokenTTL a token's expiry may be, allowing for clock differences
+const MaxTokenClockSkew = 30 * time.Second
+
+// ErrInvalidToken is returned by VerifyToken for malformed, forged, expired or long-lived tokens
+var ErrInvalidToken = errors.New("invalid data plane token")
+
+// SignToken returns a token asserting a data plane ID until expiresAt, signed with that data plane's secret
+// Format: base64url(id) "." unix expiry "." base64url(HMAC-SHA256(secret, id "." expiry))
+func SignToken(secret []byte, id string, expiresAt time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(id)) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(tokenMAC(secret, payload))
+}
+
+// VerifyToken checks a token's signature and expiry and returns the data plane ID it asserts
+// Tokens expiring more than DefaultTokenTTL plus MaxTokenClockSkew from now are rejected, so a leaked
+// token cannot be minted to stay valid indefinitely
+func VerifyToken(secret []byte, token string, now time.Time) (string, error) {
+	return VerifyTokenFunc(func(string) ([]byte, bool) { return secret, true }, token, now)
+}
+
+// VerifyTokenFunc is VerifyToken with the secret looked up by the ID the token asserts
+// A token for an ID without a secret is invalid
+func VerifyTokenFunc(secretFor func(id string) ([]byte, bool), token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	id, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(id) == 0 {
+		return "", fmt.Errorf("%w: malformed id", ErrInvalidToken)
+	}
+	secret, ok := secretFor(string(id))
+	if !ok {
+		return "", fmt.Errorf("%w: no credential for %s", ErrInvalidToken, id)
+	}
+
+	payload := parts[0] + "." + parts[1]
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, tokenMAC(secret, payload)) {
+		return "", fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed expiry", ErrInvalidToken)
+	}
+	if !now.Before(time.Unix(expiry, 0)) {
+		return "", fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if time.Unix(expiry, 0).Sub(now) > DefaultTokenTTL+MaxTokenClockSkew {
+		return "", fmt.Errorf("%w: expiry too far in the future", ErrInvalidToken)
+	}
+	return string(id), nil
+}
+
+// tokenMAC computes the token signature over its payload
+func tokenMAC(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,70 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyToken(t *testing.T) {
+	secret := []byte("shared-secret")
+	now := time.Now()
+	valid := SignToken(secret, "dp-1", now.Add(time.Minute))
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		token   string
+		wantID  string
+		wantErr bool
+	}{
+		{name: "valid token", secret: secret, token: valid, wantID: "dp-1"},
+		{name: "wrong secret", secret: []byte("other-secret"), token: valid, wantErr: true},
+		{name: "expired", secret: secret, token: SignToken(secret, "dp-1", now.Add(-time.Second)), wantErr: true},
+		{name: "full TTL plus clock skew", secret: secret, token: SignToken(secret, "dp-1", now.Add(DefaultTokenTTL+MaxTokenClockSkew)), wantID: "dp-1"},
+		{name: "expiry beyond the TTL", secret: secret, token: SignToken(secret, "dp-1", now.Add(DefaultTokenTTL+MaxTokenClockSkew+time.Second)), wantErr: true},
+		{name: "long-lived", secret: secret, token: SignToken(secret, "dp-1", now.Add(365*24*time.Hour)), wantErr: true},
+		{name: "tampered id", secret: secret, token: strings.SplitN(SignToken(secret, "dp-admin", now.Add(time.Minute)), ".", 2)[0] + valid[strings.Index(valid, "."):], wantErr: true},
+		{name: "extended expiry", secret: secret, token: strings.Replace(valid, ".", ".9", 1), wantErr: true},
+		{name: "malformed", secret: secret, token: "not-a-token", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := VerifyToken(tt.secret, tt.token, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("VerifyToken error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyToken error = %v", err)
+			}
+			if id != tt.wantID {
+				t.Errorf("VerifyToken id = %s, want %s", id, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestVerifyTokenFunc(t *testing.T) {
+	secrets := map[string][]byte{"dp-1": []byte("dp-1-secret"), "dp-2": []byte("dp-2-secret")}
+	secretFor := func(id string) ([]byte, bool) {
+		secret, ok := secrets[id]
+		return secret, ok
+	}
+	now := time.Now()
+
+	if id, err := VerifyTokenFunc(secretFor, SignToken(secrets["dp-1"], "dp-1", now.Add(time.Minute)), now); err != nil || id != "dp-1" {
+		t.Errorf("VerifyTokenFunc(own secret) = %s, %v; want dp-1", id, err)
+	}
+	// A data plane cannot mint a token for another ID with its own secret
+	if _, err := VerifyTokenFunc(secretFor, SignToken(secrets["dp-1"], "dp-2", now.Add(time.Minute)), now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyTokenFunc(other ID) error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := VerifyTokenFunc(secretFor, SignToken(secrets["dp-1"], "dp-3", now.Add(time.Minute)), now); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("VerifyTokenFunc(unknown ID) error = %v, want ErrInvalidToken", err)
+	}
+}
@@ -1,13 +1,17 @@
 package proxy
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/circuit"
@@ -15,7 +19,9 @@ import (
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/health"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/limits"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/metrics"
 	"github.com/gvquiroz/cell-routing-from-scratch/internal/routing"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/tracing"
 )
 
 const (
@@ -27,17 +33,27 @@ const (
 	headerRouteReason    = "X-Route-Reason"
 	headerFailoverReason = "X-Failover-Reason"
 	headerCircuitState   = "X-Circuit-State"
+	headerRequestTimeout = "X-Request-Timeout-Ms"
+
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
 )
 
 // Handler handles incoming HTTP requests and proxies them to cells
 type Handler struct {
 	router         *routing.Router
-	config         *config.Config
+	config         *config.Config        // Config the handler was created with
+	configSource   func() *config.Config // Live config, if set; see currentConfig
 	logger         *logging.Logger
 	transport      *http.Transport
 	healthChecker  *health.Checker
 	circuitManager *circuit.Manager
 	limitsManager  *limits.Manager
+	configureMu    sync.Mutex // Serializes ApplyConfig
+	metrics        *handlerMetrics
+	tracer         *tracing.Tracer
 }
 
 // NewHandler creates a new proxy handler
@@ -79,18 +95,35 @@ func NewHandler(router *routing.Router, cfg *config.Config, logger *logging.Logg
 		healthChecker:  healthChecker,
 		circuitManager: circuitManager,
 		limitsManager:  limitsManager,
+		tracer:         tracing.NewTracer("cell-router", nil),
 	}
 
 	// Register endpoints for health checking and configure limits
 	h.configureResilienceMechanisms(cfg)
 
+	h.metrics = newHandlerMetrics(h)
+
 	return h
 }
 
+// ApplyConfig updates health checks and limits to an activated config (see config.Loader.OnActivate)
+// Limiters are updated in place, and limits of placements no longer in the config are removed
+func (h *Handler) ApplyConfig(cfg *config.Config) {
+	h.configureMu.Lock()
+	defer h.configureMu.Unlock()
+	h.configureResilienceMechanisms(cfg)
+}
+
 // configureResilienceMechanisms sets up health checks and limits based on config
 func (h *Handler) configureResilienceMechanisms(cfg *config.Config) {
 	endpoints := cfg.GetCellEndpoints()
 
+	maxKeys := cfg.RateLimitMaxKeys
+	if maxKeys <= 0 {
+		maxKeys = limits.DefaultMaxRateLimitKeys
+	}
+	h.limitsManager.SetMaxRateLimitKeys(maxKeys)
+
 	for placementKey, endpointURL := range endpoints {
 		// Get placement-specific config if available
 		placementCfg, exists := cfg.GetPlacementConfig(placementKey)
@@ -100,31 +133,87 @@ func (h *Handler) configureResilienceMechanisms(cfg *config.Config) {
 			if placementCfg.HealthCheck != nil {
 				parsedHealthCheck, err := placementCfg.HealthCheck.Parse()
 				if err == nil {
-					checker := health.NewChecker(health.CheckConfig{
+					h.healthChecker.RegisterEndpointWithConfig(placementKey, endpointURL, health.CheckConfig{
 						Path:     parsedHealthCheck.Path,
 						Interval: parsedHealthCheck.Interval,
 						Timeout:  parsedHealthCheck.Timeout,
-					}, h.logger)
-					checker.RegisterEndpoint(placementKey, endpointURL)
-					h.healthChecker = checker // Update with placement-specific checker
+					})
 				}
 			} else {
 				// Use default health checker
 				h.healthChecker.RegisterEndpoint(placementKey, endpointURL)
 			}
 
-			// Configure limits
-			if placementCfg.ConcurrencyLimit > 0 || placementCfg.MaxRequestBodyBytes > 0 {
-				h.limitsManager.SetConfig(placementKey, limits.Config{
-					MaxConcurrentRequests: placementCfg.ConcurrencyLimit,
-					MaxRequestBodyBytes:   placementCfg.MaxRequestBodyBytes,
-				})
+			// Configure limits; a placement without any clears the limits set by earlier configs
+			limitsCfg := limits.Config{
+				MaxConcurrentRequests:  placementCfg.ConcurrencyLimit,
+				MaxConcurrentPerTenant: placementCfg.TenantConcurrencyLimit,
+				TenantOverrides:        placementCfg.TenantConcurrencyOverrides,
+				MaxRequestBodyBytes:    placementCfg.MaxRequestBodyBytes,
+			}
+			if placementCfg.Queue != nil {
+				limitsCfg.Queue = queueConfig(placementCfg.Queue)
+			}
+			if adaptive := placementCfg.AdaptiveConcurrency; adaptive != nil {
+				limitsCfg.Adaptive = &limits.AdaptiveConfig{
+					MinLimit:         adaptive.MinLimit,
+					MaxLimit:         adaptive.MaxLimit,
+					LatencyTolerance: adaptive.LatencyTolerance,
+					BackoffRatio:     adaptive.BackoffRatio,
+				}
+			}
+			h.limitsManager.SetConfig(placementKey, limitsCfg)
+
+			// Configure rate limits
+			if placementCfg.RateLimit != nil {
+				h.limitsManager.SetRateLimit(placementKey, rateLimitConfig(placementCfg.RateLimit))
+			} else {
+				h.limitsManager.RemoveRateLimit(placementKey)
 			}
 		} else {
 			// Use default health checker for legacy configs
 			h.healthChecker.RegisterEndpoint(placementKey, endpointURL)
+			h.limitsManager.RemoveConfig(placementKey)
 		}
 	}
+
+	// Drop limits of placements removed from the config
+	for _, placementKey := range h.limitsManager.Placements() {
+		if _, exists := endpoints[placementKey]; !exists {
+			h.limitsManager.RemoveConfig(placementKey)
+		}
+	}
+}
+
+// SetConfigSource sets where the live config is read from (e.g. a loader's GetConfig), so failover
+// targets and introspection follow reloads and control plane pushes. Must be called before serving
+func (h *Handler) SetConfigSource(source func() *config.Config) {
+	h.configSource = source
+}
+
+// currentConfig returns the live config, or the config the handler was created with if no source is set
+func (h *Handler) currentConfig() *config.Config {
+	if h.configSource != nil {
+		if cfg := h.configSource(); cfg != nil {
+			return cfg
+		}
+	}
+	return h.config
+}
+
+// SetTracer sets the tracer used for request spans
+func (h *Handler) SetTracer(tracer *tracing.Tracer) {
+	h.tracer = tracer
+}
+
+// Metrics returns the registry holding the handler's Prometheus metrics
+func (h *Handler) Metrics() *metrics.Registry {
+	return h.metrics.registry
+}
+
+// LimitsManager returns the handler's limits manager
+func (h *Handler) LimitsManager() *limits.Manager {
+	return h.limitsManager
 }
 
 // Stop gracefully shuts down the handler
@@ -143,6 +232,18 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if requestID == "" {
 		requestID = generateRequestID()
 	}
+	// Continue the caller's trace, if any
+	ctx := r.Context()
+	if parent, ok := tracing.Extract(r.Header); ok {
+		ctx = tracing.ContextWithRemoteParent(ctx, parent)
+	}
+	ctx, span := h.tracer.Start(ctx, "router.request", tracing.SpanKindServer)
+	r = r.WithContext(ctx)
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.Path)
+	span.SetAttribute("request_id", requestID)
+
+	info := &requestInfo{requestID: requestID, span: span}
 
 	// Extract routing key - it's required
 	routingKey := r.Header.Get(headerRoutingKey)
@@ -151,37 +252,77 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			"request_id": requestID,
 		})
 		http.Error(w, "Bad Request: X-Routing-Key header is required", http.StatusBadRequest)
-		h.logRequest(requestID, r, routingKey, "", "", "", http.StatusBadRequest, time.Since(startTime), "")
+		h.logRequest(info, r, routingKey, "", "", "", http.StatusBadRequest, time.Since(startTime), "")
 		return
 	}
 
+	span.SetAttribute("routing_key", routingKey)
+
 	// Make routing decision
+	_, routeSpan := h.tracer.Start(ctx, "routing.decision", tracing.SpanKindInternal)
 	decision, err := h.router.Route(routingKey)
 	if err != nil {
+		routeSpan.SetError(err.Error())
+		routeSpan.End()
 		h.logger.LogError("routing error", err, map[string]interface{}{
 			"request_id":  requestID,
 			"routing_key": routingKey,
 		})
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		h.logRequest(requestID, r, routingKey, "", "", "", http.StatusInternalServerError, time.Since(startTime), "")
+		h.logRequest(info, r, routingKey, "", "", "", http.StatusInternalServerError, time.Since(startTime), "")
 		return
 	}
 
+	routeSpan.SetAttribute("placement", decision.PlacementKey)
+	routeSpan.SetAttribute("route_reason", string(decision.Reason))
+	routeSpan.End()
+
 	placementKey := decision.PlacementKey
 	failoverReason := ""
 
-	// Check concurrency limits
-	if !h.limitsManager.TryAcquire(placementKey) {
-		h.logger.LogError("concurrency limit exceeded", nil, map[string]interface{}{
+	// Check rate limits
+	_, limitsSpan := h.tracer.Start(ctx, "limits.acquire", tracing.SpanKindInternal)
+	limitsSpan.SetAttribute("placement", placementKey)
+	rateLimit := h.limitsManager.AllowRate(placementKey, routingKey)
+	if rateLimit.Limited {
+		setRateLimitHeaders(w.Header(), rateLimit)
+	}
+	if !rateLimit.Allowed {
+		limitsSpan.SetError("rate_limit")
+		limitsSpan.End()
+		h.logger.LogError("rate limit exceeded", nil, map[string]interface{}{
+			"request_id":     requestID,
+			"routing_key":    routingKey,
+			"placement_key":  placementKey,
+			"retry_after_ms": rateLimit.RetryAfter.Milliseconds(),
+		})
+		w.Header().Set(headerRetryAfter, strconv.Itoa(ceilSeconds(rateLimit.RetryAfter)))
+		http.Error(w, "Too Many Requests: Rate Limit Exceeded", http.StatusTooManyRequests)
+		h.logRequest(info, r, routingKey, placementKey, string(decision.Reason), decision.EndpointURL, http.StatusTooManyRequests, time.Since(startTime), "rate_limit")
+		return
+	}
+
+	// Check concurrency limits, waiting in the placement's queue if configured
+	acquireCtx, cancel := requestDeadline(r)
+	queueTime, err := h.limitsManager.Acquire(acquireCtx, placementKey, routingKey)
+	cancel()
+	info.queueTime = queueTime
+	limitsSpan.SetAttribute("queue_time_ms", durationMs(queueTime))
+	if err != nil {
+		limitsSpan.SetError(concurrencyLimitReason(err))
+		limitsSpan.End()
+		h.logger.LogError("concurrency limit exceeded", err, map[string]interface{}{
 			"request_id":    requestID,
 			"routing_key":   routingKey,
 			"placement_key": placementKey,
+			"queue_time_ms": durationMs(queueTime),
 		})
 		http.Error(w, "Service Unavailable: Too Many Requests", http.StatusTooManyRequests)
-		h.logRequest(requestID, r, routingKey, placementKey, string(decision.Reason), decision.EndpointURL, http.StatusTooManyRequests, time.Since(startTime), "concurrency_limit")
+		h.logRequest(info, r, routingKey, placementKey, string(decision.Reason), decision.EndpointURL, http.StatusTooManyRequests, time.Since(startTime), concurrencyLimitReason(err))
 		return
 	}
-	defer h.limitsManager.Release(placementKey)
+	limitsSpan.End()
+	defer h.limitsManager.Release(placementKey, routingKey)
 
 	// Validate request body size
 	if r.ContentLength > 0 {
@@ -193,84 +334,83 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				"content_length": r.ContentLength,
 			})
 			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
-			h.logRequest(requestID, r, routingKey, placementKey, string(decision.Reason), decision.EndpointURL, http.StatusRequestEntityTooLarge, time.Since(startTime), "body_size_limit")
+			h.logRequest(info, r, routingKey, placementKey, string(decision.Reason), decision.EndpointURL, http.StatusRequestEntityTooLarge, time.Since(startTime), "body_size_limit")
 			return
 		}
 	}
 
-	// Check circuit breaker
+	// Check circuit breaker and health, failing over if needed
+	_, breakerSpan := h.tracer.Start(ctx, "circuit.check", tracing.SpanKindInternal)
+	breakerSpan.SetAttribute("placement", placementKey)
 	breaker := h.circuitManager.GetBreaker(placementKey)
-	if !breaker.Allow() {
-		// Circuit is open, check for fallback
-		placementCfg, hasFallback := h.config.GetPlacementConfig(placementKey)
-		if hasFallback && placementCfg.Fallback != "" {
-			// Route to fallback
-			fallbackEndpoint := h.config.GetCellEndpoints()[placementCfg.Fallback]
-			decision.PlacementKey = placementCfg.Fallback
-			decision.EndpointURL = fallbackEndpoint
-			failoverReason = "circuit_open"
-			placementKey = placementCfg.Fallback
-
-			h.logger.LogInfo("circuit open, routing to fallback", map[string]interface{}{
-				"request_id":         requestID,
-				"original_placement": decision.PlacementKey,
-				"fallback_placement": placementCfg.Fallback,
-			})
-		} else {
-			// No fallback, fail fast
-			h.logger.LogError("circuit breaker open, no fallback", nil, map[string]interface{}{
-				"request_id":    requestID,
-				"routing_key":   routingKey,
-				"placement_key": placementKey,
-				"circuit_state": breaker.GetState(),
-			})
-			w.Header().Set(headerCircuitState, string(breaker.GetState()))
-			http.Error(w, "Service Unavailable: Circuit Breaker Open", http.StatusServiceUnavailable)
-			h.logRequest(requestID, r, routingKey, placementKey, string(decision.Reason), decision.EndpointURL, http.StatusServiceUnavailable, time.Since(startTime), "circuit_open")
-			return
-		}
+	plan := h.planFailover(decision, breaker.Allow())
+	if plan.Rejected {
+		// Circuit is open with no fallback, fail fast
+		h.logger.LogError("circuit breaker open, no fallback", nil, map[string]interface{}{
+			"request_id":    requestID,
+			"routing_key":   routingKey,
+			"placement_key": placementKey,
+			"circuit_state": breaker.GetState(),
+		})
+		breakerSpan.SetError("circuit_open")
+		breakerSpan.End()
+		w.Header().Set(headerCircuitState, string(breaker.GetState()))
+		http.Error(w, "Service Unavailable: Circuit Breaker Open", http.StatusServiceUnavailable)
+		h.logRequest(info, r, routingKey, placementKey, string(decision.Reason), decision.EndpointURL, http.StatusServiceUnavailable, time.Since(startTime), "circuit_open")
+		return
 	}
 
-	// Check health status
-	if !h.healthChecker.IsHealthy(placementKey) {
-		// Endpoint unhealthy, check for fallback
-		placementCfg, hasFallback := h.config.GetPlacementConfig(placementKey)
-		if hasFallback && placementCfg.Fallback != "" {
-			// Route to fallback
-			fallbackEndpoint := h.config.GetCellEndpoints()[placementCfg.Fallback]
-			originalPlacement := decision.PlacementKey
-			decision.PlacementKey = placementCfg.Fallback
-			decision.EndpointURL = fallbackEndpoint
-			failoverReason = "upstream_unhealthy"
-			placementKey = placementCfg.Fallback
-
-			h.logger.LogInfo("endpoint unhealthy, routing to fallback", map[string]interface{}{
-				"request_id":         requestID,
-				"original_placement": originalPlacement,
-				"fallback_placement": placementCfg.Fallback,
-			})
-		} else {
-			// No fallback configured, route to default placement (fail-safe)
-			defaultPlacement := h.config.GetDefaultPlacement()
-			if defaultPlacement != placementKey {
-				defaultEndpoint := h.config.GetCellEndpoints()[defaultPlacement]
-				decision.PlacementKey = defaultPlacement
-				decision.EndpointURL = defaultEndpoint
-				failoverReason = "upstream_unhealthy"
-				placementKey = defaultPlacement
-
-				h.logger.LogInfo("endpoint unhealthy, routing to default", map[string]interface{}{
-					"request_id":         requestID,
-					"original_placement": decision.PlacementKey,
-					"default_placement":  defaultPlacement,
-				})
-			}
-		}
+	if plan.FailoverReason != "" {
+		h.logger.LogInfo(fmt.Sprintf("%s, routing to %s", plan.FailoverReason, plan.PlacementKey), map[string]interface{}{
+			"request_id":         requestID,
+			"original_placement": placementKey,
+			"target_placement":   plan.PlacementKey,
+			"failover_reason":    plan.FailoverReason,
+		})
+		decision.PlacementKey = plan.PlacementKey
+		decision.EndpointURL = plan.EndpointURL
+		failoverReason = plan.FailoverReason
+		placementKey = plan.PlacementKey
+	}
+
+	breakerSpan.SetAttribute("circuit_state", string(breaker.GetState()))
+	breakerSpan.SetAttribute("failover_reason", failoverReason)
+	breakerSpan.SetAttribute("target_placement", placementKey)
+	breakerSpan.End()
+
+	// Enforce the body size limit while streaming, covering chunked bodies
+	bodyLimiter := h.limitsManager.LimitRequestBody(placementKey, r.Body)
+	if bodyLimiter != nil {
+		r.Body = bodyLimiter
 	}
 
 	// Proxy request to upstream
+	upstreamStart := time.Now()
 	statusCode, err := h.proxyRequest(w, r, decision, requestID, failoverReason)
 
+	// Abort with 413 if the body crossed the limit mid-stream; the upstream is not at fault
+	if bodyLimiter != nil && bodyLimiter.Exceeded() {
+		aborts := h.limitsManager.RecordBodyLimitAbort()
+		h.metrics.bodyLimitAborts.Inc(decision.PlacementKey)
+		h.logger.LogError("request body too large, upstream request aborted", err, map[string]interface{}{
+			"request_id":              requestID,
+			"routing_key":             routingKey,
+			"placement_key":           decision.PlacementKey,
+			"max_request_body_bytes":  bodyLimiter.Limit(),
+			"body_limit_aborts_total": aborts,
+		})
+		if statusCode == 0 {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			statusCode = http.StatusRequestEntityTooLarge
+		}
+		h.logRequest(info, r, routingKey, decision.PlacementKey, string(decision.Reason), decision.EndpointURL, statusCode, time.Since(startTime), "body_size_limit_stream")
+		return
+	}
+
+	// Feed upstream latency and overload signals into adaptive concurrency limits
+	h.limitsManager.RecordOutcome(decision.PlacementKey, time.Since(upstreamStart),
+		err != nil || statusCode >= 500 || statusCode == http.StatusTooManyRequests)
+
 	// Record result in circuit breaker
 	if err != nil || statusCode >= 500 {
 		breaker.RecordFailure()
@@ -293,11 +433,77 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h.logRequest(requestID, r, routingKey, decision.PlacementKey, string(decision.Reason), decision.EndpointURL, statusCode, time.Since(startTime), failoverReason)
+	h.logRequest(info, r, routingKey, decision.PlacementKey, string(decision.Reason), decision.EndpointURL, statusCode, time.Since(startTime), failoverReason)
+}
+
+// FailoverPlan describes where a routed request is sent after circuit breaker and health checks
+type FailoverPlan struct {
+	PlacementKey   string `json:"placement_key"`             // Placement the request is sent to
+	EndpointURL    string `json:"endpoint_url"`              // Upstream the request is sent to
+	FailoverReason string `json:"failover_reason,omitempty"` // Why the routed placement was bypassed
+	Rejected       bool   `json:"rejected"`                  // Circuit open with no fallback (503)
+}
+
+// planFailover applies circuit breaker and health state to a routing decision
+// circuitAllows is the breaker verdict for the routed placement; decision is not modified
+func (h *Handler) planFailover(decision *routing.RoutingDecision, circuitAllows bool) FailoverPlan {
+	plan := FailoverPlan{
+		PlacementKey: decision.PlacementKey,
+		EndpointURL:  decision.EndpointURL,
+	}
+	cfg := h.currentConfig()
+	endpoints := cfg.GetCellEndpoints()
+
+	if !circuitAllows {
+		// Circuit is open, route to fallback or fail fast
+		placementCfg, exists := cfg.GetPlacementConfig(plan.PlacementKey)
+		if !exists || placementCfg.Fallback == "" {
+			plan.Rejected = true
+			plan.FailoverReason = "circuit_open"
+			return plan
+		}
+		plan.PlacementKey = placementCfg.Fallback
+		plan.EndpointURL = endpoints[placementCfg.Fallback]
+		plan.FailoverReason = "circuit_open"
+	}
+
+	if !h.healthChecker.IsHealthy(plan.PlacementKey) {
+		// Endpoint unhealthy, route to fallback or the default placement (fail-safe)
+		target := cfg.GetDefaultPlacement()
+		if placementCfg, exists := cfg.GetPlacementConfig(plan.PlacementKey); exists && placementCfg.Fallback != "" {
+			target = placementCfg.Fallback
+		}
+		if target != plan.PlacementKey {
+			plan.PlacementKey = target
+			plan.EndpointURL = endpoints[target]
+			plan.FailoverReason = "upstream_unhealthy"
+		}
+	}
+
+	return plan
 }
 
 // proxyRequest proxies the request to the upstream endpoint
 func (h *Handler) proxyRequest(w http.ResponseWriter, r *http.Request, decision *routing.RoutingDecision, requestID, failoverReason string) (int, error) {
+	ctx, span := h.tracer.Start(r.Context(), "upstream.request", tracing.SpanKindClient)
+	defer span.End()
+	span.SetAttribute("placement", decision.PlacementKey)
+	span.SetAttribute("upstream_url", decision.EndpointURL)
+
+	statusCode, err := h.doUpstreamRequest(ctx, w, r, decision, requestID, failoverReason)
+	if statusCode > 0 {
+		span.SetAttribute("http.status_code", statusCode)
+	}
+	if err != nil {
+		span.SetError(err.Error())
+	} else if statusCode >= 500 {
+		span.SetError(http.StatusText(statusCode))
+	}
+	return statusCode, err
+}
+
+// doUpstreamRequest sends the request to the upstream and streams the response back
+func (h *Handler) doUpstreamRequest(ctx context.Context, w http.ResponseWriter, r *http.Request, decision *routing.RoutingDecision, requestID, failoverReason string) (int, error) {
 	// Parse upstream URL
 	upstreamURL, err := url.Parse(decision.EndpointURL)
 	if err != nil {
@@ -313,7 +519,7 @@ func (h *Handler) proxyRequest(w http.ResponseWriter, r *http.Request, decision
 	}
 
 	// Create upstream request
-	upstreamReq, err := http.NewRequestWithContext(r.Context(), r.Method, targetURL.String(), r.Body)
+	upstreamReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL.String(), r.Body)
 	if err != nil {
 		return 0, err
 	}
@@ -327,6 +533,7 @@ func (h *Handler) proxyRequest(w http.ResponseWriter, r *http.Request, decision
 
 	// Add/update forwarding headers
 	upstreamReq.Header.Set(headerRequestID, requestID)
+	tracing.Inject(ctx, upstreamReq.Header)
 
 	// X-Forwarded-For: append client IP
 	clientIP, _, _ := net.SplitHostPort(r.RemoteAddr)
@@ -386,10 +593,29 @@ func (h *Handler) proxyRequest(w http.ResponseWriter, r *http.Request, decision
 	return upstreamResp.StatusCode, err
 }
 
-// logRequest logs the completed request
-func (h *Handler) logRequest(requestID string, r *http.Request, routingKey, placementKey, routeReason, upstreamURL string, statusCode int, duration time.Duration, failoverReason string) {
+// requestInfo carries per-request details recorded in the request log
+type requestInfo struct {
+	requestID string
+	queueTime time.Duration // Time spent waiting for a concurrency slot
+	span      *tracing.Span // Root span, ended when the request is logged
+}
+
+// logRequest logs the completed request and records it in request metrics
+func (h *Handler) logRequest(info *requestInfo, r *http.Request, routingKey, placementKey, routeReason, upstreamURL string, statusCode int, duration time.Duration, failoverReason string) {
+	h.metrics.observeRequest(placementKey, routeReason, failoverReason, statusCode, duration)
+
+	info.span.SetAttribute("placement", placementKey)
+	info.span.SetAttribute("route_reason", routeReason)
+	info.span.SetAttribute("failover_reason", failoverReason)
+	info.span.SetAttribute("http.status_code", statusCode)
+	if statusCode >= 500 {
+		info.span.SetError(http.StatusText(statusCode))
+	}
+	info.span.End()
+	traceID := info.span.TraceID().String()
+
 	logData := logging.RequestLog{
-		RequestID:    requestID,
+		RequestID:    info.requestID,
 		Method:       r.Method,
 		Path:         r.URL.Path,
 		RoutingKey:   routingKey,
@@ -397,13 +623,15 @@ func (h *Handler) logRequest(requestID string, r *http.Request, routingKey, plac
 		RouteReason:  routeReason,
 		UpstreamURL:  upstreamURL,
 		StatusCode:   statusCode,
-		DurationMs:   float64(duration.Microseconds()) / 1000.0,
+		DurationMs:   durationMs(duration),
+		QueueTimeMs:  durationMs(info.queueTime),
+		TraceID:      traceID,
 	}
 
 	// Add failover reason to extra fields if present
 	if failoverReason != "" {
 		h.logger.LogInfo(fmt.Sprintf("request completed with failover: %s", failoverReason), map[string]interface{}{
-			"request_id":      requestID,
+			"request_id":      info.requestID,
 			"method":          r.Method,
 			"path":            r.URL.Path,
 			"routing_key":     routingKey,
@@ -412,6 +640,8 @@ func (h *Handler) logRequest(requestID string, r *http.Request, routingKey, plac
 			"upstream_url":    upstreamURL,
 			"status_code":     statusCode,
 			"duration_ms":     logData.DurationMs,
+			"queue_time_ms":   logData.QueueTimeMs,
+			"trace_id":        traceID,
 			"failover_reason": failoverReason,
 		})
 	} else {
@@ -419,6 +649,90 @@ func (h *Handler) logRequest(requestID string, r *http.Request, routingKey, plac
 	}
 }
 
+// concurrencyLimitReason maps a limits rejection to the reason recorded in request logs
+func concurrencyLimitReason(err error) string {
+	switch {
+	case errors.Is(err, limits.ErrTenantLimit):
+		return "tenant_concurrency_limit"
+	case errors.Is(err, limits.ErrFairShare):
+		return "tenant_fair_share"
+	case errors.Is(err, limits.ErrQueueFull):
+		return "queue_full"
+	case errors.Is(err, limits.ErrQueueTimeout):
+		return "queue_timeout"
+	case errors.Is(err, limits.ErrDeadlineShed):
+		return "deadline_shed"
+	default:
+		return "concurrency_limit"
+	}
+}
+
+// requestDeadline returns the request context bounded by the client's X-Request-Timeout-Ms, if set
+func requestDeadline(r *http.Request) (context.Context, context.CancelFunc) {
+	timeoutMs, err := strconv.Atoi(r.Header.Get(headerRequestTimeout))
+	if err != nil || timeoutMs <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), time.Duration(timeoutMs)*time.Millisecond)
+}
+
+// queueConfig converts placement queue config to a limits.QueueConfig
+func queueConfig(cfg *config.QueueConfig) limits.QueueConfig {
+	parsed, err := cfg.Parse()
+	if err != nil {
+		return limits.QueueConfig{}
+	}
+	return limits.QueueConfig{
+		MaxLength: parsed.MaxLength,
+		MaxWait:   parsed.MaxWait,
+		Order:     limits.QueueOrder(parsed.Order),
+	}
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
+
+// rateLimitConfig converts placement rate limit config to a limits.RateLimitConfig
+func rateLimitConfig(cfg *config.RateLimitConfig) limits.RateLimitConfig {
+	key := limits.RateLimitKey(cfg.Key)
+	if key == "" {
+		key = limits.RateLimitByRoutingKey
+	}
+
+	overrides := make(map[string]limits.RateLimitRule, len(cfg.Overrides))
+	for routingKey, override := range cfg.Overrides {
+		overrides[routingKey] = rateLimitRule(override)
+	}
+
+	return limits.RateLimitConfig{
+		Key:       key,
+		Rule:      rateLimitRule(cfg.DefaultRule()),
+		Overrides: overrides,
+	}
+}
+
+// rateLimitRule converts a config rule to a limits.RateLimitRule
+func rateLimitRule(rule *config.RateLimitRuleConfig) limits.RateLimitRule {
+	return limits.RateLimitRule{
+		RequestsPerSecond: rule.RequestsPerSecond,
+		Burst:             rule.EffectiveBurst(),
+	}
+}
+
+// setRateLimitHeaders adds RateLimit-* headers describing the caller's bucket
+func setRateLimitHeaders(header http.Header, result limits.RateLimitResult) {
+	header.Set(headerRateLimitLimit, strconv.Itoa(result.Limit))
+	header.Set(headerRateLimitRemaining, strconv.Itoa(result.Remaining))
+	header.Set(headerRateLimitReset, strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
 // generateRequestID generates a unique request ID
 func generateRequestID() string {
 	b := make([]byte, 16)
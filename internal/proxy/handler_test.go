@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/limits"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/routing"
+)
+
+func TestHandler_ApplyConfigUpdatesLimits(t *testing.T) {
+	tmpFile := t.TempDir() + "/config.json"
+	initialConfig := `{
+		"version": "v1",
+		"routingTable": {"acme": "tier1"},
+		"placements": {
+			"tier1": {"url": "http://cell-tier1:9001", "concurrency_limit": 5},
+			"tier2": {"url": "http://cell-tier2:9002", "rate_limit": {"requests_per_second": 1, "burst": 1}}
+		},
+		"defaultPlacement": "tier1"
+	}`
+	if err := os.WriteFile(tmpFile, []byte(initialConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	loader := config.NewLoader(tmpFile, time.Second)
+	if err := loader.LoadInitial(); err != nil {
+		t.Fatalf("LoadInitial failed: %v", err)
+	}
+	handler := NewHandler(routing.NewRouter(loader), loader.GetConfig(), logging.NewLogger())
+	defer handler.Stop()
+	loader.OnActivate(handler.ApplyConfig)
+	manager := handler.LimitsManager()
+
+	// acme holds a slot across the reload
+	if err := manager.TryAcquire("tier1", "acme"); err != nil {
+		t.Fatalf("TryAcquire(acme) before reload = %v", err)
+	}
+
+	// The reload adds a rate limit, a tenant quota and a queue to tier1 and removes tier2
+	_, err := loader.Update("v1", func(cfg *config.Config) error {
+		tier1 := cfg.Placements["tier1"]
+		tier1.ConcurrencyLimit = 2
+		tier1.TenantConcurrencyLimit = 1
+		tier1.Queue = &config.QueueConfig{MaxLength: 1, MaxWait: "1s"}
+		tier1.RateLimit = &config.RateLimitConfig{RequestsPerSecond: 1, Burst: 1}
+		delete(cfg.Placements, "tier2")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	if inFlight, capacity, _ := manager.InFlight("tier1"); inFlight != 1 || capacity != 2 {
+		t.Errorf("tier1 in flight = %d of %d, want acme's slot kept under the new limit of 2", inFlight, capacity)
+	}
+	if err := manager.TryAcquire("tier1", "acme"); !errors.Is(err, limits.ErrTenantLimit) {
+		t.Errorf("TryAcquire(acme) after reload = %v, want %v", err, limits.ErrTenantLimit)
+	}
+	if first, second := manager.AllowRate("tier1", "acme"), manager.AllowRate("tier1", "acme"); !first.Allowed || second.Allowed {
+		t.Errorf("AllowRate(tier1) after reload = %v then %v, want the new burst of 1", first.Allowed, second.Allowed)
+	}
+	if result := manager.AllowRate("tier2", "acme"); result.Limited {
+		t.Errorf("AllowRate(tier2) after tier2 was removed = %+v, want no limit", result)
+	}
+
+	// With tier1 full, a request waits in the new queue until acme releases its slot
+	if err := manager.TryAcquire("tier1", "globex"); err != nil {
+		t.Fatalf("TryAcquire(globex) = %v", err)
+	}
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := manager.Acquire(context.Background(), "tier1", "initech")
+		acquired <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for manager.QueueLength("tier1") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("initech was not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	manager.Release("tier1", "acme")
+	if err := <-acquired; err != nil {
+		t.Errorf("queued Acquire(initech) = %v, want a slot", err)
+	}
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"sort"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/circuit"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/health"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/limits"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/routing"
+)
+
+// PlacementStatus is a point-in-time view of a placement's resilience state
+type PlacementStatus struct {
+	Placement string                 `json:"placement"`
+	URL       string                 `json:"url"`
+	Fallback  string                 `json:"fallback,omitempty"`
+	Health    health.EndpointStatus  `json:"health"`
+	Circuit   circuit.BreakerStatus  `json:"circuit"`
+	Limits    *limits.PlacementStats `json:"limits,omitempty"` // Nil when no concurrency limit applies
+}
+
+// Placements returns the health, circuit breaker and limit state of every placement, sorted by name
+func (h *Handler) Placements() []PlacementStatus {
+	healthSnapshot := h.healthChecker.Snapshot()
+	breakerSnapshot := h.circuitManager.Snapshot()
+
+	limitStats := make(map[string]limits.PlacementStats)
+	for _, stats := range h.limitsManager.Stats() {
+		limitStats[stats.Placement] = stats
+	}
+
+	cfg := h.currentConfig()
+	endpoints := cfg.GetCellEndpoints()
+	placements := make([]PlacementStatus, 0, len(endpoints))
+	for placementKey, endpointURL := range endpoints {
+		status := PlacementStatus{
+			Placement: placementKey,
+			URL:       endpointURL,
+			Health:    health.EndpointStatus{URL: endpointURL, State: health.StateHealthy},
+			Circuit:   circuit.BreakerStatus{State: circuit.StateClosed},
+		}
+
+		if placementCfg, exists := cfg.GetPlacementConfig(placementKey); exists {
+			status.Fallback = placementCfg.Fallback
+		}
+		if endpointHealth, exists := healthSnapshot[placementKey]; exists {
+			status.Health = endpointHealth
+		}
+		if breaker, exists := breakerSnapshot[placementKey]; exists {
+			status.Circuit = breaker
+		}
+		if stats, exists := limitStats[placementKey]; exists {
+			status.Limits = &stats
+		}
+
+		placements = append(placements, status)
+	}
+
+	sort.Slice(placements, func(i, j int) bool {
+		return placements[i].Placement < placements[j].Placement
+	})
+	return placements
+}
+
+// ExplainRoute returns the routing decision for a key and the failover the handler would apply now
+// Unlike a real request it does not consume rate limit tokens or advance circuit breaker state
+func (h *Handler) ExplainRoute(routingKey string) (*routing.RoutingDecision, FailoverPlan, error) {
+	decision, err := h.router.Route(routingKey)
+	if err != nil {
+		return nil, FailoverPlan{}, err
+	}
+
+	breaker := h.circuitManager.GetBreaker(decision.PlacementKey)
+	return decision, h.planFailover(decision, breaker.WouldAllow()), nil
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/circuit"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/routing"
+)
+
+func TestHandler_ExplainRoute(t *testing.T) {
+	cell := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer cell.Close()
+
+	cfg := &config.Config{
+		Version: "v1",
+		RoutingTable: map[string]string{
+			"acme":   "tier1",
+			"globex": "tier2",
+		},
+		Placements: map[string]*config.PlacementConfig{
+			"tier1": {URL: cell.URL, Fallback: "tier3"},
+			"tier2": {URL: cell.URL},
+			"tier3": {URL: cell.URL},
+		},
+		DefaultPlacement: "tier3",
+	}
+	handler := NewHandler(routing.NewRouter(cfg), cfg, logging.NewLogger())
+	defer handler.Stop()
+
+	// Healthy placements route without failover
+	decision, plan, err := handler.ExplainRoute("acme")
+	if err != nil {
+		t.Fatalf("ExplainRoute() error = %v", err)
+	}
+	if decision.PlacementKey != "tier1" || plan.PlacementKey != "tier1" || plan.FailoverReason != "" {
+		t.Errorf("ExplainRoute(acme) = %s -> %+v, want tier1 without failover", decision.PlacementKey, plan)
+	}
+
+	// Open circuits fail over to the fallback, or reject without one
+	for _, placementKey := range []string{"tier1", "tier2"} {
+		breaker := handler.circuitManager.GetBreaker(placementKey)
+		for i := 0; i < 5; i++ {
+			breaker.RecordFailure()
+		}
+	}
+
+	_, plan, _ = handler.ExplainRoute("acme")
+	if plan.PlacementKey != "tier3" || plan.FailoverReason != "circuit_open" || plan.Rejected {
+		t.Errorf("ExplainRoute(acme) with open circuit = %+v, want fallback to tier3", plan)
+	}
+
+	_, plan, _ = handler.ExplainRoute("globex")
+	if !plan.Rejected {
+		t.Errorf("ExplainRoute(globex) with open circuit = %+v, want rejected", plan)
+	}
+
+	// Dry-runs must not advance breaker state
+	if state := handler.circuitManager.GetBreaker("tier1").GetState(); state != circuit.StateOpen {
+		t.Errorf("breaker state after dry-run = %s, want %s", state, circuit.StateOpen)
+	}
+
+	placements := handler.Placements()
+	if len(placements) != 3 || placements[0].Placement != "tier1" {
+		t.Fatalf("Placements() = %+v, want tier1, tier2, tier3", placements)
+	}
+	if placements[0].Circuit.State != circuit.StateOpen || placements[0].Fallback != "tier3" {
+		t.Errorf("Placements()[tier1] = %+v, want open circuit with fallback tier3", placements[0])
+	}
+}
+
+func TestHandler_PlacementsFollowLiveConfig(t *testing.T) {
+	cfg := &config.Config{
+		Version:          "v1",
+		RoutingTable:     map[string]string{"acme": "tier1"},
+		Placements:       map[string]*config.PlacementConfig{"tier1": {URL: "http://cell-tier1:9001"}},
+		DefaultPlacement: "tier1",
+	}
+	live := cfg
+	handler := NewHandler(routing.NewRouter(cfg), cfg, logging.NewLogger())
+	defer handler.Stop()
+	handler.SetConfigSource(func() *config.Config { return live })
+
+	// A reload moves tier1 and adds tier2
+	live = &config.Config{
+		Version:      "v2",
+		RoutingTable: map[string]string{"acme": "tier1"},
+		Placements: map[string]*config.PlacementConfig{
+			"tier1": {URL: "http://cell-tier1-new:9001", Fallback: "tier2"},
+			"tier2": {URL: "http://cell-tier2:9002"},
+		},
+		DefaultPlacement: "tier1",
+	}
+
+	placements := handler.Placements()
+	if len(placements) != 2 || placements[0].URL != "http://cell-tier1-new:9001" || placements[0].Fallback != "tier2" {
+		t.Errorf("Placements() = %+v, want tier1 at its new URL with fallback tier2, and tier2", placements)
+	}
+}
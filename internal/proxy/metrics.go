@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/circuit"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/health"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/metrics"
+)
+
+// handlerMetrics holds the router's request and resilience metrics
+type handlerMetrics struct {
+	registry *metrics.Registry
+
+	requests        *metrics.CounterVec
+	duration        *metrics.HistogramVec
+	bodyLimitAborts *metrics.CounterVec
+
+	breakerState    *metrics.GaugeVec
+	breakerFailures *metrics.GaugeVec
+	upstreamHealthy *metrics.GaugeVec
+	inFlight        *metrics.GaugeVec
+	capacity        *metrics.GaugeVec
+	queued          *metrics.GaugeVec
+}
+
+// newHandlerMetrics registers request metrics and live-state gauges for a handler
+func newHandlerMetrics(h *Handler) *handlerMetrics {
+	reg := metrics.NewRegistry()
+	m := &handlerMetrics{
+		registry: reg,
+		requests: reg.NewCounterVec("router_requests_total",
+			"Requests handled by the router.",
+			"placement", "route_reason", "failover_reason", "code"),
+		duration: reg.NewHistogramVec("router_request_duration_seconds",
+			"End-to-end request latency in seconds.", metrics.DefaultBuckets,
+			"placement", "route_reason", "failover_reason"),
+		bodyLimitAborts: reg.NewCounterVec("router_body_limit_aborts_total",
+			"Requests aborted mid-stream for exceeding max_request_body_bytes.",
+			"placement"),
+		breakerState: reg.NewGaugeVec("router_circuit_breaker_state",
+			"Circuit breaker state per placement (1 for the current state).",
+			"placement", "state"),
+		breakerFailures: reg.NewGaugeVec("router_circuit_breaker_failures",
+			"Consecutive failures counted by the circuit breaker.",
+			"placement"),
+		upstreamHealthy: reg.NewGaugeVec("router_upstream_healthy",
+			"Active health check result per placement (1 healthy, 0 unhealthy).",
+			"placement"),
+		inFlight: reg.NewGaugeVec("router_concurrency_in_flight",
+			"Requests holding a concurrency slot per placement.",
+			"placement"),
+		capacity: reg.NewGaugeVec("router_concurrency_limit",
+			"Current concurrency limit per placement.",
+			"placement"),
+		queued: reg.NewGaugeVec("router_concurrency_queued",
+			"Requests waiting for a concurrency slot per placement.",
+			"placement"),
+	}
+
+	reg.OnScrape(func() { m.collect(h) })
+	return m
+}
+
+// observeRequest records a completed request
+func (m *handlerMetrics) observeRequest(placementKey, routeReason, failoverReason string, statusCode int, duration time.Duration) {
+	if failoverReason == "" {
+		failoverReason = "none"
+	}
+	m.requests.Inc(placementKey, routeReason, failoverReason, strconv.Itoa(statusCode))
+	m.duration.Observe(duration.Seconds(), placementKey, routeReason, failoverReason)
+}
+
+// breakerStates lists every state reported by router_circuit_breaker_state
+var breakerStates = []circuit.State{
+	circuit.StateClosed, circuit.StateOpen, circuit.StateHalfOpen,
+	circuit.StateForcedOpen, circuit.StateForcedClosed,
+}
+
+// collect refreshes gauges from the handler's live resilience state
+func (m *handlerMetrics) collect(h *Handler) {
+	m.breakerState.Reset()
+	m.breakerFailures.Reset()
+	for placementKey, status := range h.circuitManager.Snapshot() {
+		for _, state := range breakerStates {
+			m.breakerState.Set(boolToFloat(status.State == state), placementKey, string(state))
+		}
+		m.breakerFailures.Set(float64(status.Failures), placementKey)
+	}
+
+	m.upstreamHealthy.Reset()
+	for placementKey, status := range h.healthChecker.Snapshot() {
+		m.upstreamHealthy.Set(boolToFloat(status.State == health.StateHealthy), placementKey)
+	}
+
+	m.inFlight.Reset()
+	m.capacity.Reset()
+	m.queued.Reset()
+	for _, stats := range h.limitsManager.Stats() {
+		m.inFlight.Set(float64(stats.InFlight), stats.Placement)
+		m.capacity.Set(float64(stats.Limit), stats.Placement)
+		m.queued.Set(float64(stats.Queued), stats.Placement)
+	}
+}
+
+// boolToFloat converts a bool to a gauge value
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
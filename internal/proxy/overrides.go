@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"fmt"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/admin"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+)
+
+// ApplyOverride forces a placement's circuit breaker or drains it until the override expires
+func (h *Handler) ApplyOverride(override protocol.OverrideMessage) error {
+	if _, exists := h.currentConfig().GetCellEndpoints()[override.Placement]; !exists {
+		return fmt.Errorf("%w: %s", admin.ErrUnknownPlacement, override.Placement)
+	}
+
+	breaker := h.circuitManager.GetBreaker(override.Placement)
+	switch override.Action {
+	case protocol.OverrideForceOpen:
+		breaker.ForceOpen(override.ExpiresAt)
+	case protocol.OverrideForceClosed:
+		breaker.ForceClosed(override.ExpiresAt)
+	case protocol.OverrideClearCircuit:
+		breaker.ClearOverride()
+	case protocol.OverrideDrain:
+		if !h.healthChecker.Drain(override.Placement, override.ExpiresAt) {
+			return fmt.Errorf("%w: %s has no health state", admin.ErrUnknownPlacement, override.Placement)
+		}
+	case protocol.OverrideUndrain:
+		if !h.healthChecker.Undrain(override.Placement) {
+			return fmt.Errorf("%w: %s has no health state", admin.ErrUnknownPlacement, override.Placement)
+		}
+	default:
+		return fmt.Errorf("unknown override action: %s", override.Action)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/admin"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/circuit"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/config"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/health"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/protocol"
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/routing"
+)
+
+func TestHandler_ApplyOverride(t *testing.T) {
+	cell := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer cell.Close()
+
+	cfg := &config.Config{
+		Version:      "v1",
+		RoutingTable: map[string]string{"acme": "tier1"},
+		Placements: map[string]*config.PlacementConfig{
+			"tier1": {URL: cell.URL, Fallback: "tier3"},
+			"tier3": {URL: cell.URL},
+		},
+		DefaultPlacement: "tier3",
+	}
+	handler := NewHandler(routing.NewRouter(cfg), cfg, logging.NewLogger())
+	defer handler.Stop()
+
+	apply := func(action protocol.OverrideAction, expiresAt time.Time) {
+		t.Helper()
+		err := handler.ApplyOverride(protocol.OverrideMessage{
+			Type:      protocol.MessageTypeOverride,
+			Placement: "tier1",
+			Action:    action,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			t.Fatalf("ApplyOverride(%s) error = %v", action, err)
+		}
+	}
+
+	// Draining moves new traffic to the fallback
+	apply(protocol.OverrideDrain, time.Now().Add(time.Minute))
+	if _, plan, _ := handler.ExplainRoute("acme"); plan.PlacementKey != "tier3" || plan.FailoverReason != "upstream_unhealthy" {
+		t.Errorf("ExplainRoute(acme) while draining = %+v, want failover to tier3", plan)
+	}
+	if state := handler.healthChecker.GetState("tier1"); state != health.StateDraining {
+		t.Errorf("health state = %s, want %s", state, health.StateDraining)
+	}
+	apply(protocol.OverrideUndrain, time.Time{})
+
+	// Forced breakers report the forced state until cleared
+	apply(protocol.OverrideForceOpen, time.Now().Add(time.Minute))
+	if state := handler.circuitManager.GetBreaker("tier1").GetState(); state != circuit.StateForcedOpen {
+		t.Errorf("breaker state = %s, want %s", state, circuit.StateForcedOpen)
+	}
+	if _, plan, _ := handler.ExplainRoute("acme"); plan.PlacementKey != "tier3" || plan.FailoverReason != "circuit_open" {
+		t.Errorf("ExplainRoute(acme) while forced open = %+v, want failover to tier3", plan)
+	}
+	apply(protocol.OverrideClearCircuit, time.Time{})
+	if _, plan, _ := handler.ExplainRoute("acme"); plan.PlacementKey != "tier1" {
+		t.Errorf("ExplainRoute(acme) after clearing overrides = %+v, want tier1", plan)
+	}
+
+	// Expired overrides no longer apply
+	apply(protocol.OverrideForceOpen, time.Now().Add(-time.Second))
+	if state := handler.circuitManager.GetBreaker("tier1").GetState(); state != circuit.StateClosed {
+		t.Errorf("breaker state with expired override = %s, want %s", state, circuit.StateClosed)
+	}
+
+	err := handler.ApplyOverride(protocol.OverrideMessage{Placement: "nope", Action: protocol.OverrideDrain})
+	if !errors.Is(err, admin.ErrUnknownPlacement) {
+		t.Errorf("ApplyOverride(unknown placement) error = %v, want ErrUnknownPlacement", err)
+	}
+
+	// Placements added by a reload can be overridden
+	reloaded := *cfg
+	reloaded.Placements = map[string]*config.PlacementConfig{"tier1": {URL: cell.URL}, "tier3": {URL: cell.URL}, "tier4": {URL: cell.URL}}
+	handler.SetConfigSource(func() *config.Config { return &reloaded })
+	if err := handler.ApplyOverride(protocol.OverrideMessage{Placement: "tier4", Action: protocol.OverrideForceOpen}); err != nil {
+		t.Errorf("ApplyOverride(reloaded placement) error = %v", err)
+	}
+}
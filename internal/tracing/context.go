@@ -0,0 +1,161 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	headerTraceparent = "traceparent"
+	headerTracestate  = "tracestate"
+
+	flagSampled = 0x01
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// String returns the lowercase hex encoding
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid reports whether the trace ID is non-zero
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the lowercase hex encoding
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid reports whether the span ID is non-zero
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext is the propagated identity of a span (W3C Trace Context)
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string // Opaque vendor state, passed through unchanged
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the span context as a traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := 0
+	if sc.Sampled {
+		flags = flagSampled
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a version 00 traceparent header value
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("traceparent: expected 4 fields, got %d", len(parts))
+	}
+	if len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid version %q", parts[0])
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, fmt.Errorf("traceparent: expected 4 fields for version 00, got %d", len(parts))
+	}
+
+	var sc SpanContext
+	if err := decodeHex(parts[1], sc.TraceID[:]); err != nil || !sc.TraceID.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid trace id %q", parts[1])
+	}
+	if err := decodeHex(parts[2], sc.SpanID[:]); err != nil || !sc.SpanID.IsValid() {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid parent id %q", parts[2])
+	}
+
+	var flags [1]byte
+	if err := decodeHex(parts[3], flags[:]); err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent: invalid flags %q", parts[3])
+	}
+	sc.Sampled = flags[0]&flagSampled != 0
+	return sc, nil
+}
+
+// Extract reads the remote span context from W3C trace context headers
+// Returns false if no valid traceparent is present
+func Extract(header http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(header.Get(headerTraceparent))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.TraceState = header.Get(headerTracestate)
+	return sc, true
+}
+
+// Inject writes the span context in ctx as W3C trace context headers
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	sc := span.SpanContext()
+	header.Set(headerTraceparent, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(headerTracestate, sc.TraceState)
+	} else {
+		header.Del(headerTracestate)
+	}
+}
+
+type spanContextKey struct{}
+type remoteContextKey struct{}
+
+// ContextWithSpan returns a context carrying span as the current span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the current span, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent returns a context whose next span continues a remote trace
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey{}, sc)
+}
+
+// remoteParent returns the remote span context stored in ctx, if any
+func remoteParent(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(remoteContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// decodeHex decodes s into dst, requiring an exact length match
+func decodeHex(s string, dst []byte) error {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return fmt.Errorf("invalid length or case")
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// randomID fills b with random bytes
+func randomID(b []byte) {
+	rand.Read(b)
+}
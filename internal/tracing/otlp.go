@@ -0,0 +1,273 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+)
+
+// otlpTracesPath is the OTLP/HTTP traces endpoint path
+const otlpTracesPath = "/v1/traces"
+
+// OTLP status codes
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// OTLPConfig configures an OTLP/HTTP exporter
+type OTLPConfig struct {
+	Endpoint      string        // Collector base URL, e.g. http://collector:4318
+	ServiceName   string        // Reported as the service.name resource attribute
+	BatchSize     int           // Spans per export request
+	FlushInterval time.Duration // Max time a span waits before export
+	QueueSize     int           // Spans buffered before new spans are dropped
+}
+
+// OTLPExporter batches spans and sends them to a collector as OTLP/HTTP JSON
+type OTLPExporter struct {
+	config OTLPConfig
+	client *http.Client
+	logger *logging.Logger
+	queue  chan *Span
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewOTLPExporter creates an exporter and starts its export loop
+func NewOTLPExporter(config OTLPConfig, logger *logging.Logger) *OTLPExporter {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 2048
+	}
+
+	e := &OTLPExporter{
+		config: config,
+		client: &http.Client{Timeout: 5 * time.Second},
+		logger: logger,
+		queue:  make(chan *Span, config.QueueSize),
+		stopCh: make(chan struct{}),
+	}
+
+	e.wg.Add(1)
+	go e.exportLoop()
+	return e
+}
+
+// ExportSpan queues a finished span, dropping it if the queue is full
+func (e *OTLPExporter) ExportSpan(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+		// Never block the request path on the collector
+	}
+}
+
+// Shutdown flushes queued spans and stops the export loop
+func (e *OTLPExporter) Shutdown() {
+	close(e.stopCh)
+	e.wg.Wait()
+}
+
+// exportLoop sends spans in batches
+func (e *OTLPExporter) exportLoop() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, e.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			e.logger.LogError("failed to export spans", err, map[string]interface{}{
+				"endpoint": e.config.Endpoint,
+				"spans":    len(batch),
+			})
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stopCh:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts a batch of spans to the collector
+func (e *OTLPExporter) send(spans []*Span) error {
+	data, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(e.config.Endpoint, "/") + otlpTracesPath
+	resp, err := e.client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP JSON encoding (opentelemetry-proto ExportTraceServiceRequest)
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	TraceState        string         `json:"traceState,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"` // int64 is a string in OTLP JSON
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// encode converts spans to an OTLP export request
+func (e *OTLPExporter) encode(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mu.Lock()
+		s := otlpSpan{
+			TraceID:           span.context.TraceID.String(),
+			SpanID:            span.context.SpanID.String(),
+			TraceState:        span.context.TraceState,
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.attributes),
+			Status:            otlpStatus{Code: statusUnset},
+		}
+		if span.parentID.IsValid() {
+			s.ParentSpanID = span.parentID.String()
+		}
+		if span.errMessage != "" {
+			s.Status = otlpStatus{Code: statusError, Message: span.errMessage}
+		}
+		span.mu.Unlock()
+		encoded = append(encoded, s)
+	}
+
+	return otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: encodeAttributes(map[string]interface{}{"service.name": e.config.ServiceName}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/gvquiroz/cell-routing-from-scratch"},
+				Spans: encoded,
+			}},
+		}},
+	}
+}
+
+// encodeAttributes converts span attributes to OTLP key-values, sorted by key
+func encodeAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, key := range keys {
+		var value otlpValue
+		switch v := attributes[key].(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			value.IntValue = &s
+		case float64:
+			value.DoubleValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		kvs = append(kvs, otlpKeyValue{Key: key, Value: value})
+	}
+	return kvs
+}
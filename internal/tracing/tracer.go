@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SpanKind describes the relationship of a span to its remote peers (OTLP values)
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Exporter receives finished spans
+type Exporter interface {
+	ExportSpan(span *Span)
+}
+
+// Tracer creates spans and hands sampled, finished spans to an exporter
+type Tracer struct {
+	serviceName string
+	exporter    Exporter // nil = spans are propagated and logged but not exported
+}
+
+// NewTracer creates a tracer; exporter may be nil
+func NewTracer(serviceName string, exporter Exporter) *Tracer {
+	return &Tracer{
+		serviceName: serviceName,
+		exporter:    exporter,
+	}
+}
+
+// Start begins a span as a child of the current span in ctx, or of a remote parent,
+// or as a new root. Returns a context carrying the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		tracer:     t,
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		sc := parent.SpanContext()
+		span.context = SpanContext{TraceID: sc.TraceID, Sampled: sc.Sampled, TraceState: sc.TraceState}
+		span.parentID = sc.SpanID
+	} else if remote, ok := remoteParent(ctx); ok {
+		span.context = SpanContext{TraceID: remote.TraceID, Sampled: remote.Sampled, TraceState: remote.TraceState}
+		span.parentID = remote.SpanID
+	} else {
+		randomID(span.context.TraceID[:])
+		span.context.Sampled = true
+	}
+	randomID(span.context.SpanID[:])
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Span is a timed operation within a trace
+type Span struct {
+	tracer     *Tracer
+	name       string
+	kind       SpanKind
+	context    SpanContext
+	parentID   SpanID
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	errMessage string
+	ended      bool
+	mu         sync.Mutex
+}
+
+// SpanContext returns the span's propagated identity
+func (s *Span) SpanContext() SpanContext {
+	return s.context
+}
+
+// TraceID returns the span's trace ID
+func (s *Span) TraceID() TraceID {
+	return s.context.TraceID
+}
+
+// SetAttribute annotates the span; values should be strings, bools or numbers
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errMessage = message
+}
+
+// End finishes the span and exports it if sampled; later calls are ignored
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(s)
+	}
+}
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gvquiroz/cell-routing-from-scratch/internal/logging"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantErr     bool
+		wantSampled bool
+	}{
+		{name: "sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantSampled: true},
+		{name: "not sampled", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		{name: "uppercase", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "invalid version", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		{name: "missing fields", value: "00-4bf92f3577b34da6a3ce929d0e0e4736", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseTraceparent(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if sc.Sampled != tt.wantSampled {
+				t.Errorf("Sampled = %v, want %v", sc.Sampled, tt.wantSampled)
+			}
+			if got := sc.Traceparent(); got != tt.value {
+				t.Errorf("Traceparent() = %s, want %s", got, tt.value)
+			}
+		})
+	}
+}
+
+func TestTracer_PropagatesRemoteParent(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("tracestate", "vendor=abc")
+
+	remote, ok := Extract(header)
+	if !ok {
+		t.Fatal("Extract failed on valid traceparent")
+	}
+
+	tracer := NewTracer("test", nil)
+	ctx, span := tracer.Start(ContextWithRemoteParent(context.Background(), remote), "request", SpanKindServer)
+	_, child := tracer.Start(ctx, "upstream", SpanKindClient)
+
+	if child.TraceID() != remote.TraceID || span.TraceID() != remote.TraceID {
+		t.Errorf("Spans did not join remote trace %s", remote.TraceID)
+	}
+	if span.parentID != remote.SpanID || child.parentID != span.SpanContext().SpanID {
+		t.Error("Span parent IDs do not form a chain")
+	}
+
+	out := http.Header{}
+	Inject(ContextWithSpan(ctx, child), out)
+	if got, want := out.Get("traceparent"), child.SpanContext().Traceparent(); got != want {
+		t.Errorf("Injected traceparent = %s, want %s", got, want)
+	}
+	if out.Get("tracestate") != "vendor=abc" {
+		t.Errorf("Injected tracestate = %q, want vendor=abc", out.Get("tracestate"))
+	}
+}
+
+func TestOTLPExporter_SendsToCollector(t *testing.T) {
+	received := make(chan otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected export request: %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode export: %v", err)
+		}
+		received <- req
+	}))
+	defer collector.Close()
+
+	exporter := NewOTLPExporter(OTLPConfig{
+		Endpoint:      collector.URL,
+		ServiceName:   "cell-router",
+		FlushInterval: 10 * time.Millisecond,
+	}, logging.NewLogger())
+	defer exporter.Shutdown()
+
+	tracer := NewTracer("cell-router", exporter)
+	_, span := tracer.Start(context.Background(), "router.request", SpanKindServer)
+	span.SetAttribute("routing_key", "acme")
+	span.SetAttribute("http.status_code", 200)
+	span.End()
+
+	select {
+	case req := <-received:
+		spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+		if len(spans) != 1 {
+			t.Fatalf("Exported %d spans, want 1", len(spans))
+		}
+		if spans[0].TraceID != span.TraceID().String() || spans[0].Name != "router.request" {
+			t.Errorf("Exported span = %+v", spans[0])
+		}
+		if *req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue != "cell-router" {
+			t.Error("service.name resource attribute not set")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Collector did not receive spans")
+	}
+}